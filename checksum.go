@@ -0,0 +1,536 @@
+//////////////////////////////////////////////////////////////////////
+// checksum.go
+//
+// @usage
+//
+//     1. Import this package.
+//
+//         --------------------------------------------------
+//         import myMySQL "mysql"
+//         --------------------------------------------------
+//
+//     2. Compare a table between two connections.
+//
+//         --------------------------------------------------
+//         report, err := myMySQL.CompareTable(ctx, primaryDb, replicaDb, &myMySQL.ChecksumOptions{
+//             Table: "countries",
+//             Repair: true,
+//         })
+//         report.Write(os.Stdout)
+//         --------------------------------------------------
+//
+//     3. Apply the repair SQL to the target if you want it to match the source.
+//        The TIMESTAMP values are in UTC, so run it in the time zone '+00:00'.
+//
+//         --------------------------------------------------
+//         conn, err := replicaDb.Conn(ctx)
+//         defer conn.Close()
+//         conn.ExecContext(ctx, "SET time_zone='+00:00'")
+//         for _, stmt := range report.RepairSQL {
+//             conn.ExecContext(ctx, stmt)
+//         }
+//         --------------------------------------------------
+//
+//     The table is split into chunks of primary key ranges of the source.
+//     Only the chunks whose row count or checksum differ are compared row by row.
+//
+//     Each side is read in a consistent snapshot, so the chunks of one side
+//     are from the same point in time. The target snapshot is taken just
+//     after the source one, though: rows written in between, or not yet
+//     replicated, show up as differences. Compare while the writes are
+//     stopped, or compare again before repairing.
+//
+//     The missing and changed rows are repaired with INSERT ... ON DUPLICATE
+//     KEY UPDATE, not REPLACE, which deletes the row first and so fires the
+//     ON DELETE actions of the foreign keys referencing it.
+//
+//
+// MIT License
+//
+// Copyright (c) 2019 noknow.info
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+// PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+// HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+// OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTW//ARE.
+//////////////////////////////////////////////////////////////////////
+package mysql
+
+import (
+    "bufio"
+    "context"
+    "database/sql"
+    "fmt"
+    "io"
+    "strings"
+)
+
+const (
+    CHECKSUM_CRC32 = "crc32"
+    CHECKSUM_MD5 = "md5"
+
+    DIFF_MISSING = "missing"
+    DIFF_EXTRA = "extra"
+    DIFF_CHANGED = "changed"
+
+    DEFAULT_CHECKSUM_CHUNK_SIZE = 1000
+)
+
+type ChecksumOptions struct {
+    Table string
+    // Key columns of the chunks. Defaults to the primary key of the source.
+    Key []string
+    ChunkSize int
+    // CHECKSUM_CRC32 (default) or CHECKSUM_MD5.
+    Algorithm string
+    // Generate the SQL which makes the target match the source.
+    Repair bool
+}
+
+type ChecksumChunk struct {
+    // nil when the range is unbounded.
+    Lower []interface{}
+    Upper []interface{}
+    SourceRows int64
+    TargetRows int64
+    SourceChecksum string
+    TargetChecksum string
+}
+
+type RowDiff struct {
+    Kind string
+    Key []interface{}
+    // Changed columns (DIFF_CHANGED only).
+    Columns []string
+    Source []interface{}
+    Target []interface{}
+}
+
+type ChecksumReport struct {
+    Table string
+    Key []string
+    Columns []string
+    Chunks int
+    DifferentChunks []ChecksumChunk
+    Diffs []RowDiff
+    RepairSQL []string
+}
+
+type checksumSide struct {
+    conn *sql.Conn
+    columns []exportColumn
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Compare the table between the source and the target.
+//////////////////////////////////////////////////////////////////////
+func CompareTable(ctx context.Context, source, target *sql.DB, opts *ChecksumOptions) (*ChecksumReport, error) {
+    o := ChecksumOptions{}
+    if opts != nil {
+        o = *opts
+    }
+    if o.Table == "" {
+        return nil, fmt.Errorf("table name is required")
+    }
+    if o.ChunkSize <= 0 {
+        o.ChunkSize = DEFAULT_CHECKSUM_CHUNK_SIZE
+    }
+    if o.Algorithm == "" {
+        o.Algorithm = CHECKSUM_CRC32
+    }
+    if o.Algorithm != CHECKSUM_CRC32 && o.Algorithm != CHECKSUM_MD5 {
+        return nil, fmt.Errorf("unknown checksum algorithm %q", o.Algorithm)
+    }
+    src := &checksumSide{}
+    dst := &checksumSide{}
+    var err error
+    if src.conn, err = snapshotConn(ctx, source); err != nil {
+        return nil, err
+    }
+    defer closeSnapshotConn(src.conn)
+    if dst.conn, err = snapshotConn(ctx, target); err != nil {
+        return nil, err
+    }
+    defer closeSnapshotConn(dst.conn)
+    for _, side := range []*checksumSide{src, dst} {
+        if err := side.loadColumns(ctx, o.Table); err != nil {
+            return nil, err
+        }
+    }
+    if len(o.Key) == 0 {
+        primaryKeys, err := PrimaryKeys(ctx, src.conn)
+        if err != nil {
+            return nil, err
+        }
+        if o.Key = primaryKeys[o.Table]; len(o.Key) == 0 {
+            return nil, fmt.Errorf("table %s has no primary key", o.Table)
+        }
+    }
+    report := &ChecksumReport{
+        Table: o.Table,
+        Key: o.Key,
+    }
+    for _, c := range src.columns {
+        report.Columns = append(report.Columns, c.name)
+    }
+    if len(src.columns) != len(dst.columns) {
+        return nil, fmt.Errorf("table %s has %d columns on the source and %d on the target", o.Table, len(src.columns), len(dst.columns))
+    }
+    for i := range src.columns {
+        if src.columns[i].name != dst.columns[i].name {
+            return nil, fmt.Errorf("column %d of %s is %s on the source and %s on the target", i + 1, o.Table, src.columns[i].name, dst.columns[i].name)
+        }
+    }
+
+    var lower []interface{}
+    for {
+        upper, err := src.nextBoundary(ctx, &o, lower)
+        if err != nil {
+            return nil, err
+        }
+        chunk := ChecksumChunk{Lower: lower, Upper: upper}
+        if chunk.SourceRows, chunk.SourceChecksum, err = src.checksum(ctx, &o, lower, upper); err != nil {
+            return nil, err
+        }
+        if chunk.TargetRows, chunk.TargetChecksum, err = dst.checksum(ctx, &o, lower, upper); err != nil {
+            return nil, err
+        }
+        report.Chunks++
+        if chunk.SourceRows != chunk.TargetRows || chunk.SourceChecksum != chunk.TargetChecksum {
+            report.DifferentChunks = append(report.DifferentChunks, chunk)
+            if err := report.diffChunk(ctx, &o, src, dst, lower, upper); err != nil {
+                return nil, err
+            }
+        }
+        if upper == nil {
+            break
+        }
+        lower = upper
+    }
+
+    if o.Repair {
+        report.RepairSQL = report.repairSQL()
+    }
+    return report, nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get the columns of the table.
+//////////////////////////////////////////////////////////////////////
+func (s *checksumSide) loadColumns(ctx context.Context, table string) error {
+    rows, err := s.conn.QueryContext(ctx, "SELECT * FROM " + QuoteIdentifier(table) + " LIMIT 0")
+    if err != nil {
+        return fmt.Errorf("conn.QueryContext() error: %w", err)
+    }
+    defer rows.Close()
+    s.columns, err = exportColumns(rows)
+    return err
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get the upper key of the chunk which starts after lower.
+// nil when the chunk is the last one.
+//////////////////////////////////////////////////////////////////////
+func (s *checksumSide) nextBoundary(ctx context.Context, o *ChecksumOptions, lower []interface{}) ([]interface{}, error) {
+    keys := quoteIdentifiers(o.Key)
+    where, args := chunkCondition(o.Key, lower, nil)
+    query := "SELECT " + strings.Join(keys, ",") + " FROM " + QuoteIdentifier(o.Table) + where +
+            " ORDER BY " + strings.Join(keys, ",") +
+            fmt.Sprintf(" LIMIT 1 OFFSET %d", o.ChunkSize - 1)
+    rows, err := s.conn.QueryContext(ctx, query, args...)
+    if err != nil {
+        return nil, fmt.Errorf("conn.QueryContext() error: %w", err)
+    }
+    defer rows.Close()
+    if !rows.Next() {
+        return nil, rows.Err()
+    }
+    scanned := make([]interface{}, len(o.Key))
+    dest := make([]interface{}, len(o.Key))
+    for i := range scanned {
+        dest[i] = &scanned[i]
+    }
+    if err := rows.Scan(dest...); err != nil {
+        return nil, fmt.Errorf("rows.Scan() error: %w", err)
+    }
+    upper := make([]interface{}, len(o.Key))
+    for i, v := range scanned {
+        upper[i] = normalizeValue(s.kind(o.Key[i]), v)
+    }
+    return upper, nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get the row count and the aggregated checksum of the chunk.
+//////////////////////////////////////////////////////////////////////
+func (s *checksumSide) checksum(ctx context.Context, o *ChecksumOptions, lower, upper []interface{}) (int64, string, error) {
+    columns := make([]string, len(s.columns))
+    nulls := make([]string, len(s.columns))
+    for i, c := range s.columns {
+        columns[i] = QuoteIdentifier(c.name)
+        nulls[i] = "ISNULL(" + columns[i] + ")"
+    }
+    row := "CONCAT_WS('#'," + strings.Join(columns, ",") + ",CONCAT(" + strings.Join(nulls, ",") + "))"
+    var aggregate string
+    if o.Algorithm == CHECKSUM_MD5 {
+        aggregate = "BIT_XOR(CAST(CONV(SUBSTRING(MD5(" + row + "),1,16),16,10) AS UNSIGNED))"
+    } else {
+        aggregate = "BIT_XOR(CRC32(" + row + "))"
+    }
+    where, args := chunkCondition(o.Key, lower, upper)
+    query := "SELECT COUNT(*), COALESCE(" + aggregate + ",0) FROM " + QuoteIdentifier(o.Table) + where
+
+    var count int64
+    var checksum string
+    if err := s.conn.QueryRowContext(ctx, query, args...).Scan(&count, &checksum); err != nil {
+        return 0, "", fmt.Errorf("row.Scan() error: %w", err)
+    }
+    return count, checksum, nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get the rows of the chunk keyed by the key columns.
+//////////////////////////////////////////////////////////////////////
+func (s *checksumSide) rows(ctx context.Context, o *ChecksumOptions, lower, upper []interface{}) ([]string, map[string][]interface{}, error) {
+    where, args := chunkCondition(o.Key, lower, upper)
+    query := "SELECT * FROM " + QuoteIdentifier(o.Table) + where + " ORDER BY " + strings.Join(quoteIdentifiers(o.Key), ",")
+    rows, err := s.conn.QueryContext(ctx, query, args...)
+    if err != nil {
+        return nil, nil, fmt.Errorf("conn.QueryContext() error: %w", err)
+    }
+    defer rows.Close()
+
+    keyIndex := make([]int, len(o.Key))
+    for i, k := range o.Key {
+        keyIndex[i] = s.index(k)
+    }
+    scanned := make([]interface{}, len(s.columns))
+    dest := make([]interface{}, len(s.columns))
+    for i := range scanned {
+        dest[i] = &scanned[i]
+    }
+    var order []string
+    result := make(map[string][]interface{})
+    for rows.Next() {
+        if err := rows.Scan(dest...); err != nil {
+            return nil, nil, fmt.Errorf("rows.Scan() error: %w", err)
+        }
+        values := make([]interface{}, len(s.columns))
+        for i, c := range s.columns {
+            values[i] = normalizeValue(c.kind, scanned[i])
+        }
+        key := make([]interface{}, len(keyIndex))
+        for i, index := range keyIndex {
+            key[i] = values[index]
+        }
+        k := subsetKey(key)
+        order = append(order, k)
+        result[k] = values
+    }
+    return order, result, rows.Err()
+}
+
+func (s *checksumSide) index(column string) int {
+    for i, c := range s.columns {
+        if c.name == column {
+            return i
+        }
+    }
+    return -1
+}
+
+func (s *checksumSide) kind(column string) int {
+    if i := s.index(column); i >= 0 {
+        return s.columns[i].kind
+    }
+    return kindString
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Compare the rows of the chunk one by one.
+//////////////////////////////////////////////////////////////////////
+func (r *ChecksumReport) diffChunk(ctx context.Context, o *ChecksumOptions, src, dst *checksumSide, lower, upper []interface{}) error {
+    srcOrder, srcRows, err := src.rows(ctx, o, lower, upper)
+    if err != nil {
+        return err
+    }
+    dstOrder, dstRows, err := dst.rows(ctx, o, lower, upper)
+    if err != nil {
+        return err
+    }
+
+    keyIndex := make([]int, len(o.Key))
+    for i, k := range o.Key {
+        keyIndex[i] = src.index(k)
+    }
+    keyOf := func(values []interface{}) []interface{} {
+        key := make([]interface{}, len(keyIndex))
+        for i, index := range keyIndex {
+            key[i] = values[index]
+        }
+        return key
+    }
+
+    for _, k := range srcOrder {
+        s := srcRows[k]
+        t, ok := dstRows[k]
+        if !ok {
+            r.Diffs = append(r.Diffs, RowDiff{Kind: DIFF_MISSING, Key: keyOf(s), Source: s})
+            continue
+        }
+        var changed []string
+        for i := range s {
+            if fmt.Sprint(s[i]) != fmt.Sprint(t[i]) || (s[i] == nil) != (t[i] == nil) {
+                changed = append(changed, r.Columns[i])
+            }
+        }
+        if len(changed) > 0 {
+            r.Diffs = append(r.Diffs, RowDiff{Kind: DIFF_CHANGED, Key: keyOf(s), Columns: changed, Source: s, Target: t})
+        }
+    }
+    for _, k := range dstOrder {
+        if _, ok := srcRows[k]; !ok {
+            t := dstRows[k]
+            r.Diffs = append(r.Diffs, RowDiff{Kind: DIFF_EXTRA, Key: keyOf(t), Target: t})
+        }
+    }
+    return nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Generate the SQL which makes the target match the source.
+//////////////////////////////////////////////////////////////////////
+func (r *ChecksumReport) repairSQL() []string {
+    table := QuoteIdentifier(r.Table)
+    columns := strings.Join(quoteIdentifiers(r.Columns), ",")
+    isKey := make(map[string]bool)
+    for _, k := range r.Key {
+        isKey[k] = true
+    }
+    var updates []string
+    for _, c := range r.Columns {
+        if !isKey[c] {
+            updates = append(updates, QuoteIdentifier(c) + "=VALUES(" + QuoteIdentifier(c) + ")")
+        }
+    }
+    if len(updates) == 0 {
+        // Only key columns: the existing row is already the same.
+        quoted := QuoteIdentifier(r.Key[0])
+        updates = append(updates, quoted + "=" + quoted)
+    }
+    var result []string
+    for _, d := range r.Diffs {
+        if d.Kind == DIFF_EXTRA {
+            conditions := make([]string, len(r.Key))
+            for i, k := range r.Key {
+                conditions[i] = QuoteIdentifier(k) + "=" + sqlLiteral(d.Key[i])
+            }
+            result = append(result, "DELETE FROM " + table + " WHERE " + strings.Join(conditions, " AND ") + ";")
+            continue
+        }
+        values := make([]string, len(d.Source))
+        for i, v := range d.Source {
+            values[i] = sqlLiteral(v)
+        }
+        result = append(result, "INSERT INTO " + table + " (" + columns + ") VALUES (" + strings.Join(values, ",") + ") ON DUPLICATE KEY UPDATE " + strings.Join(updates, ",") + ";")
+    }
+    return result
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Write the report as text.
+//////////////////////////////////////////////////////////////////////
+func (r *ChecksumReport) Write(w io.Writer) error {
+    bw := bufio.NewWriter(w)
+    fmt.Fprintf(bw, "Table: %s\n", r.Table)
+    fmt.Fprintf(bw, "Chunks: %d, different: %d\n", r.Chunks, len(r.DifferentChunks))
+    for _, c := range r.DifferentChunks {
+        fmt.Fprintf(bw, "  (%s .. %s]: source %d rows %s, target %d rows %s\n",
+                formatKey(c.Lower), formatKey(c.Upper), c.SourceRows, c.SourceChecksum, c.TargetRows, c.TargetChecksum)
+    }
+    counts := make(map[string]int)
+    for _, d := range r.Diffs {
+        counts[d.Kind]++
+    }
+    fmt.Fprintf(bw, "Rows: %d changed, %d missing, %d extra\n", counts[DIFF_CHANGED], counts[DIFF_MISSING], counts[DIFF_EXTRA])
+    for _, d := range r.Diffs {
+        if d.Kind == DIFF_CHANGED {
+            fmt.Fprintf(bw, "  %-7s %s: %s\n", strings.ToUpper(d.Kind), formatKey(d.Key), strings.Join(d.Columns, ","))
+        } else {
+            fmt.Fprintf(bw, "  %-7s %s\n", strings.ToUpper(d.Kind), formatKey(d.Key))
+        }
+    }
+    if len(r.RepairSQL) > 0 {
+        bw.WriteString("Repair SQL:\n")
+        for _, stmt := range r.RepairSQL {
+            bw.WriteString(stmt + "\n")
+        }
+    }
+    return bw.Flush()
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Build the WHERE clause of the key range (lower, upper].
+//////////////////////////////////////////////////////////////////////
+func chunkCondition(key []string, lower, upper []interface{}) (string, []interface{}) {
+    left := strings.Join(quoteIdentifiers(key), ",")
+    placeholder := strings.TrimSuffix(strings.Repeat("?,", len(key)), ",")
+    if len(key) > 1 {
+        left = "(" + left + ")"
+        placeholder = "(" + placeholder + ")"
+    }
+    var conditions []string
+    var args []interface{}
+    if lower != nil {
+        conditions = append(conditions, left + " > " + placeholder)
+        args = append(args, lower...)
+    }
+    if upper != nil {
+        conditions = append(conditions, left + " <= " + placeholder)
+        args = append(args, upper...)
+    }
+    if len(conditions) == 0 {
+        return "", nil
+    }
+    return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+func quoteIdentifiers(names []string) []string {
+    quoted := make([]string, len(names))
+    for i, name := range names {
+        quoted[i] = QuoteIdentifier(name)
+    }
+    return quoted
+}
+
+func formatKey(key []interface{}) string {
+    if key == nil {
+        return "-"
+    }
+    parts := make([]string, len(key))
+    for i, v := range key {
+        parts[i] = fmt.Sprint(v)
+    }
+    return strings.Join(parts, ",")
+}
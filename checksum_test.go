@@ -0,0 +1,116 @@
+package mysql_test
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+    "reflect"
+    "testing"
+    myMySQL "mysql"
+    "mysql/mysqltest/embedded"
+)
+
+func openChecksumDBT(t testing.TB, statements ...string) *sql.DB {
+    t.Helper()
+    setup := func(db *sql.DB) error {
+        if _, err := db.Exec("CREATE TABLE items (id INT NOT NULL, name VARCHAR(20) NOT NULL, note VARCHAR(20) NULL, PRIMARY KEY(id))"); err != nil {
+            return err
+        }
+        for id := 1; id <= 10; id++ {
+            if _, err := db.Exec("INSERT INTO items VALUES (?, ?, '')", id, fmt.Sprintf("item%d", id)); err != nil {
+                return err
+            }
+        }
+        for _, statement := range statements {
+            if _, err := db.Exec(statement); err != nil {
+                return err
+            }
+        }
+        return nil
+    }
+    return embedded.NewDB(t, &embedded.Options{Setup: []func(db *sql.DB) error{setup}})
+}
+
+func TestCompareTable(t *testing.T) {
+    for _, algorithm := range []string{myMySQL.CHECKSUM_CRC32, myMySQL.CHECKSUM_MD5} {
+        t.Run(algorithm, func(t *testing.T) {
+            ctx := context.Background()
+            source := openChecksumDBT(t)
+            target := openChecksumDBT(t,
+                    "UPDATE items SET name = 'changed' WHERE id = 5",
+                    "DELETE FROM items WHERE id = 7",
+                    "UPDATE items SET note = NULL WHERE id = 8",
+                    "INSERT INTO items VALUES (11, 'extra', NULL)")
+            o := &myMySQL.ChecksumOptions{Table: "items", ChunkSize: 3, Algorithm: algorithm, Repair: true}
+            report, err := myMySQL.CompareTable(ctx, source, target, o)
+            if err != nil {
+                t.Fatalf("mysql.CompareTable() error: %s", err)
+            }
+
+            // (-∞,3] (3,6] (6,9] (9,∞)
+            if report.Chunks != 4 {
+                t.Errorf("Chunks = %d, want 4", report.Chunks)
+            }
+            var uppers []string
+            for _, c := range report.DifferentChunks {
+                uppers = append(uppers, fmt.Sprint(c.Upper))
+            }
+            if want := []string{"[6]", "[9]", "[]"}; !reflect.DeepEqual(uppers, want) {
+                t.Errorf("upper keys of the different chunks = %q, want %q", uppers, want)
+            }
+            var diffs []string
+            for _, d := range report.Diffs {
+                diffs = append(diffs, fmt.Sprintf("%s %v %v", d.Kind, d.Key, d.Columns))
+            }
+            want := []string{"changed [5] [name]", "missing [7] []", "changed [8] [note]", "extra [11] []"}
+            if !reflect.DeepEqual(diffs, want) {
+                t.Errorf("Diffs = %q, want %q", diffs, want)
+            }
+            wantSQL := []string{
+                "INSERT INTO `items` (`id`,`name`,`note`) VALUES (5,'item5','') ON DUPLICATE KEY UPDATE `name`=VALUES(`name`),`note`=VALUES(`note`);",
+                "INSERT INTO `items` (`id`,`name`,`note`) VALUES (7,'item7','') ON DUPLICATE KEY UPDATE `name`=VALUES(`name`),`note`=VALUES(`note`);",
+                "INSERT INTO `items` (`id`,`name`,`note`) VALUES (8,'item8','') ON DUPLICATE KEY UPDATE `name`=VALUES(`name`),`note`=VALUES(`note`);",
+                "DELETE FROM `items` WHERE `id`=11;",
+            }
+            if !reflect.DeepEqual(report.RepairSQL, wantSQL) {
+                t.Errorf("RepairSQL = %q, want %q", report.RepairSQL, wantSQL)
+            }
+
+            for _, stmt := range report.RepairSQL {
+                if _, err := target.Exec(stmt); err != nil {
+                    t.Fatalf("db.Exec(%q) error: %s", stmt, err)
+                }
+            }
+            if report, err = myMySQL.CompareTable(ctx, source, target, o); err != nil {
+                t.Fatalf("mysql.CompareTable() error: %s", err)
+            }
+            if len(report.DifferentChunks) != 0 || len(report.Diffs) != 0 {
+                t.Errorf("after the repair: %d different chunks, diffs %v", len(report.DifferentChunks), report.Diffs)
+            }
+        })
+    }
+}
+
+func TestCompareTableChunks(t *testing.T) {
+    ctx := context.Background()
+    db := openChecksumDBT(t)
+    tests := []struct {
+        chunkSize int
+        want int
+    }{
+        {1, 11},
+        {3, 4},
+        {5, 3},
+        {10, 2},
+        {20, 1},
+    }
+    for _, tt := range tests {
+        report, err := myMySQL.CompareTable(ctx, db, db, &myMySQL.ChecksumOptions{Table: "items", ChunkSize: tt.chunkSize})
+        if err != nil {
+            t.Fatalf("mysql.CompareTable() error: %s", err)
+        }
+        if report.Chunks != tt.want || len(report.DifferentChunks) != 0 {
+            t.Errorf("ChunkSize %d: %d chunks, %d different, want %d chunks", tt.chunkSize, report.Chunks, len(report.DifferentChunks), tt.want)
+        }
+    }
+}
@@ -0,0 +1,399 @@
+//////////////////////////////////////////////////////////////////////
+// circuit.go
+//
+// @usage
+//
+//     1. Import this package.
+//
+//         --------------------------------------------------
+//         import myMySQL "mysql"
+//         --------------------------------------------------
+//
+//     2. Create a circuit breaker for a connection and register its
+//        interceptor before the connection is opened.
+//
+//         --------------------------------------------------
+//         breaker := myMySQL.NewCircuitBreaker(myMySQL.DEFAULT_CONNECTION_NAME, &myMySQL.BreakerOptions{
+//             MaxFailures: 5,
+//             OpenTimeout: 30 * time.Second,
+//             OnStateChange: func(name, from, to string) {
+//                 log.Printf("[WARN] circuit breaker %s: %s -> %s\n", name, from, to)
+//             },
+//         })
+//         myMySQL.Use(breaker.Interceptor())
+//         myMySQL.Init(datasourceName)
+//         --------------------------------------------------
+//
+//     3. Fail fast while it is open.
+//
+//         --------------------------------------------------
+//         if err := breaker.Allow(); err != nil {
+//             // Respond 503 without waiting for a connection of the pool.
+//         }
+//         rows, err := myMySQL.Conn().Query(query)
+//         if errors.Is(err, myMySQL.ErrCircuitOpen) {
+//             // Respond 503 without waiting for the database.
+//         }
+//         --------------------------------------------------
+//
+//     The breaker opens after MaxFailures consecutive connection errors, or
+//     when the rate of them in Window reaches FailureRate. After OpenTimeout
+//     it half-opens and lets HalfOpenProbes calls through: a success closes
+//     it, a failure opens it again. Commit and rollback are never rejected.
+//
+//     The interceptor does not cover the pool waits. It rejects the connects
+//     and the statements, but a call made while all MaxOpenConns connections
+//     are in use still waits for a free one, and is rejected only after
+//     that. Only breaker.Allow() fails fast there.
+//
+//
+// MIT License
+//
+// Copyright (c) 2019 noknow.info
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+// PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+// HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+// OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTW//ARE.
+//////////////////////////////////////////////////////////////////////
+package mysql
+
+import (
+    "context"
+    "database/sql/driver"
+    "errors"
+    "fmt"
+    "net"
+    "sync"
+    "syscall"
+    "time"
+    gomysql "github.com/go-sql-driver/mysql"
+)
+
+const (
+    CIRCUIT_CLOSED = "closed"
+    CIRCUIT_OPEN = "open"
+    CIRCUIT_HALF_OPEN = "half-open"
+
+    DEFAULT_MAX_FAILURES = 5
+    DEFAULT_FAILURE_WINDOW = 10 * time.Second
+    DEFAULT_MIN_REQUESTS = 20
+    DEFAULT_OPEN_TIMEOUT = 30 * time.Second
+    DEFAULT_HALF_OPEN_PROBES = 1
+)
+
+var (
+    ErrCircuitOpen = errors.New("circuit breaker is open")
+
+    myBreakers = map[string]*CircuitBreaker{}
+    myBreakersMu sync.RWMutex
+)
+
+// Returned while the breaker is open. errors.Is(err, ErrCircuitOpen) is true.
+type CircuitOpenError struct {
+    Name string
+    RetryAfter time.Duration
+}
+
+type BreakerOptions struct {
+    // Consecutive failures to open. Defaults to DEFAULT_MAX_FAILURES. -1 disables it.
+    MaxFailures int
+    // Failure rate in Window to open, e.g. 0.5. 0 disables it.
+    FailureRate float64
+    // Defaults to DEFAULT_FAILURE_WINDOW.
+    Window time.Duration
+    // Calls in Window needed before FailureRate applies. Defaults to DEFAULT_MIN_REQUESTS.
+    MinRequests int
+    // Time to half-open. Defaults to DEFAULT_OPEN_TIMEOUT.
+    OpenTimeout time.Duration
+    // Concurrent calls let through while half-open. Defaults to DEFAULT_HALF_OPEN_PROBES.
+    HalfOpenProbes int
+    // Errors which count as failures. Defaults to IsConnectionError.
+    IsFailure func(err error) bool
+    // Called after the state changed.
+    OnStateChange func(name, from, to string)
+}
+
+type CircuitBreaker struct {
+    name string
+    o BreakerOptions
+    mu sync.Mutex
+    state string
+    failures int
+    windowStart time.Time
+    windowCalls int
+    windowFailures int
+    openedAt time.Time
+    probes int
+    // State changes to report after unlocking.
+    changes [][2]string
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Create a circuit breaker for the named connection. It replaces the
+// breaker of the same name returned by Breaker().
+//////////////////////////////////////////////////////////////////////
+func NewCircuitBreaker(name string, opts *BreakerOptions) *CircuitBreaker {
+    o := BreakerOptions{}
+    if opts != nil {
+        o = *opts
+    }
+    if o.MaxFailures == 0 {
+        o.MaxFailures = DEFAULT_MAX_FAILURES
+    }
+    if o.Window <= 0 {
+        o.Window = DEFAULT_FAILURE_WINDOW
+    }
+    if o.MinRequests <= 0 {
+        o.MinRequests = DEFAULT_MIN_REQUESTS
+    }
+    if o.OpenTimeout <= 0 {
+        o.OpenTimeout = DEFAULT_OPEN_TIMEOUT
+    }
+    if o.HalfOpenProbes <= 0 {
+        o.HalfOpenProbes = DEFAULT_HALF_OPEN_PROBES
+    }
+    if o.IsFailure == nil {
+        o.IsFailure = IsConnectionError
+    }
+    cb := &CircuitBreaker{
+        name: name,
+        o: o,
+        state: CIRCUIT_CLOSED,
+    }
+    myBreakersMu.Lock()
+    myBreakers[name] = cb
+    myBreakersMu.Unlock()
+    return cb
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get the circuit breaker of the named connection, or nil.
+//////////////////////////////////////////////////////////////////////
+func Breaker(name string) *CircuitBreaker {
+    myBreakersMu.RLock()
+    defer myBreakersMu.RUnlock()
+    return myBreakers[name]
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get the current state.
+//////////////////////////////////////////////////////////////////////
+func (cb *CircuitBreaker) State() string {
+    cb.mu.Lock()
+    defer cb.mu.Unlock()
+    if cb.state == CIRCUIT_OPEN && time.Since(cb.openedAt) >= cb.o.OpenTimeout {
+        return CIRCUIT_HALF_OPEN
+    }
+    return cb.state
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get an interceptor which rejects the calls while the breaker is open.
+//////////////////////////////////////////////////////////////////////
+func (cb *CircuitBreaker) Interceptor() Interceptor {
+    return func(ctx context.Context, call *Call, next Handler) error {
+        if call.Op == OP_COMMIT || call.Op == OP_ROLLBACK {
+            err := next(ctx, call)
+            cb.done(false, err)
+            return err
+        }
+        probe, err := cb.allow()
+        if err != nil {
+            return err
+        }
+        err = next(ctx, call)
+        cb.done(probe, err)
+        return err
+    }
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Check whether a call would be let through now, without running one.
+// The error is a *CircuitOpenError.
+//////////////////////////////////////////////////////////////////////
+func (cb *CircuitBreaker) Allow() error {
+    cb.mu.Lock()
+    defer cb.mu.Unlock()
+    if cb.state == CIRCUIT_OPEN {
+        if wait := cb.o.OpenTimeout - time.Since(cb.openedAt); wait > 0 {
+            return &CircuitOpenError{Name: cb.name, RetryAfter: wait}
+        }
+        return nil
+    }
+    if cb.state == CIRCUIT_HALF_OPEN && cb.probes >= cb.o.HalfOpenProbes {
+        return &CircuitOpenError{Name: cb.name}
+    }
+    return nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Check whether a call may run. probe is true when it runs as a probe
+// of the half-open breaker.
+//////////////////////////////////////////////////////////////////////
+func (cb *CircuitBreaker) allow() (bool, error) {
+    cb.mu.Lock()
+    defer cb.unlock()
+    if cb.state == CIRCUIT_OPEN {
+        wait := cb.o.OpenTimeout - time.Since(cb.openedAt)
+        if wait > 0 {
+            return false, &CircuitOpenError{Name: cb.name, RetryAfter: wait}
+        }
+        cb.setState(CIRCUIT_HALF_OPEN)
+    }
+    if cb.state == CIRCUIT_HALF_OPEN {
+        if cb.probes >= cb.o.HalfOpenProbes {
+            return false, &CircuitOpenError{Name: cb.name}
+        }
+        cb.probes++
+        return true, nil
+    }
+    return false, nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Record the result of a call.
+//////////////////////////////////////////////////////////////////////
+func (cb *CircuitBreaker) done(probe bool, err error) {
+    failed := err != nil && cb.o.IsFailure(err)
+    cb.mu.Lock()
+    defer cb.unlock()
+    if probe {
+        cb.probes--
+    }
+    if cb.state == CIRCUIT_HALF_OPEN {
+        if !probe {
+            return
+        }
+        if failed {
+            cb.open()
+        } else {
+            cb.reset()
+            cb.setState(CIRCUIT_CLOSED)
+        }
+        return
+    }
+    if cb.state != CIRCUIT_CLOSED {
+        return
+    }
+
+    now := time.Now()
+    if now.Sub(cb.windowStart) >= cb.o.Window {
+        cb.windowStart = now
+        cb.windowCalls = 0
+        cb.windowFailures = 0
+    }
+    cb.windowCalls++
+    if !failed {
+        cb.failures = 0
+        return
+    }
+    cb.failures++
+    cb.windowFailures++
+    if cb.o.MaxFailures > 0 && cb.failures >= cb.o.MaxFailures {
+        cb.open()
+        return
+    }
+    if cb.o.FailureRate > 0 && cb.windowCalls >= cb.o.MinRequests &&
+            float64(cb.windowFailures) / float64(cb.windowCalls) >= cb.o.FailureRate {
+        cb.open()
+    }
+}
+
+func (cb *CircuitBreaker) open() {
+    cb.reset()
+    cb.openedAt = time.Now()
+    cb.setState(CIRCUIT_OPEN)
+}
+
+func (cb *CircuitBreaker) reset() {
+    cb.failures = 0
+    cb.windowStart = time.Time{}
+    cb.windowCalls = 0
+    cb.windowFailures = 0
+}
+
+func (cb *CircuitBreaker) setState(state string) {
+    if cb.state == state {
+        return
+    }
+    if cb.o.OnStateChange != nil {
+        cb.changes = append(cb.changes, [2]string{cb.state, state})
+    }
+    cb.state = state
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Unlock and report the state changes in order.
+//////////////////////////////////////////////////////////////////////
+func (cb *CircuitBreaker) unlock() {
+    changes := cb.changes
+    cb.changes = nil
+    cb.mu.Unlock()
+    for _, change := range changes {
+        cb.o.OnStateChange(cb.name, change[0], change[1])
+    }
+}
+
+
+func (e *CircuitOpenError) Error() string {
+    if e.RetryAfter > 0 {
+        return fmt.Sprintf("%s: %s (retry after %s)", e.Name, ErrCircuitOpen, e.RetryAfter.Round(time.Millisecond))
+    }
+    return fmt.Sprintf("%s: %s", e.Name, ErrCircuitOpen)
+}
+
+func (e *CircuitOpenError) Is(target error) bool {
+    return target == ErrCircuitOpen
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Check whether the error means that the server can not be reached or
+// the connection is broken. A canceled or timed out context is not:
+// the caller gave up, which says nothing about the server.
+//////////////////////////////////////////////////////////////////////
+func IsConnectionError(err error) bool {
+    if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+        return false
+    }
+    if errors.Is(err, driver.ErrBadConn) || errors.Is(err, gomysql.ErrInvalidConn) ||
+            errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+            errors.Is(err, syscall.EPIPE) {
+        return true
+    }
+    var netErr net.Error
+    if errors.As(err, &netErr) {
+        return true
+    }
+    var mysqlErr *gomysql.MySQLError
+    if errors.As(err, &mysqlErr) {
+        switch mysqlErr.Number {
+        // ER_CON_COUNT_ERROR, ER_SERVER_SHUTDOWN, ER_NET_READ_ERROR, ER_NET_WRITE_INTERRUPTED,
+        // ER_CLIENT_INTERACTION_TIMEOUT, ER_SERVER_OFFLINE_MODE
+        case 1040, 1053, 1158, 1161, 4031, 3032:
+            return true
+        }
+    }
+    return false
+}
@@ -0,0 +1,215 @@
+package mysql_test
+
+import (
+    "context"
+    "database/sql/driver"
+    "errors"
+    "fmt"
+    "net"
+    "strings"
+    "sync"
+    "syscall"
+    "testing"
+    "time"
+    gomysql "github.com/go-sql-driver/mysql"
+    myMySQL "mysql"
+    "mysql/mysqltest"
+)
+
+type breakerTest struct {
+    breaker *myMySQL.CircuitBreaker
+    intercept myMySQL.Interceptor
+    mu sync.Mutex
+    changes []string
+}
+
+func newBreakerTest(t *testing.T, opts myMySQL.BreakerOptions) *breakerTest {
+    bt := &breakerTest{}
+    opts.OnStateChange = func(name, from, to string) {
+        bt.mu.Lock()
+        bt.changes = append(bt.changes, from + "->" + to)
+        bt.mu.Unlock()
+    }
+    bt.breaker = myMySQL.NewCircuitBreaker(t.Name(), &opts)
+    bt.intercept = bt.breaker.Interceptor()
+    return bt
+}
+
+func (bt *breakerTest) call(op string, result error) error {
+    return bt.intercept(context.Background(), &myMySQL.Call{Op: op}, func(ctx context.Context, call *myMySQL.Call) error {
+        return result
+    })
+}
+
+func (bt *breakerTest) stateChanges() string {
+    bt.mu.Lock()
+    defer bt.mu.Unlock()
+    return strings.Join(bt.changes, ",")
+}
+
+func TestCircuitBreakerConsecutiveFailures(t *testing.T) {
+    bt := newBreakerTest(t, myMySQL.BreakerOptions{MaxFailures: 3, OpenTimeout: 50 * time.Millisecond})
+
+    bt.call(myMySQL.OP_QUERY, driver.ErrBadConn)
+    bt.call(myMySQL.OP_QUERY, driver.ErrBadConn)
+    // A success resets the count, and a query error is not a failure.
+    bt.call(myMySQL.OP_QUERY, nil)
+    bt.call(myMySQL.OP_QUERY, &gomysql.MySQLError{Number: 1062})
+    // The caller gave up, which is not a failure either.
+    bt.call(myMySQL.OP_QUERY, context.Canceled)
+    bt.call(myMySQL.OP_QUERY, driver.ErrBadConn)
+    if state := bt.breaker.State(); state != myMySQL.CIRCUIT_CLOSED {
+        t.Fatalf("state = %s, want %s", state, myMySQL.CIRCUIT_CLOSED)
+    }
+    bt.call(myMySQL.OP_CONNECT, driver.ErrBadConn)
+    bt.call(myMySQL.OP_CONNECT, driver.ErrBadConn)
+    if state := bt.breaker.State(); state != myMySQL.CIRCUIT_OPEN {
+        t.Fatalf("state = %s, want %s", state, myMySQL.CIRCUIT_OPEN)
+    }
+
+    err := bt.call(myMySQL.OP_QUERY, nil)
+    var openErr *myMySQL.CircuitOpenError
+    if !errors.Is(err, myMySQL.ErrCircuitOpen) || !errors.As(err, &openErr) || openErr.RetryAfter <= 0 {
+        t.Errorf("call while open = %v, want %v with RetryAfter", err, myMySQL.ErrCircuitOpen)
+    }
+    if err := bt.breaker.Allow(); !errors.Is(err, myMySQL.ErrCircuitOpen) {
+        t.Errorf("Allow() while open = %v, want %v", err, myMySQL.ErrCircuitOpen)
+    }
+    // Commit and rollback are never rejected.
+    if err := bt.call(myMySQL.OP_COMMIT, nil); err != nil {
+        t.Errorf("commit while open = %v", err)
+    }
+
+    time.Sleep(60 * time.Millisecond)
+    if state := bt.breaker.State(); state != myMySQL.CIRCUIT_HALF_OPEN {
+        t.Fatalf("state = %s, want %s", state, myMySQL.CIRCUIT_HALF_OPEN)
+    }
+    if err := bt.breaker.Allow(); err != nil {
+        t.Errorf("Allow() after OpenTimeout = %v", err)
+    }
+    // A failed probe opens it again.
+    if err := bt.call(myMySQL.OP_QUERY, driver.ErrBadConn); err != driver.ErrBadConn {
+        t.Errorf("probe = %v, want %v", err, driver.ErrBadConn)
+    }
+    if state := bt.breaker.State(); state != myMySQL.CIRCUIT_OPEN {
+        t.Fatalf("state = %s, want %s", state, myMySQL.CIRCUIT_OPEN)
+    }
+
+    time.Sleep(60 * time.Millisecond)
+    if err := bt.call(myMySQL.OP_QUERY, nil); err != nil {
+        t.Errorf("probe = %v", err)
+    }
+    if state := bt.breaker.State(); state != myMySQL.CIRCUIT_CLOSED {
+        t.Fatalf("state = %s, want %s", state, myMySQL.CIRCUIT_CLOSED)
+    }
+    want := "closed->open,open->half-open,half-open->open,open->half-open,half-open->closed"
+    if changes := bt.stateChanges(); changes != want {
+        t.Errorf("state changes = %s, want %s", changes, want)
+    }
+}
+
+func TestCircuitBreakerHalfOpenProbes(t *testing.T) {
+    bt := newBreakerTest(t, myMySQL.BreakerOptions{MaxFailures: 1, OpenTimeout: 20 * time.Millisecond})
+    bt.call(myMySQL.OP_QUERY, driver.ErrBadConn)
+    time.Sleep(30 * time.Millisecond)
+
+    release := make(chan struct{})
+    started := make(chan struct{})
+    done := make(chan error)
+    go func() {
+        done <- bt.intercept(context.Background(), &myMySQL.Call{Op: myMySQL.OP_QUERY}, func(ctx context.Context, call *myMySQL.Call) error {
+            close(started)
+            <-release
+            return nil
+        })
+    }()
+    <-started
+    // The only probe is running.
+    if err := bt.call(myMySQL.OP_QUERY, nil); !errors.Is(err, myMySQL.ErrCircuitOpen) {
+        t.Errorf("second probe = %v, want %v", err, myMySQL.ErrCircuitOpen)
+    }
+    if err := bt.breaker.Allow(); !errors.Is(err, myMySQL.ErrCircuitOpen) {
+        t.Errorf("Allow() during the probe = %v, want %v", err, myMySQL.ErrCircuitOpen)
+    }
+    close(release)
+    if err := <-done; err != nil {
+        t.Errorf("probe = %v", err)
+    }
+    if state := bt.breaker.State(); state != myMySQL.CIRCUIT_CLOSED {
+        t.Errorf("state = %s, want %s", state, myMySQL.CIRCUIT_CLOSED)
+    }
+}
+
+func TestCircuitBreakerFailureRate(t *testing.T) {
+    bt := newBreakerTest(t, myMySQL.BreakerOptions{MaxFailures: -1, FailureRate: 0.5, MinRequests: 4, Window: time.Minute})
+    for _, err := range []error{driver.ErrBadConn, nil, driver.ErrBadConn} {
+        bt.call(myMySQL.OP_QUERY, err)
+    }
+    if state := bt.breaker.State(); state != myMySQL.CIRCUIT_CLOSED {
+        t.Fatalf("state before MinRequests = %s, want %s", state, myMySQL.CIRCUIT_CLOSED)
+    }
+    bt.call(myMySQL.OP_QUERY, nil)
+    bt.call(myMySQL.OP_QUERY, driver.ErrBadConn)
+    if state := bt.breaker.State(); state != myMySQL.CIRCUIT_OPEN {
+        t.Errorf("state at 3 of 5 failed = %s, want %s", state, myMySQL.CIRCUIT_OPEN)
+    }
+    if myMySQL.Breaker(t.Name()) != bt.breaker {
+        t.Errorf("Breaker(%q) is not the created breaker", t.Name())
+    }
+}
+
+func TestIsConnectionError(t *testing.T) {
+    tests := []struct {
+        err error
+        want bool
+    }{
+        {nil, false},
+        {driver.ErrBadConn, true},
+        {fmt.Errorf("query: %w", gomysql.ErrInvalidConn), true},
+        {&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, true},
+        {&gomysql.MySQLError{Number: 1040}, true},
+        {&gomysql.MySQLError{Number: 1062}, false},
+        {context.Canceled, false},
+        {context.DeadlineExceeded, false},
+        {fmt.Errorf("query: %w", context.DeadlineExceeded), false},
+        {errors.New("syntax error"), false},
+    }
+    for _, tt := range tests {
+        if got := myMySQL.IsConnectionError(tt.err); got != tt.want {
+            t.Errorf("IsConnectionError(%v) = %v, want %v", tt.err, got, tt.want)
+        }
+    }
+}
+
+func TestCircuitBreakerConnect(t *testing.T) {
+    _, mock := mysqltest.NewT(t)
+    mock.ExpectQuery("SELECT 1").WillReturnError(syscall.ECONNREFUSED)
+    breaker := myMySQL.NewCircuitBreaker(t.Name(), &myMySQL.BreakerOptions{MaxFailures: 1, OpenTimeout: time.Minute})
+    var connects int
+    countConnects := func(ctx context.Context, call *myMySQL.Call, next myMySQL.Handler) error {
+        if call.Op == myMySQL.OP_CONNECT {
+            connects++
+        }
+        return next(ctx, call)
+    }
+    db, _, err := myMySQL.OpenDB(mysqltest.DRIVER_NAME, mock.DSN(), breaker.Interceptor(), countConnects)
+    if err != nil {
+        t.Fatalf("mysql.OpenDB() error: %s", err)
+    }
+    defer db.Close()
+    // Every call connects.
+    db.SetMaxIdleConns(0)
+
+    if _, err := db.Query("SELECT 1"); !errors.Is(err, syscall.ECONNREFUSED) {
+        t.Fatalf("Query() error = %v, want %v", err, syscall.ECONNREFUSED)
+    }
+    if state := breaker.State(); state != myMySQL.CIRCUIT_OPEN {
+        t.Fatalf("State() = %s, want %s", state, myMySQL.CIRCUIT_OPEN)
+    }
+    if _, err := db.Query("SELECT 1"); !errors.Is(err, myMySQL.ErrCircuitOpen) {
+        t.Errorf("Query() while open error = %v, want %v", err, myMySQL.ErrCircuitOpen)
+    }
+    if connects != 1 {
+        t.Errorf("%d connects reached the driver, want 1", connects)
+    }
+}
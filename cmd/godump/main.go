@@ -0,0 +1,159 @@
+//////////////////////////////////////////////////////////////////////
+// main.go
+//
+// @usage
+//
+//     Dump tables without the MySQL client binaries.
+//
+//         --------------------------------------------------
+//         godump -dsn "DB_USER:DB_PASS@tcp(127.0.0.1:3306)/DB_NAME" -tables countries -gzip -o backup.sql.gz
+//         --------------------------------------------------
+//
+//     Dump production data with masked columns (see mask.go for the config).
+//
+//         --------------------------------------------------
+//         godump -dsn "DB_USER:DB_PASS@tcp(127.0.0.1:3306)/DB_NAME" -mask mask.json -o masked.sql
+//         --------------------------------------------------
+//
+//     Restore a dump.
+//
+//         --------------------------------------------------
+//         godump -dsn "DB_USER:DB_PASS@tcp(127.0.0.1:3306)/DB_NAME" -restore backup.sql.gz
+//         --------------------------------------------------
+//
+//
+// MIT License
+//
+// Copyright (c) 2019 noknow.info
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+// PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+// HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+// OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTW//ARE.
+//////////////////////////////////////////////////////////////////////
+package main
+
+import (
+    "context"
+    "errors"
+    "flag"
+    "fmt"
+    "io"
+    "log"
+    "os"
+    "strings"
+    myMySQL "mysql"
+)
+
+type options struct {
+    dsn string
+    tables string
+    output string
+    compress bool
+    rowsPerInsert int
+    mask string
+    restore string
+}
+
+func main() {
+    o := options{}
+    flag.StringVar(&o.dsn, "dsn", "", "data source name")
+    flag.StringVar(&o.tables, "tables", "", "comma separated tables to dump (default: all)")
+    flag.StringVar(&o.output, "o", "", "output file (default: stdout)")
+    flag.BoolVar(&o.compress, "gzip", false, "compress the output with gzip")
+    flag.IntVar(&o.rowsPerInsert, "rows-per-insert", myMySQL.DEFAULT_ROWS_PER_INSERT, "number of rows in one INSERT statement")
+    flag.StringVar(&o.mask, "mask", "", "masking config file (JSON)")
+    flag.StringVar(&o.restore, "restore", "", "restore the dump file instead of dumping")
+    flag.Parse()
+
+    if o.dsn == "" {
+        log.Fatalf("[FATAL] -dsn is required\n")
+    }
+    // The deferred closes of run() are done before exiting.
+    if err := run(&o); err != nil {
+        log.Printf("[ERROR] %s\n", err)
+        os.Exit(1)
+    }
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Dump or restore. The output file is complete only when it returns nil.
+//////////////////////////////////////////////////////////////////////
+func run(o *options) (err error) {
+    myMySQL.Init(o.dsn)
+    defer myMySQL.Close()
+    ctx := context.Background()
+
+    if o.restore != "" {
+        f, err := os.Open(o.restore)
+        if err != nil {
+            return fmt.Errorf("os.Open() error: %w", err)
+        }
+        defer f.Close()
+        err = myMySQL.Restore(ctx, myMySQL.Conn(), f, &myMySQL.RestoreOptions{
+            Progress: func(p myMySQL.RestoreProgress) {
+                if p.Statements % 100 == 0 {
+                    log.Printf("[INFO] %d statements, %d bytes\n", p.Statements, p.Bytes)
+                }
+            },
+        })
+        if err != nil {
+            return fmt.Errorf("myMySQL.Restore() error: %w", err)
+        }
+        return nil
+    }
+
+    opts := &myMySQL.DumpOptions{
+        Compress: o.compress,
+        RowsPerInsert: o.rowsPerInsert,
+    }
+    if o.mask != "" {
+        f, err := os.Open(o.mask)
+        if err != nil {
+            return fmt.Errorf("os.Open() error: %w", err)
+        }
+        config, err := myMySQL.LoadMaskConfig(f)
+        f.Close()
+        if err != nil {
+            return fmt.Errorf("myMySQL.LoadMaskConfig() error: %w", err)
+        }
+        if opts.Masker, err = myMySQL.NewMasker(config); err != nil {
+            return fmt.Errorf("myMySQL.NewMasker() error: %w", err)
+        }
+    }
+    if o.tables != "" {
+        opts.Tables = strings.Split(o.tables, ",")
+    }
+
+    var w io.Writer = os.Stdout
+    if o.output != "" {
+        f, err := os.Create(o.output)
+        if err != nil {
+            return fmt.Errorf("os.Create() error: %w", err)
+        }
+        defer func() {
+            // e.g. a full disk is reported on close.
+            if closeErr := f.Close(); closeErr != nil {
+                err = errors.Join(err, fmt.Errorf("(*os.File) Close() error: %w", closeErr))
+            }
+        }()
+        w = f
+    }
+    if err := myMySQL.Dump(ctx, myMySQL.Conn(), w, opts); err != nil {
+        return fmt.Errorf("myMySQL.Dump() error: %w", err)
+    }
+    return nil
+}
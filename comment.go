@@ -0,0 +1,231 @@
+//////////////////////////////////////////////////////////////////////
+// comment.go
+//
+// @usage
+//
+//     1. Import this package.
+//
+//         --------------------------------------------------
+//         import myMySQL "mysql"
+//         --------------------------------------------------
+//
+//     2. Register the annotator before Init().
+//
+//         --------------------------------------------------
+//         annotator := myMySQL.NewAnnotator(&myMySQL.AnnotateOptions{
+//             Application: "api",
+//             TraceParent: otelmysql.TraceParent,
+//         })
+//         myMySQL.Use(annotator.Interceptor())
+//         myMySQL.Init(datasourceName)
+//         --------------------------------------------------
+//
+//        The queries are sent with a sqlcommenter style comment, e.g.
+//
+//         /*application='api',caller='main.listCountries',file='main.go%3A42',traceparent='00-...-01'*/ SELECT ...
+//
+//     3. Turn it off and on at run time.
+//
+//         --------------------------------------------------
+//         annotator.SetEnabled(false)
+//         --------------------------------------------------
+//
+//     The keys and the values are URL encoded, so they can not close the
+//     comment. Statements which are already prepared are not changed, so a
+//     statement shared by StmtCache keeps the comment (caller, traceparent)
+//     of the call which prepared it.
+//
+//     The caller is the first function outside of database/sql and this
+//     module, e.g. the function which called countries.Select().
+//
+//
+// MIT License
+//
+// Copyright (c) 2019 noknow.info
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+// PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+// HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+// OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTW//ARE.
+//////////////////////////////////////////////////////////////////////
+package mysql
+
+import (
+    "context"
+    "fmt"
+    "net/url"
+    "path/filepath"
+    "runtime"
+    "sort"
+    "strings"
+    "sync/atomic"
+)
+
+const (
+    COMMENT_KEY_APPLICATION = "application"
+    COMMENT_KEY_CALLER = "caller"
+    COMMENT_KEY_FILE = "file"
+    COMMENT_KEY_TRACEPARENT = "traceparent"
+
+    MAX_CALLER_DEPTH = 64
+)
+
+var (
+    // Import path of this package, e.g. "mysql".
+    myPackagePath = func() string {
+        pc, _, _, _ := runtime.Caller(0)
+        name := runtime.FuncForPC(pc).Name()
+        // Strip the function name after the last path element.
+        slash := strings.LastIndex(name, "/")
+        return name[:slash + 1 + strings.Index(name[slash + 1:], ".")]
+    }()
+)
+
+type AnnotateOptions struct {
+    // Value of the application key.
+    Application string
+    // Returns the W3C traceparent of the context, or "".
+    TraceParent func(ctx context.Context) string
+    // Do not add the caller and the file keys.
+    DisableCaller bool
+    // Added to every comment.
+    Tags map[string]string
+}
+
+type Annotator struct {
+    o AnnotateOptions
+    disabled atomic.Bool
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Create an annotator. It is enabled.
+//////////////////////////////////////////////////////////////////////
+func NewAnnotator(opts *AnnotateOptions) *Annotator {
+    a := &Annotator{}
+    if opts != nil {
+        a.o = *opts
+    }
+    return a
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Turn the annotation on or off.
+//////////////////////////////////////////////////////////////////////
+func (a *Annotator) SetEnabled(enabled bool) {
+    a.disabled.Store(!enabled)
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get an interceptor which prefixes the queries with the comment.
+//////////////////////////////////////////////////////////////////////
+func (a *Annotator) Interceptor() Interceptor {
+    return func(ctx context.Context, call *Call, next Handler) error {
+        if a.disabled.Load() || call.Prepared || call.Query == "" {
+            return next(ctx, call)
+        }
+        switch call.Op {
+        case OP_QUERY, OP_EXEC, OP_PREPARE:
+            if comment := a.Comment(ctx); comment != "" {
+                call.Query = comment + " " + call.Query
+            }
+        }
+        return next(ctx, call)
+    }
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Build the comment for a query issued in the context by the caller
+// of database/sql.
+//////////////////////////////////////////////////////////////////////
+func (a *Annotator) Comment(ctx context.Context) string {
+    tags := make(map[string]string, len(a.o.Tags) + 4)
+    for key, value := range a.o.Tags {
+        tags[key] = value
+    }
+    if a.o.Application != "" {
+        tags[COMMENT_KEY_APPLICATION] = a.o.Application
+    }
+    if a.o.TraceParent != nil {
+        if traceparent := a.o.TraceParent(ctx); traceparent != "" {
+            tags[COMMENT_KEY_TRACEPARENT] = traceparent
+        }
+    }
+    if !a.o.DisableCaller {
+        if frame, ok := callerFrame(); ok {
+            tags[COMMENT_KEY_CALLER] = frame.Function
+            tags[COMMENT_KEY_FILE] = fmt.Sprintf("%s:%d", filepath.Base(frame.File), frame.Line)
+        }
+    }
+    return FormatComment(tags)
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Format the tags as a sqlcommenter comment. The keys are sorted.
+//////////////////////////////////////////////////////////////////////
+func FormatComment(tags map[string]string) string {
+    if len(tags) == 0 {
+        return ""
+    }
+    keys := make([]string, 0, len(tags))
+    for key := range tags {
+        keys = append(keys, key)
+    }
+    sort.Strings(keys)
+    pairs := make([]string, len(keys))
+    for i, key := range keys {
+        pairs[i] = escapeCommentPart(key) + "='" + escapeCommentPart(tags[key]) + "'"
+    }
+    return "/*" + strings.Join(pairs, ",") + "*/"
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// URL encode the key or the value. Quotes, "*" and "/" are encoded too.
+//////////////////////////////////////////////////////////////////////
+func escapeCommentPart(s string) string {
+    return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get the first frame outside of database/sql and this module which
+// called into database/sql.
+//////////////////////////////////////////////////////////////////////
+func callerFrame() (runtime.Frame, bool) {
+    pcs := make([]uintptr, MAX_CALLER_DEPTH)
+    n := runtime.Callers(3, pcs)
+    frames := runtime.CallersFrames(pcs[:n])
+    inSQL := false
+    for {
+        frame, more := frames.Next()
+        if strings.HasPrefix(frame.Function, "database/sql.") {
+            inSQL = true
+        } else if inSQL && !inModule(frame.Function) {
+            return frame, true
+        }
+        if !more {
+            return runtime.Frame{}, false
+        }
+    }
+}
+
+func inModule(function string) bool {
+    return strings.HasPrefix(function, myPackagePath + ".") || strings.HasPrefix(function, myPackagePath + "/")
+}
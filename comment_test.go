@@ -0,0 +1,54 @@
+package mysql_test
+
+import (
+    "context"
+    "strings"
+    "sync"
+    "testing"
+    myMySQL "mysql"
+    "mysql/mysqltest/embedded"
+)
+
+func TestAnnotatorCaller(t *testing.T) {
+    var mu sync.Mutex
+    var queries []string
+    capture := func(ctx context.Context, call *myMySQL.Call, next myMySQL.Handler) error {
+        if call.Op == myMySQL.OP_QUERY || call.Op == myMySQL.OP_PREPARE {
+            mu.Lock()
+            queries = append(queries, call.Query)
+            mu.Unlock()
+        }
+        return next(ctx, call)
+    }
+    annotator := myMySQL.NewAnnotator(&myMySQL.AnnotateOptions{Application: "api"})
+    db, _, err := myMySQL.OpenDB("mysql", embedded.NewDSN(t, nil), annotator.Interceptor(), capture)
+    if err != nil {
+        t.Fatalf("mysql.OpenDB() error: %s", err)
+    }
+    defer db.Close()
+    stmts := myMySQL.NewStmtCache(db, nil)
+    defer stmts.Close()
+
+    rows, err := db.QueryContext(context.Background(), "SELECT 1")
+    if err != nil {
+        t.Fatalf("db.QueryContext() error: %s", err)
+    }
+    rows.Close()
+    // Called through StmtCache, which is in this module.
+    rows, err = stmts.QueryContext(context.Background(), "SELECT 2")
+    if err != nil {
+        t.Fatalf("stmts.QueryContext() error: %s", err)
+    }
+    rows.Close()
+
+    mu.Lock()
+    defer mu.Unlock()
+    if len(queries) < 2 {
+        t.Fatalf("queries = %q", queries)
+    }
+    for _, query := range queries {
+        if !strings.HasPrefix(query, "/*application='api',caller='mysql_test.TestAnnotatorCaller',file='comment_test.go%3A") {
+            t.Errorf("query = %q, want the caller in the test", query)
+        }
+    }
+}
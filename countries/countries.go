@@ -40,6 +40,27 @@
 //             allCountries := myCountries.GetAfricaOnlyActive(langCode)
 //             --------------------------------------------------
 //
+//         4-3. When you would like to export all countries in all languages.
+//
+//             --------------------------------------------------
+//             err := myCountries.Export(os.Stdout, myMySQL.FORMAT_CSV)
+//             --------------------------------------------------
+//
+//         4-4. When you would like to page through countries with a cursor.
+//
+//             --------------------------------------------------
+//             paginator, err := myMySQL.NewPaginator(secret)
+//             sort := []myMySQL.SortKey{{Column: "en"}}
+//             countries, page, err := myCountries.SelectPage(paginator, myCountries.Columns{Status: 1}, "en", sort, 20, "")
+//             nextCountries, page, err := myCountries.SelectPage(paginator, myCountries.Columns{Status: 1}, "en", sort, 20, page.Next)
+//             --------------------------------------------------
+//
+//             Status 0 selects the inactive countries, as in Select(). Set STATUS_ANY to select any status.
+//
+//             --------------------------------------------------
+//             allCountries, page, err := myCountries.SelectPage(paginator, myCountries.Columns{Status: myCountries.STATUS_ANY}, "en", sort, 20, "")
+//             --------------------------------------------------
+//
 //
 // MIT License
 //
@@ -65,18 +86,26 @@
 package countries
 
 import (
+    "context"
     "database/sql"
+    "io"
     "log"
-    "strconv"
+    "strings"
     _ "github.com/go-sql-driver/mysql"
+    myMySQL "mysql"
 )
 
 const (
     TABLE_NAME = "countries"
+    // Columns.Status of Select() and SelectPage() to select any status.
+    STATUS_ANY = -1
 )
 
 var (
     db *sql.DB
+    stmts *myMySQL.StmtCache
+    // Also the columns allowed in ORDER BY.
+    columnNames = []string{"country_code", "ar", "de", "en", "es", "fr", "ja", "pt", "ru", "zh_cn", "zh_tw", "continent", "status"}
 )
 
 type Columns struct {
@@ -98,6 +127,7 @@ type Columns struct {
 
 func Init(mydb *sql.DB) {
     db = mydb
+    stmts = myMySQL.NewStmtCache(db, nil)
     query := "CREATE TABLE IF NOT EXISTS " + TABLE_NAME +
             "(country_code VARCHAR(2) NOT NULL COMMENT 'Country code of 2 digits'," +
             "ar VARCHAR(255) NOT NULL COMMENT 'Arabic'," +
@@ -383,139 +413,159 @@ func Init(mydb *sql.DB) {
 
 
 //////////////////////////////////////////////////////////////////////
-// Select
+// Select. orderby is one of ColumnNames(), and Status STATUS_ANY
+// selects any status.
 //////////////////////////////////////////////////////////////////////
 func Select(columns Columns, langCode string, orderby string, orderDesc bool, limit int, offset int) []Columns {
     var result []Columns
+    if !isColumn(orderby) {
+        // The query is the key of the statement cache.
+        log.Printf("[ERROR] [countries] unknown orderby column: %s\n", orderby)
+        return result
+    }
     whereFlag := false
+    args := []interface{}{}
     bufferQuery := make([]byte, 0)
     query := "SELECT * FROM " + TABLE_NAME
     bufferQuery = append(bufferQuery, query...)
 
     if columns.CountryCode != "" {
-        query = " WHERE country_code='" + columns.CountryCode + "'"
+        query = " WHERE country_code=?"
         bufferQuery = append(bufferQuery, query...)
+        args = append(args, columns.CountryCode)
         whereFlag = true
     }
 
     if columns.Ar != "" {
         if whereFlag {
-            query = " AND ar='" + columns.Ar + "'"
+            query = " AND ar=?"
         } else {
-            query = " WHERE ar='" + columns.Ar + "'"
+            query = " WHERE ar=?"
             whereFlag = true
         }
         bufferQuery = append(bufferQuery, query...)
+        args = append(args, columns.Ar)
     }
 
     if columns.De != "" {
         if whereFlag {
-            query = " AND de='" + columns.De + "'"
+            query = " AND de=?"
         } else {
-            query = " WHERE de='" + columns.De + "'"
+            query = " WHERE de=?"
             whereFlag = true
         }
         bufferQuery = append(bufferQuery, query...)
+        args = append(args, columns.De)
     }
 
     if columns.En != "" {
         if whereFlag {
-            query = " AND en='" + columns.En + "'"
+            query = " AND en=?"
         } else {
-            query = " WHERE en='" + columns.En + "'"
+            query = " WHERE en=?"
             whereFlag = true
         }
         bufferQuery = append(bufferQuery, query...)
+        args = append(args, columns.En)
     }
 
     if columns.Es != "" {
         if whereFlag {
-            query = " AND es='" + columns.Es + "'"
+            query = " AND es=?"
         } else {
-            query = " WHERE es='" + columns.Es + "'"
+            query = " WHERE es=?"
             whereFlag = true
         }
         bufferQuery = append(bufferQuery, query...)
+        args = append(args, columns.Es)
     }
 
     if columns.Fr != "" {
         if whereFlag {
-            query = " AND fr='" + columns.Fr + "'"
+            query = " AND fr=?"
         } else {
-            query = " WHERE fr='" + columns.Fr + "'"
+            query = " WHERE fr=?"
             whereFlag = true
         }
         bufferQuery = append(bufferQuery, query...)
+        args = append(args, columns.Fr)
     }
 
     if columns.Ja != "" {
         if whereFlag {
-            query = " AND ja='" + columns.Ja + "'"
+            query = " AND ja=?"
         } else {
-            query = " WHERE ja='" + columns.Ja + "'"
+            query = " WHERE ja=?"
             whereFlag = true
         }
         bufferQuery = append(bufferQuery, query...)
+        args = append(args, columns.Ja)
     }
 
     if columns.Pt != "" {
         if whereFlag {
-            query = " AND pt='" + columns.Pt + "'"
+            query = " AND pt=?"
         } else {
-            query = " WHERE pt='" + columns.Pt + "'"
+            query = " WHERE pt=?"
             whereFlag = true
         }
         bufferQuery = append(bufferQuery, query...)
+        args = append(args, columns.Pt)
     }
 
     if columns.Ru != "" {
         if whereFlag {
-            query = " AND ru='" + columns.Ru + "'"
+            query = " AND ru=?"
         } else {
-            query = " WHERE ru='" + columns.Ru + "'"
+            query = " WHERE ru=?"
             whereFlag = true
         }
         bufferQuery = append(bufferQuery, query...)
+        args = append(args, columns.Ru)
     }
 
     if columns.ZhCn != "" {
         if whereFlag {
-            query = " AND zh_cn='" + columns.ZhCn + "'"
+            query = " AND zh_cn=?"
         } else {
-            query = " WHERE zh_cn='" + columns.ZhCn + "'"
+            query = " WHERE zh_cn=?"
             whereFlag = true
         }
         bufferQuery = append(bufferQuery, query...)
+        args = append(args, columns.ZhCn)
     }
 
     if columns.ZhTw != "" {
         if whereFlag {
-            query = " AND zh_tw='" + columns.ZhTw + "'"
+            query = " AND zh_tw=?"
         } else {
-            query = " WHERE zh_tw='" + columns.ZhTw + "'"
+            query = " WHERE zh_tw=?"
             whereFlag = true
         }
         bufferQuery = append(bufferQuery, query...)
+        args = append(args, columns.ZhTw)
     }
 
     if columns.Continent != 0 {
         if whereFlag {
-            query = " AND continent='" + strconv.Itoa(columns.Continent)
+            query = " AND continent=?"
         } else {
-            query = " WHERE continent=" + strconv.Itoa(columns.Continent)
+            query = " WHERE continent=?"
             whereFlag = true
         }
         bufferQuery = append(bufferQuery, query...)
+        args = append(args, columns.Continent)
     }
 
-    if columns.Status == 0 && columns.Status == 1 {
+    if columns.Status != STATUS_ANY {
         if whereFlag {
-            query = " AND status='" + strconv.Itoa(columns.Status)
+            query = " AND status=?"
         } else {
-            query = " WHERE status=" + strconv.Itoa(columns.Status)
+            query = " WHERE status=?"
             whereFlag = true
         }
         bufferQuery = append(bufferQuery, query...)
+        args = append(args, columns.Status)
     }
 
     if orderDesc {
@@ -526,19 +576,23 @@ func Select(columns Columns, langCode string, orderby string, orderDesc bool, li
     bufferQuery = append(bufferQuery, query...)
 
     if limit > 0 && offset > 0 {
-        query = " LIMIT " + strconv.Itoa(offset) + "," + strconv.Itoa(limit)
+        query = " LIMIT ?,?"
+        args = append(args, offset, limit)
     } else if limit > 0 {
-        query = " LIMIT " + strconv.Itoa(limit)
+        query = " LIMIT ?"
+        args = append(args, limit)
     } else if offset > 0 {
-        query = " LIMIT " + strconv.Itoa(offset)
+        // All the rows after offset.
+        query = " LIMIT ?,18446744073709551615"
+        args = append(args, offset)
     } else {
         query = ""
     }
     bufferQuery = append(bufferQuery, query...)
 
-    rows, err := db.Query(string(bufferQuery[:]))
+    rows, err := stmts.QueryContext(context.Background(), string(bufferQuery[:]), args...)
     if err != nil {
-        log.Printf("[ERROR] [posts] db.Query() error: %s\n", err)
+        log.Printf("[ERROR] [countries] stmts.QueryContext() error: %s\n", err)
         return result
     }
 
@@ -603,6 +657,110 @@ func Select(columns Columns, langCode string, orderby string, orderDesc bool, li
 }
 
 
+//////////////////////////////////////////////////////////////////////
+// Select a page with a cursor of the previous page. The country code
+// is added to the sort. Status STATUS_ANY selects any status.
+//////////////////////////////////////////////////////////////////////
+func SelectPage(paginator *myMySQL.Paginator, columns Columns, langCode string, sort []myMySQL.SortKey, limit int, cursor string) ([]Columns, *myMySQL.Page, error) {
+    var conditions []string
+    var args []interface{}
+    filters := []struct {
+        column string
+        value string
+    }{
+        {"country_code", columns.CountryCode},
+        {"ar", columns.Ar},
+        {"de", columns.De},
+        {"en", columns.En},
+        {"es", columns.Es},
+        {"fr", columns.Fr},
+        {"ja", columns.Ja},
+        {"pt", columns.Pt},
+        {"ru", columns.Ru},
+        {"zh_cn", columns.ZhCn},
+        {"zh_tw", columns.ZhTw},
+    }
+    for _, filter := range filters {
+        if filter.value != "" {
+            conditions = append(conditions, filter.column + "=?")
+            args = append(args, filter.value)
+        }
+    }
+    if columns.Continent != 0 {
+        conditions = append(conditions, "continent=?")
+        args = append(args, columns.Continent)
+    }
+    if columns.Status != STATUS_ANY {
+        conditions = append(conditions, "status=?")
+        args = append(args, columns.Status)
+    }
+
+    var result []Columns
+    page, err := paginator.Paginate(context.Background(), stmts, &myMySQL.PageQuery{
+        Table: TABLE_NAME,
+        Columns: columnNames,
+        Where: strings.Join(conditions, " AND "),
+        Args: args,
+        Sort: sort,
+        PrimaryKey: []string{"country_code"},
+        Limit: limit,
+        Cursor: cursor,
+    }, func() []interface{} {
+        result = append(result, Columns{})
+        c := &result[len(result) - 1]
+        return []interface{}{&c.CountryCode, &c.Ar, &c.De, &c.En, &c.Es, &c.Fr, &c.Ja, &c.Pt, &c.Ru, &c.ZhCn, &c.ZhTw, &c.Continent, &c.Status}
+    })
+    if err != nil {
+        return nil, nil, err
+    }
+    for i := range result {
+        result[i].Name = localName(result[i], langCode)
+    }
+    return result, page, nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get the column names of the table.
+//////////////////////////////////////////////////////////////////////
+func ColumnNames() []string {
+    return append([]string{}, columnNames...)
+}
+
+func isColumn(name string) bool {
+    for _, column := range columnNames {
+        if name == column {
+            return true
+        }
+    }
+    return false
+}
+
+func localName(columns Columns, langCode string) string {
+    switch langCode {
+    case "ar":
+        return columns.Ar
+    case "de":
+        return columns.De
+    case "es":
+        return columns.Es
+    case "fr":
+        return columns.Fr
+    case "ja":
+        return columns.Ja
+    case "pt":
+        return columns.Pt
+    case "ru":
+        return columns.Ru
+    case "zh_cn":
+        return columns.ZhCn
+    case "zh_tw":
+        return columns.ZhTw
+    }
+    return columns.En
+}
+
+
 //////////////////////////////////////////////////////////////////////
 // Get only active countries
 //////////////////////////////////////////////////////////////////////
@@ -697,3 +855,13 @@ func GetAntarcticaOnlyActive(langCode string) []Columns {
     return Select(columns, langCode, "country_code", false, 0, 0)
 }
 
+
+//////////////////////////////////////////////////////////////////////
+// Export all countries with the names in all languages.
+// format is one of myMySQL.FORMAT_CSV, FORMAT_JSON, FORMAT_NDJSON or FORMAT_SQL.
+//////////////////////////////////////////////////////////////////////
+func Export(w io.Writer, format string) error {
+    return myMySQL.ExportTable(db, w, TABLE_NAME, &myMySQL.ExportOptions{
+        Format: format,
+    })
+}
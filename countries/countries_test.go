@@ -0,0 +1,135 @@
+package countries_test
+
+import (
+    "errors"
+    "strings"
+    "testing"
+    myMySQL "mysql"
+    myCountries "mysql/countries"
+    "mysql/mysqltest"
+)
+
+const (
+    selectAfricaQuery = "SELECT * FROM countries WHERE continent=? AND status=? ORDER BY country_code ASC"
+)
+
+func countryRows() *mysqltest.Rows {
+    return mysqltest.NewRows("country_code", "ar", "de", "en", "es", "fr", "ja", "pt", "ru", "zh_cn", "zh_tw", "continent", "status")
+}
+
+func TestGetAfricaOnlyActive(t *testing.T) {
+    db, mock := mysqltest.NewT(t)
+    mock.ExpectExecRegexp("^CREATE TABLE IF NOT EXISTS countries").WillReturnResult(0, 0)
+    mock.ExpectExecRegexp("^INSERT IGNORE INTO countries").WillReturnResult(0, 249)
+    mock.ExpectPrepare(selectAfricaQuery)
+    mock.ExpectQuery(selectAfricaQuery).
+        WithArgs(1, 1).
+        WillReturnRows(countryRows().
+            AddRow("ZM", "زامبيا", "Sambia", "Zambia", "Zambia", "Zambie", "ザンビア", "Zâmbia", "Замбия", "尚比亞", "尚比亞", 1, 1).
+            AddRow("ZW", "زيمبابوي", "Simbabwe", "Zimbabwe", "Zimbabue", "Zimbabwe", "ジンバブエ", "Zimbábue", "Зимбабве", "辛巴威", "辛巴威", 1, 1))
+    // The second call reuses the prepared statement.
+    mock.ExpectQuery(selectAfricaQuery).
+        WithArgs(1, 1).
+        WillReturnError(errors.New("read only"))
+
+    myCountries.Init(db)
+    result := myCountries.GetAfricaOnlyActive("de")
+    if len(result) != 2 {
+        t.Fatalf("GetAfricaOnlyActive() = %v, want 2 countries", result)
+    }
+    if result[0].CountryCode != "ZM" || result[0].Name != "Sambia" || result[1].Name != "Simbabwe" {
+        t.Errorf("GetAfricaOnlyActive() = %v", result)
+    }
+    if result := myCountries.GetAfricaOnlyActive("de"); len(result) != 0 {
+        t.Errorf("GetAfricaOnlyActive() on error = %v, want none", result)
+    }
+}
+
+func TestSelectUnknownOrderBy(t *testing.T) {
+    db, mock := mysqltest.NewT(t)
+    mock.ExpectExecRegexp("^CREATE TABLE IF NOT EXISTS countries").WillReturnResult(0, 0)
+    mock.ExpectExecRegexp("^INSERT IGNORE INTO countries").WillReturnResult(0, 249)
+
+    myCountries.Init(db)
+    // No query is expected.
+    if result := myCountries.Select(myCountries.Columns{}, "en", "en; DROP TABLE countries", false, 0, 0); len(result) != 0 {
+        t.Errorf("Select() = %v, want none", result)
+    }
+}
+
+func TestSelectPageStatus(t *testing.T) {
+    selected := "SELECT `country_code`, `" + strings.Join(myCountries.ColumnNames(), "`, `") + "` FROM `countries`"
+    order := " ORDER BY `country_code` ASC LIMIT 3"
+    tests := []struct {
+        name string
+        status int
+        query string
+        args []interface{}
+    }{
+        {"inactive", 0, selected + " WHERE (status=?)" + order, []interface{}{0}},
+        {"active", 1, selected + " WHERE (status=?)" + order, []interface{}{1}},
+        {"any", myCountries.STATUS_ANY, selected + order, nil},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            db, mock := mysqltest.NewT(t)
+            mock.ExpectExecRegexp("^CREATE TABLE IF NOT EXISTS countries").WillReturnResult(0, 0)
+            mock.ExpectExecRegexp("^INSERT IGNORE INTO countries").WillReturnResult(0, 249)
+            mock.ExpectPrepare(tt.query)
+            mock.ExpectQuery(tt.query).
+                WithArgs(tt.args...).
+                WillReturnRows(mysqltest.NewRows(append([]string{"country_code"}, myCountries.ColumnNames()...)...).
+                    AddRow("BI", "BI", "بوروندي", "Burundi", "Burundi", "Burundi", "Burundi", "ブルンジ", "Burundi", "Бурунди", "布隆迪", "蒲隆地", 1, tt.status))
+
+            myCountries.Init(db)
+            paginator, err := myMySQL.NewPaginator([]byte("secret"))
+            if err != nil {
+                t.Fatalf("NewPaginator() error: %s", err)
+            }
+            result, _, err := myCountries.SelectPage(paginator, myCountries.Columns{Status: tt.status}, "en", nil, 2, "")
+            if err != nil {
+                t.Fatalf("SelectPage() error: %s", err)
+            }
+            if len(result) != 1 || result[0].CountryCode != "BI" {
+                t.Errorf("SelectPage() = %v, want BI", result)
+            }
+            if err := mock.ExpectationsWereMet(); err != nil {
+                t.Error(err)
+            }
+        })
+    }
+}
+
+func TestSelectStatus(t *testing.T) {
+    tests := []struct {
+        name string
+        status int
+        query string
+        args []interface{}
+    }{
+        {"inactive", 0, "SELECT * FROM countries WHERE status=? ORDER BY country_code ASC", []interface{}{0}},
+        {"active", 1, "SELECT * FROM countries WHERE status=? ORDER BY country_code ASC", []interface{}{1}},
+        {"any", myCountries.STATUS_ANY, "SELECT * FROM countries ORDER BY country_code ASC", nil},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            db, mock := mysqltest.NewT(t)
+            mock.ExpectExecRegexp("^CREATE TABLE IF NOT EXISTS countries").WillReturnResult(0, 0)
+            mock.ExpectExecRegexp("^INSERT IGNORE INTO countries").WillReturnResult(0, 249)
+            mock.ExpectPrepare(tt.query)
+            mock.ExpectQuery(tt.query).
+                WithArgs(tt.args...).
+                WillReturnRows(countryRows().
+                    AddRow("BI", "بوروندي", "Burundi", "Burundi", "Burundi", "Burundi", "ブルンジ", "Burundi", "Бурунди", "布隆迪", "蒲隆地", 1, tt.status))
+
+            myCountries.Init(db)
+            result := myCountries.Select(myCountries.Columns{Status: tt.status}, "en", "country_code", false, 0, 0)
+            if len(result) != 1 || result[0].CountryCode != "BI" {
+                t.Errorf("Select() = %v, want BI", result)
+            }
+            if err := mock.ExpectationsWereMet(); err != nil {
+                t.Error(err)
+            }
+        })
+    }
+}
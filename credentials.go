@@ -0,0 +1,135 @@
+//////////////////////////////////////////////////////////////////////
+// credentials.go
+//
+// @usage
+//
+//     1. Import this package.
+//
+//         --------------------------------------------------
+//         import myMySQL "mysql"
+//         --------------------------------------------------
+//
+//     2. Create a connector which asks the provider for the credentials
+//        of each new connection, and let the old connections age out.
+//
+//         --------------------------------------------------
+//         connector, err := myMySQL.NewCredentialsConnector(
+//             "DB_USER@tcp(db:3306)/DB_NAME?parseTime=true",
+//             myMySQL.FileCredentials("/run/secrets/db-password"))
+//         if err != nil {
+//             // Error handling.
+//         }
+//         myMySQL.InitConnector(connector)
+//         myMySQL.Conn().SetConnMaxLifetime(15 * time.Minute)
+//         --------------------------------------------------
+//
+//     3. Or write a provider, e.g. for a token based auth plugin.
+//        Such tokens are sent as a clear text password, so add
+//        allowCleartextPasswords=true and tls=true to the DSN.
+//
+//         --------------------------------------------------
+//         provider := func(ctx context.Context) (myMySQL.Credentials, error) {
+//             token, err := fetchToken(ctx)
+//             return myMySQL.Credentials{Password: token}, err
+//         }
+//         --------------------------------------------------
+//
+//     The failover connector takes a provider too (FailoverOptions.Credentials).
+//
+//
+// MIT License
+//
+// Copyright (c) 2019 noknow.info
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+// PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+// HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+// OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTW//ARE.
+//////////////////////////////////////////////////////////////////////
+package mysql
+
+import (
+    "context"
+    "database/sql/driver"
+    "fmt"
+    "os"
+    "strings"
+    gomysql "github.com/go-sql-driver/mysql"
+)
+
+type Credentials struct {
+    // Keeps the user of the DSN when it is empty.
+    User string
+    Password string
+}
+
+type CredentialsProvider func(ctx context.Context) (Credentials, error)
+
+
+//////////////////////////////////////////////////////////////////////
+// Create a connector which gets the credentials from the provider
+// before each new connection.
+//////////////////////////////////////////////////////////////////////
+func NewCredentialsConnector(datasourceName string, provider CredentialsProvider) (driver.Connector, error) {
+    cfg, err := gomysql.ParseDSN(datasourceName)
+    if err != nil {
+        return nil, fmt.Errorf("mysql.ParseDSN() error: %w", err)
+    }
+    if err := applyCredentials(cfg, provider); err != nil {
+        return nil, err
+    }
+    connector, err := gomysql.NewConnector(cfg)
+    if err != nil {
+        return nil, fmt.Errorf("mysql.NewConnector() error: %w", err)
+    }
+    return connector, nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Set the provider to the config of go-sql-driver/mysql.
+//////////////////////////////////////////////////////////////////////
+func applyCredentials(cfg *gomysql.Config, provider CredentialsProvider) error {
+    err := cfg.Apply(gomysql.BeforeConnect(func(ctx context.Context, c *gomysql.Config) error {
+        credentials, err := provider(ctx)
+        if err != nil {
+            return fmt.Errorf("credentials provider error: %w", err)
+        }
+        if credentials.User != "" {
+            c.User = credentials.User
+        }
+        c.Passwd = credentials.Password
+        return nil
+    }))
+    if err != nil {
+        return fmt.Errorf("(*mysql.Config) Apply() error: %w", err)
+    }
+    return nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Create a provider which reads the password from a file, e.g. a
+// rotated secret. The file is read for each new connection.
+//////////////////////////////////////////////////////////////////////
+func FileCredentials(path string) CredentialsProvider {
+    return func(ctx context.Context) (Credentials, error) {
+        data, err := os.ReadFile(path)
+        if err != nil {
+            return Credentials{}, fmt.Errorf("os.ReadFile() error: %w", err)
+        }
+        return Credentials{Password: strings.TrimRight(string(data), "\r\n")}, nil
+    }
+}
@@ -0,0 +1,99 @@
+package mysql_test
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "os"
+    "path/filepath"
+    "strings"
+    "sync"
+    "testing"
+    myMySQL "mysql"
+    "mysql/mysqltest/embedded"
+)
+
+func TestCredentialsConnectorRotation(t *testing.T) {
+    var mu sync.Mutex
+    user := "alice"
+    calls := 0
+    provider := func(ctx context.Context) (myMySQL.Credentials, error) {
+        mu.Lock()
+        defer mu.Unlock()
+        calls++
+        return myMySQL.Credentials{User: user, Password: "secret"}, nil
+    }
+    connector, err := myMySQL.NewCredentialsConnector(embedded.NewDSN(t, nil), provider)
+    if err != nil {
+        t.Fatalf("mysql.NewCredentialsConnector() error: %s", err)
+    }
+    if calls != 0 {
+        t.Errorf("provider called %d times before connecting, want 0", calls)
+    }
+    db := sql.OpenDB(connector)
+    defer db.Close()
+    // Every query connects.
+    db.SetMaxIdleConns(0)
+
+    currentUser := func() string {
+        t.Helper()
+        var current string
+        if err := db.QueryRow("SELECT USER()").Scan(&current); err != nil {
+            t.Fatalf("row.Scan() error: %s", err)
+        }
+        return current
+    }
+    if current := currentUser(); !strings.HasPrefix(current, "alice@") {
+        t.Errorf("USER() = %q, want alice", current)
+    }
+    mu.Lock()
+    user = "bob"
+    mu.Unlock()
+    if current := currentUser(); !strings.HasPrefix(current, "bob@") {
+        t.Errorf("USER() after the rotation = %q, want bob", current)
+    }
+    mu.Lock()
+    defer mu.Unlock()
+    if calls != 2 {
+        t.Errorf("provider called %d times for 2 connections", calls)
+    }
+}
+
+func TestCredentialsConnectorProviderError(t *testing.T) {
+    errSealed := errors.New("vault is sealed")
+    connector, err := myMySQL.NewCredentialsConnector(embedded.NewDSN(t, nil), func(ctx context.Context) (myMySQL.Credentials, error) {
+        return myMySQL.Credentials{}, errSealed
+    })
+    if err != nil {
+        t.Fatalf("mysql.NewCredentialsConnector() error: %s", err)
+    }
+    conn, err := connector.Connect(context.Background())
+    if !errors.Is(err, errSealed) || conn != nil {
+        t.Errorf("Connect() = %v, %v, want the provider error", conn, err)
+    }
+    db := sql.OpenDB(connector)
+    defer db.Close()
+    if err := db.Ping(); !errors.Is(err, errSealed) {
+        t.Errorf("Ping() error = %v, want %v", err, errSealed)
+    }
+}
+
+func TestFileCredentials(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "password")
+    provider := myMySQL.FileCredentials(path)
+    if _, err := provider(context.Background()); err == nil {
+        t.Error("provider() without the file error = nil")
+    }
+    for _, password := range []string{"first", "second"} {
+        if err := os.WriteFile(path, []byte(password + "\n"), 0600); err != nil {
+            t.Fatalf("os.WriteFile() error: %s", err)
+        }
+        credentials, err := provider(context.Background())
+        if err != nil {
+            t.Fatalf("provider() error: %s", err)
+        }
+        if credentials.Password != password || credentials.User != "" {
+            t.Errorf("provider() = %+v, want the password %q", credentials, password)
+        }
+    }
+}
@@ -0,0 +1,462 @@
+//////////////////////////////////////////////////////////////////////
+// dump.go
+//
+// @usage
+//
+//     1. Import this package.
+//
+//         --------------------------------------------------
+//         import myMySQL "mysql"
+//         --------------------------------------------------
+//
+//     2. Dump tables into a file.
+//
+//         --------------------------------------------------
+//         f, _ := os.Create("backup.sql.gz")
+//         defer f.Close()
+//         err := myMySQL.Dump(ctx, myMySQL.Conn(), f, &myMySQL.DumpOptions{
+//             Tables: []string{"countries"},
+//             Compress: true,
+//         })
+//         --------------------------------------------------
+//
+//     3. Restore the file. Gzip compressed files are detected automatically.
+//
+//         --------------------------------------------------
+//         f, _ := os.Open("backup.sql.gz")
+//         defer f.Close()
+//         err := myMySQL.Restore(ctx, myMySQL.Conn(), f, &myMySQL.RestoreOptions{
+//             Progress: func(p myMySQL.RestoreProgress) {
+//                 log.Printf("%d statements, %d bytes\n", p.Statements, p.Bytes)
+//             },
+//         })
+//         --------------------------------------------------
+//
+//
+// MIT License
+//
+// Copyright (c) 2019 noknow.info
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+// PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+// HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+// OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTW//ARE.
+//////////////////////////////////////////////////////////////////////
+package mysql
+
+import (
+    "bufio"
+    "compress/gzip"
+    "context"
+    "database/sql"
+    "database/sql/driver"
+    "errors"
+    "fmt"
+    "io"
+    "strings"
+    "time"
+)
+
+const (
+    DUMP_TIME_LAYOUT = "2006-01-02 15:04:05"
+)
+
+type DumpOptions struct {
+    // Tables to dump. All base tables are dumped when empty.
+    Tables []string
+    // Number of rows in one INSERT statement.
+    RowsPerInsert int
+    // Compress the output with gzip.
+    Compress bool
+    // Do not write DROP TABLE statements.
+    NoDropTable bool
+    // Write the table definitions only.
+    NoData bool
+    // Mask the values of the columns.
+    Masker *Masker
+}
+
+type RestoreOptions struct {
+    // Called after each executed statement.
+    Progress func(p RestoreProgress)
+}
+
+type RestoreProgress struct {
+    Statements int
+    Bytes int64
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Dump the tables as mysqldump compatible SQL.
+// All tables are read in one consistent snapshot.
+//////////////////////////////////////////////////////////////////////
+func Dump(ctx context.Context, db *sql.DB, w io.Writer, opts *DumpOptions) (err error) {
+    o := DumpOptions{}
+    if opts != nil {
+        o = *opts
+    }
+
+    if o.Compress {
+        gz := gzip.NewWriter(w)
+        defer func() {
+            if closeErr := gz.Close(); closeErr != nil {
+                err = errors.Join(err, fmt.Errorf("gz.Close() error: %w", closeErr))
+            }
+        }()
+        w = gz
+    }
+    bw := bufio.NewWriter(w)
+
+    conn, err := snapshotConn(ctx, db)
+    if err != nil {
+        return err
+    }
+    defer closeSnapshotConn(conn)
+    if err := o.Masker.LoadColumnTypes(ctx, conn); err != nil {
+        return err
+    }
+
+    tables := o.Tables
+    if len(tables) == 0 {
+        if tables, err = baseTables(ctx, conn); err != nil {
+            return err
+        }
+    }
+
+    var database sql.NullString
+    if err := conn.QueryRowContext(ctx, "SELECT DATABASE()").Scan(&database); err != nil {
+        return fmt.Errorf("row.Scan() error: %w", err)
+    }
+    fmt.Fprintf(bw, "-- Go MySQL dump\n--\n-- Database: %s\n-- ------------------------------------------------------\n\n", database.String)
+    writeSessionHeader(bw)
+    bw.WriteString("/*!40014 SET @OLD_UNIQUE_CHECKS=@@UNIQUE_CHECKS, UNIQUE_CHECKS=0 */;\n")
+    bw.WriteString("/*!40014 SET @OLD_FOREIGN_KEY_CHECKS=@@FOREIGN_KEY_CHECKS, FOREIGN_KEY_CHECKS=0 */;\n")
+    bw.WriteString("/*!40101 SET @OLD_SQL_MODE=@@SQL_MODE, SQL_MODE='NO_AUTO_VALUE_ON_ZERO' */;\n\n")
+
+    for _, table := range tables {
+        if err := dumpTable(ctx, conn, bw, table, &o); err != nil {
+            return err
+        }
+    }
+
+    bw.WriteString("/*!40101 SET SQL_MODE=@OLD_SQL_MODE */;\n")
+    bw.WriteString("/*!40014 SET FOREIGN_KEY_CHECKS=@OLD_FOREIGN_KEY_CHECKS */;\n")
+    bw.WriteString("/*!40014 SET UNIQUE_CHECKS=@OLD_UNIQUE_CHECKS */;\n")
+    writeSessionFooter(bw)
+    bw.WriteString("\n")
+    fmt.Fprintf(bw, "-- Dump completed on %s\n", time.Now().UTC().Format(DUMP_TIME_LAYOUT))
+
+    return bw.Flush()
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Write the session settings the values were read with: utf8mb4 and
+// the time zone of snapshotConn().
+//////////////////////////////////////////////////////////////////////
+func writeSessionHeader(bw *bufio.Writer) {
+    bw.WriteString("/*!40101 SET @OLD_CHARACTER_SET_CLIENT=@@CHARACTER_SET_CLIENT */;\n")
+    bw.WriteString("/*!40101 SET NAMES utf8mb4 */;\n")
+    bw.WriteString("/*!40103 SET @OLD_TIME_ZONE=@@TIME_ZONE */;\n")
+    bw.WriteString("/*!40103 SET TIME_ZONE='+00:00' */;\n")
+}
+
+func writeSessionFooter(bw *bufio.Writer) {
+    bw.WriteString("/*!40103 SET TIME_ZONE=@OLD_TIME_ZONE */;\n")
+    bw.WriteString("/*!40101 SET CHARACTER_SET_CLIENT=@OLD_CHARACTER_SET_CLIENT */;\n")
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get a connection in a consistent snapshot transaction. The session
+// time zone is UTC, so TIMESTAMP values are dumped as they are stored,
+// and restored by closeSnapshotConn().
+//////////////////////////////////////////////////////////////////////
+func snapshotConn(ctx context.Context, db *sql.DB) (*sql.Conn, error) {
+    conn, err := db.Conn(ctx)
+    if err != nil {
+        return nil, fmt.Errorf("db.Conn() error: %w", err)
+    }
+    if _, err := conn.ExecContext(ctx, "SET SESSION TRANSACTION ISOLATION LEVEL REPEATABLE READ"); err != nil {
+        conn.Close()
+        return nil, fmt.Errorf("conn.ExecContext() error: %w", err)
+    }
+    if _, err := conn.ExecContext(ctx, "SET @mysql_old_time_zone=@@SESSION.time_zone, SESSION time_zone='+00:00'"); err != nil {
+        conn.Close()
+        return nil, fmt.Errorf("conn.ExecContext() error: %w", err)
+    }
+    if _, err := conn.ExecContext(ctx, "START TRANSACTION WITH CONSISTENT SNAPSHOT"); err != nil {
+        conn.Close()
+        return nil, fmt.Errorf("conn.ExecContext() error: %w", err)
+    }
+    return conn, nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// End the snapshot transaction and release the connection.
+//////////////////////////////////////////////////////////////////////
+func closeSnapshotConn(conn *sql.Conn) {
+    conn.ExecContext(context.Background(), "ROLLBACK")
+    if _, err := conn.ExecContext(context.Background(), "SET SESSION time_zone=@mysql_old_time_zone"); err != nil {
+        // Not returned to the pool with the time zone of the dump.
+        conn.Raw(func(interface{}) error {
+            return driver.ErrBadConn
+        })
+    }
+    conn.Close()
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Dump one table.
+//////////////////////////////////////////////////////////////////////
+func dumpTable(ctx context.Context, conn *sql.Conn, bw *bufio.Writer, table string, o *DumpOptions) error {
+    if err := dumpCreateTable(ctx, conn, bw, table, !o.NoDropTable); err != nil {
+        return err
+    }
+    if o.NoData {
+        return nil
+    }
+
+    quoted := QuoteIdentifier(table)
+    fmt.Fprintf(bw, "--\n-- Dumping data for table %s\n--\n\n", quoted)
+    fmt.Fprintf(bw, "LOCK TABLES %s WRITE;\n", quoted)
+    fmt.Fprintf(bw, "/*!40000 ALTER TABLE %s DISABLE KEYS */;\n", quoted)
+    rows, err := conn.QueryContext(ctx, "SELECT * FROM " + quoted)
+    if err != nil {
+        return fmt.Errorf("conn.QueryContext() error: %w", err)
+    }
+    err = ExportRows(rows, bw, &ExportOptions{
+        Format: FORMAT_SQL,
+        Table: table,
+        RowsPerInsert: o.RowsPerInsert,
+        Masker: o.Masker,
+    })
+    rows.Close()
+    if err != nil {
+        return err
+    }
+    fmt.Fprintf(bw, "/*!40000 ALTER TABLE %s ENABLE KEYS */;\n", quoted)
+    bw.WriteString("UNLOCK TABLES;\n\n")
+    return nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Dump the table structure.
+//////////////////////////////////////////////////////////////////////
+func dumpCreateTable(ctx context.Context, conn *sql.Conn, bw *bufio.Writer, table string, dropTable bool) error {
+    var name, createTable string
+    if err := conn.QueryRowContext(ctx, "SHOW CREATE TABLE " + QuoteIdentifier(table)).Scan(&name, &createTable); err != nil {
+        return fmt.Errorf("SHOW CREATE TABLE %s error: %w", table, err)
+    }
+
+    quoted := QuoteIdentifier(table)
+    fmt.Fprintf(bw, "--\n-- Table structure for table %s\n--\n\n", quoted)
+    if dropTable {
+        fmt.Fprintf(bw, "DROP TABLE IF EXISTS %s;\n", quoted)
+    }
+    bw.WriteString(createTable + ";\n\n")
+    return nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get all base tables of the current database.
+//////////////////////////////////////////////////////////////////////
+func baseTables(ctx context.Context, conn *sql.Conn) ([]string, error) {
+    rows, err := conn.QueryContext(ctx, "SHOW FULL TABLES WHERE Table_type = 'BASE TABLE'")
+    if err != nil {
+        return nil, fmt.Errorf("conn.QueryContext() error: %w", err)
+    }
+    defer rows.Close()
+    var tables []string
+    for rows.Next() {
+        var table, tableType string
+        if err := rows.Scan(&table, &tableType); err != nil {
+            return nil, fmt.Errorf("rows.Scan() error: %w", err)
+        }
+        tables = append(tables, table)
+    }
+    return tables, rows.Err()
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Restore a dump. All statements are executed on one connection.
+//////////////////////////////////////////////////////////////////////
+func Restore(ctx context.Context, db *sql.DB, r io.Reader, opts *RestoreOptions) error {
+    o := RestoreOptions{}
+    if opts != nil {
+        o = *opts
+    }
+
+    counter := &countingReader{r: r}
+    br := bufio.NewReader(counter)
+    if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+        gz, err := gzip.NewReader(br)
+        if err != nil {
+            return fmt.Errorf("gzip.NewReader() error: %w", err)
+        }
+        defer gz.Close()
+        br = bufio.NewReader(gz)
+    }
+
+    conn, err := db.Conn(ctx)
+    if err != nil {
+        return fmt.Errorf("db.Conn() error: %w", err)
+    }
+    defer conn.Close()
+
+    scanner := NewStatementScanner(br)
+    progress := RestoreProgress{}
+    for scanner.Scan() {
+        stmt := scanner.Statement()
+        if _, err := conn.ExecContext(ctx, stmt); err != nil {
+            return fmt.Errorf("statement %d error: %w", progress.Statements + 1, err)
+        }
+        progress.Statements++
+        progress.Bytes = counter.n
+        if o.Progress != nil {
+            o.Progress(progress)
+        }
+    }
+    return scanner.Err()
+}
+
+
+type countingReader struct {
+    r io.Reader
+    n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+    n, err := c.r.Read(p)
+    c.n += int64(n)
+    return n, err
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// StatementScanner splits a SQL script into statements.
+// Quoted strings, identifiers and comments are respected. Line comments
+// are dropped, block comments (including /*! ... */) are kept.
+//////////////////////////////////////////////////////////////////////
+type StatementScanner struct {
+    r *bufio.Reader
+    stmt string
+    err error
+}
+
+func NewStatementScanner(r io.Reader) *StatementScanner {
+    br, ok := r.(*bufio.Reader)
+    if !ok {
+        br = bufio.NewReader(r)
+    }
+    return &StatementScanner{r: br}
+}
+
+func (s *StatementScanner) Statement() string {
+    return s.stmt
+}
+
+func (s *StatementScanner) Err() error {
+    return s.err
+}
+
+func (s *StatementScanner) Scan() bool {
+    if s.err != nil {
+        return false
+    }
+    var b strings.Builder
+    var quote rune
+    for {
+        c, _, err := s.r.ReadRune()
+        if err != nil {
+            if err != io.EOF {
+                s.err = err
+                return false
+            }
+            s.stmt = strings.TrimSpace(b.String())
+            return s.stmt != ""
+        }
+
+        if quote != 0 {
+            b.WriteRune(c)
+            if c == '\\' && quote != '`' {
+                if n, _, err := s.r.ReadRune(); err == nil {
+                    b.WriteRune(n)
+                }
+            } else if c == quote {
+                quote = 0
+            }
+            continue
+        }
+
+        switch {
+        case c == '\'' || c == '"' || c == '`':
+            quote = c
+            b.WriteRune(c)
+        case c == '#' || (c == '-' && s.peekIs("- ", "-\n", "-\t", "-\r")):
+            s.r.ReadString('\n')
+            b.WriteRune('\n')
+        case c == '/' && s.peekIs("*"):
+            b.WriteRune(c)
+            if err := s.copyBlockComment(&b); err != nil {
+                s.err = err
+                return false
+            }
+        case c == ';':
+            s.stmt = strings.TrimSpace(b.String())
+            if s.stmt != "" {
+                return true
+            }
+            b.Reset()
+        default:
+            b.WriteRune(c)
+        }
+    }
+}
+
+func (s *StatementScanner) peekIs(prefixes ...string) bool {
+    for _, p := range prefixes {
+        if next, err := s.r.Peek(len(p)); err == nil && string(next) == p {
+            return true
+        }
+    }
+    return false
+}
+
+func (s *StatementScanner) copyBlockComment(b *strings.Builder) error {
+    var prev rune
+    star, _, _ := s.r.ReadRune()
+    b.WriteRune(star)
+    for {
+        c, _, err := s.r.ReadRune()
+        if err != nil {
+            if err == io.EOF {
+                return fmt.Errorf("unterminated block comment")
+            }
+            return err
+        }
+        b.WriteRune(c)
+        if prev == '*' && c == '/' {
+            return nil
+        }
+        prev = c
+    }
+}
@@ -0,0 +1,65 @@
+package mysql_test
+
+import (
+    "bytes"
+    "context"
+    "database/sql"
+    "testing"
+    "time"
+    myMySQL "mysql"
+    "mysql/mysqltest/embedded"
+)
+
+func TestDumpRestore(t *testing.T) {
+    setup := func(db *sql.DB) error {
+        _, err := db.Exec("CREATE TABLE events (id INT NOT NULL, name VARCHAR(64) NOT NULL, created_at TIMESTAMP NOT NULL, PRIMARY KEY(id))")
+        return err
+    }
+    src := embedded.NewDB(t, &embedded.Options{Setup: []func(db *sql.DB) error{setup}})
+    src.SetMaxOpenConns(1)
+    created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+    if _, err := src.Exec("INSERT INTO events VALUES (1, 'it''s', ?), (2, 'b\\\\c', ?)", created, created); err != nil {
+        t.Fatalf("db.Exec() error: %s", err)
+    }
+    if _, err := src.Exec("SET SESSION time_zone='+09:00'"); err != nil {
+        t.Fatalf("db.Exec() error: %s", err)
+    }
+
+    var b bytes.Buffer
+    if err := myMySQL.Dump(context.Background(), src, &b, &myMySQL.DumpOptions{Compress: true}); err != nil {
+        t.Fatalf("mysql.Dump() error: %s", err)
+    }
+    var timeZone string
+    if err := src.QueryRow("SELECT @@SESSION.time_zone").Scan(&timeZone); err != nil {
+        t.Fatalf("row.Scan() error: %s", err)
+    }
+    if timeZone != "+09:00" {
+        t.Errorf("time_zone after Dump() = %q, want %q", timeZone, "+09:00")
+    }
+
+    dst := embedded.NewDB(t, nil)
+    if err := myMySQL.Restore(context.Background(), dst, &b, nil); err != nil {
+        t.Fatalf("mysql.Restore() error: %s", err)
+    }
+    rows, err := dst.Query("SELECT id, name, created_at FROM events ORDER BY id")
+    if err != nil {
+        t.Fatalf("db.Query() error: %s", err)
+    }
+    defer rows.Close()
+    var names []string
+    for rows.Next() {
+        var id int
+        var name string
+        var createdAt time.Time
+        if err := rows.Scan(&id, &name, &createdAt); err != nil {
+            t.Fatalf("rows.Scan() error: %s", err)
+        }
+        if !createdAt.Equal(created) {
+            t.Errorf("created_at of %d = %s, want %s", id, createdAt, created)
+        }
+        names = append(names, name)
+    }
+    if len(names) != 2 || names[0] != "it's" || names[1] != "b\\c" {
+        t.Errorf("names = %q", names)
+    }
+}
@@ -0,0 +1,428 @@
+//////////////////////////////////////////////////////////////////////
+// explain.go
+//
+// @usage
+//
+//     1. Import this package.
+//
+//         --------------------------------------------------
+//         import myMySQL "mysql"
+//         --------------------------------------------------
+//
+//     2. Explain a query and check the plan.
+//
+//         --------------------------------------------------
+//         plan, err := myMySQL.Explain(ctx, myMySQL.Conn(), "SELECT * FROM countries WHERE continent = ?", 2)
+//         if err != nil {
+//             // Error handling.
+//         }
+//         for _, warning := range plan.Analyze(&myMySQL.AnalyzeOptions{MaxScanRows: 100}) {
+//             log.Printf("[WARN] %s\n", warning)
+//         }
+//         --------------------------------------------------
+//
+//     3. Or parse a captured EXPLAIN FORMAT=JSON output.
+//
+//         --------------------------------------------------
+//         data, _ := os.ReadFile("testdata/plans/countries_select.json")
+//         plan, err := myMySQL.ParsePlan(data)
+//         --------------------------------------------------
+//
+//     4. Register the queries whose plans must not regress.
+//        mysqltest.AssertPlans(t, db, nil) fails the test on a warning
+//        which is not allowed.
+//
+//         --------------------------------------------------
+//         myMySQL.RegisterPlanCheck(myMySQL.PlanCheck{
+//             Name: "countries by continent",
+//             Query: "SELECT * FROM countries WHERE continent = ? ORDER BY en",
+//             Args: []interface{}{2},
+//             Allow: []string{myMySQL.WARN_FILESORT},
+//         })
+//         --------------------------------------------------
+//
+//
+// MIT License
+//
+// Copyright (c) 2019 noknow.info
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+// PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+// HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+// OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTW//ARE.
+//////////////////////////////////////////////////////////////////////
+package mysql
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "sort"
+    "strconv"
+    "sync"
+)
+
+const (
+    NODE_QUERY_BLOCK = "query_block"
+    NODE_TABLE = "table"
+    NODE_NESTED_LOOP = "nested_loop"
+    NODE_ORDERING_OPERATION = "ordering_operation"
+    NODE_GROUPING_OPERATION = "grouping_operation"
+
+    WARN_FULL_SCAN = "full_scan"
+    WARN_FILESORT = "filesort"
+    WARN_TEMPORARY = "temporary"
+    WARN_UNUSED_INDEX = "unused_index"
+
+    DEFAULT_MAX_SCAN_ROWS = 1000
+)
+
+var (
+    myPlanChecks []PlanCheck
+    myPlanChecksMu sync.Mutex
+)
+
+type Plan struct {
+    // The top query_block.
+    Root *PlanNode
+}
+
+// A node of the plan. Kind is the key of the node in EXPLAIN FORMAT=JSON,
+// e.g. NODE_QUERY_BLOCK or NODE_TABLE. The elements of an array like
+// nested_loop are the children of one node of the array key.
+type PlanNode struct {
+    Kind string
+    SelectID int
+    // query_cost of a query_block.
+    Cost float64
+    UsingFilesort bool
+    UsingTemporaryTable bool
+    // Set when Kind is NODE_TABLE.
+    Table *PlanTable
+    Children []*PlanNode
+}
+
+type PlanTable struct {
+    TableName string `json:"table_name"`
+    AccessType string `json:"access_type"`
+    PossibleKeys []string `json:"possible_keys"`
+    Key string `json:"key"`
+    UsedKeyParts []string `json:"used_key_parts"`
+    RowsExaminedPerScan int64 `json:"rows_examined_per_scan"`
+    RowsProducedPerJoin int64 `json:"rows_produced_per_join"`
+    // A string like "10.00" or a number, depending on the server.
+    Filtered json.Number `json:"filtered"`
+    UsingIndex bool `json:"using_index"`
+    AttachedCondition string `json:"attached_condition"`
+}
+
+type AnalyzeOptions struct {
+    // Full scans of more rows are flagged. Defaults to DEFAULT_MAX_SCAN_ROWS.
+    MaxScanRows int64
+}
+
+type PlanWarning struct {
+    Kind string
+    Table string
+    Message string
+}
+
+type PlanCheck struct {
+    Name string
+    Query string
+    Args []interface{}
+    // Kinds of the warnings which do not fail the check.
+    Allow []string
+}
+
+type planNodeJSON struct {
+    SelectID int `json:"select_id"`
+    UsingFilesort bool `json:"using_filesort"`
+    UsingTemporaryTable bool `json:"using_temporary_table"`
+    CostInfo struct {
+        QueryCost json.Number `json:"query_cost"`
+    } `json:"cost_info"`
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Run EXPLAIN FORMAT=JSON and parse the plan.
+//////////////////////////////////////////////////////////////////////
+func Explain(ctx context.Context, q Queryer, query string, args ...interface{}) (*Plan, error) {
+    rows, err := q.QueryContext(ctx, "EXPLAIN FORMAT=JSON " + query, args...)
+    if err != nil {
+        return nil, fmt.Errorf("QueryContext() error: %w", err)
+    }
+    defer rows.Close()
+    var data []byte
+    if rows.Next() {
+        if err := rows.Scan(&data); err != nil {
+            return nil, fmt.Errorf("rows.Scan() error: %w", err)
+        }
+    }
+    if err := rows.Err(); err != nil {
+        return nil, fmt.Errorf("rows.Err() error: %w", err)
+    }
+    if data == nil {
+        return nil, fmt.Errorf("EXPLAIN returned no plan")
+    }
+    return ParsePlan(data)
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Parse the output of EXPLAIN FORMAT=JSON.
+//////////////////////////////////////////////////////////////////////
+func ParsePlan(data []byte) (*Plan, error) {
+    var top map[string]json.RawMessage
+    if err := json.Unmarshal(data, &top); err != nil {
+        return nil, fmt.Errorf("json.Unmarshal() error: %w", err)
+    }
+    raw, ok := top[NODE_QUERY_BLOCK]
+    if !ok {
+        return nil, fmt.Errorf("no %s in the plan", NODE_QUERY_BLOCK)
+    }
+    root, err := parsePlanNode(NODE_QUERY_BLOCK, raw)
+    if err != nil {
+        return nil, err
+    }
+    return &Plan{Root: root}, nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Parse a JSON object of the plan and its children.
+//////////////////////////////////////////////////////////////////////
+func parsePlanNode(kind string, raw json.RawMessage) (*PlanNode, error) {
+    var fields map[string]json.RawMessage
+    if err := json.Unmarshal(raw, &fields); err != nil {
+        return nil, fmt.Errorf("%s: json.Unmarshal() error: %w", kind, err)
+    }
+    var v planNodeJSON
+    if err := json.Unmarshal(raw, &v); err != nil {
+        return nil, fmt.Errorf("%s: json.Unmarshal() error: %w", kind, err)
+    }
+    node := &PlanNode{
+        Kind: kind,
+        SelectID: v.SelectID,
+        UsingFilesort: v.UsingFilesort,
+        UsingTemporaryTable: v.UsingTemporaryTable,
+    }
+    if v.CostInfo.QueryCost != "" {
+        node.Cost, _ = strconv.ParseFloat(v.CostInfo.QueryCost.String(), 64)
+    }
+    if kind == NODE_TABLE {
+        node.Table = &PlanTable{}
+        if err := json.Unmarshal(raw, node.Table); err != nil {
+            return nil, fmt.Errorf("%s: json.Unmarshal() error: %w", kind, err)
+        }
+    }
+
+    keys := make([]string, 0, len(fields))
+    for key := range fields {
+        keys = append(keys, key)
+    }
+    sort.Strings(keys)
+    for _, key := range keys {
+        if key == "cost_info" {
+            continue
+        }
+        value := fields[key]
+        switch firstByte(value) {
+        case '{':
+            child, err := parsePlanNode(key, value)
+            if err != nil {
+                return nil, err
+            }
+            node.Children = append(node.Children, child)
+        case '[':
+            var elements []json.RawMessage
+            if err := json.Unmarshal(value, &elements); err != nil {
+                return nil, fmt.Errorf("%s: json.Unmarshal() error: %w", key, err)
+            }
+            list := &PlanNode{Kind: key}
+            for _, element := range elements {
+                if firstByte(element) != '{' {
+                    continue
+                }
+                child, err := parsePlanNode(key, element)
+                if err != nil {
+                    return nil, err
+                }
+                // The elements only wrap the nodes, e.g. {"table": {...}}.
+                if child.SelectID == 0 && !child.UsingFilesort && !child.UsingTemporaryTable {
+                    list.Children = append(list.Children, child.Children...)
+                } else {
+                    list.Children = append(list.Children, child)
+                }
+            }
+            if len(list.Children) > 0 {
+                node.Children = append(node.Children, list)
+            }
+        }
+    }
+    return node, nil
+}
+
+func firstByte(raw json.RawMessage) byte {
+    for _, c := range raw {
+        if c != ' ' && c != '\t' && c != '\r' && c != '\n' {
+            return c
+        }
+    }
+    return 0
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Call fn for the node and its descendants, parents first.
+//////////////////////////////////////////////////////////////////////
+func (n *PlanNode) Walk(fn func(node *PlanNode)) {
+    fn(n)
+    for _, child := range n.Children {
+        child.Walk(fn)
+    }
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get the tables of the plan in order.
+//////////////////////////////////////////////////////////////////////
+func (p *Plan) Tables() []*PlanTable {
+    var tables []*PlanTable
+    p.Root.Walk(func(node *PlanNode) {
+        if node.Table != nil {
+            tables = append(tables, node.Table)
+        }
+    })
+    return tables
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Flag the risky parts of the plan: full scans of many rows, filesorts,
+// temporary tables and possible indexes which are not used.
+//////////////////////////////////////////////////////////////////////
+func (p *Plan) Analyze(opts *AnalyzeOptions) []PlanWarning {
+    o := AnalyzeOptions{}
+    if opts != nil {
+        o = *opts
+    }
+    if o.MaxScanRows <= 0 {
+        o.MaxScanRows = DEFAULT_MAX_SCAN_ROWS
+    }
+    var warnings []PlanWarning
+    p.Root.Walk(func(node *PlanNode) {
+        if node.UsingFilesort {
+            warnings = append(warnings, PlanWarning{
+                Kind: WARN_FILESORT,
+                Table: firstTableName(node),
+                Message: fmt.Sprintf("%s uses filesort", node.Kind),
+            })
+        }
+        if node.UsingTemporaryTable {
+            warnings = append(warnings, PlanWarning{
+                Kind: WARN_TEMPORARY,
+                Table: firstTableName(node),
+                Message: fmt.Sprintf("%s uses a temporary table", node.Kind),
+            })
+        }
+        t := node.Table
+        if t == nil {
+            return
+        }
+        if (t.AccessType == "ALL" || t.AccessType == "index") && t.RowsExaminedPerScan > o.MaxScanRows {
+            scan := "full table scan"
+            if t.AccessType == "index" {
+                scan = "full index scan"
+            }
+            warnings = append(warnings, PlanWarning{
+                Kind: WARN_FULL_SCAN,
+                Table: t.TableName,
+                Message: fmt.Sprintf("%s of %d rows (max %d)", scan, t.RowsExaminedPerScan, o.MaxScanRows),
+            })
+        }
+        if len(t.PossibleKeys) > 0 && t.Key == "" {
+            warnings = append(warnings, PlanWarning{
+                Kind: WARN_UNUSED_INDEX,
+                Table: t.TableName,
+                Message: fmt.Sprintf("none of the possible keys %v is used", t.PossibleKeys),
+            })
+        }
+    })
+    return warnings
+}
+
+func firstTableName(node *PlanNode) string {
+    name := ""
+    node.Walk(func(n *PlanNode) {
+        if name == "" && n.Table != nil {
+            name = n.Table.TableName
+        }
+    })
+    return name
+}
+
+func (w PlanWarning) String() string {
+    if w.Table == "" {
+        return fmt.Sprintf("%s: %s", w.Kind, w.Message)
+    }
+    return fmt.Sprintf("%s: %s: %s", w.Kind, w.Table, w.Message)
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Register a query whose plan is checked by mysqltest.AssertPlans().
+//////////////////////////////////////////////////////////////////////
+func RegisterPlanCheck(check PlanCheck) {
+    myPlanChecksMu.Lock()
+    myPlanChecks = append(myPlanChecks, check)
+    myPlanChecksMu.Unlock()
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get the registered plan checks.
+//////////////////////////////////////////////////////////////////////
+func PlanChecks() []PlanCheck {
+    myPlanChecksMu.Lock()
+    defer myPlanChecksMu.Unlock()
+    return append([]PlanCheck{}, myPlanChecks...)
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Explain the query of the check and get the warnings which are not allowed.
+//////////////////////////////////////////////////////////////////////
+func (c PlanCheck) Run(ctx context.Context, q Queryer, opts *AnalyzeOptions) ([]PlanWarning, error) {
+    plan, err := Explain(ctx, q, c.Query, c.Args...)
+    if err != nil {
+        return nil, err
+    }
+    var result []PlanWarning
+    for _, warning := range plan.Analyze(opts) {
+        allowed := false
+        for _, kind := range c.Allow {
+            if warning.Kind == kind {
+                allowed = true
+                break
+            }
+        }
+        if !allowed {
+            result = append(result, warning)
+        }
+    }
+    return result, nil
+}
@@ -0,0 +1,125 @@
+package mysql_test
+
+import (
+    "os"
+    "reflect"
+    "testing"
+    myMySQL "mysql"
+)
+
+const (
+    joinPlan = `{
+  "query_block": {
+    "select_id": 1,
+    "cost_info": {"query_cost": "120.50"},
+    "grouping_operation": {
+      "using_temporary_table": true,
+      "using_filesort": true,
+      "nested_loop": [
+        {"table": {"table_name": "u", "access_type": "index", "possible_keys": ["PRIMARY"], "key": "PRIMARY", "rows_examined_per_scan": 5000, "filtered": 100}},
+        {"table": {"table_name": "o", "access_type": "ALL", "possible_keys": ["user_id"], "rows_examined_per_scan": 20, "filtered": 33.33}}
+      ]
+    }
+  }
+}`
+)
+
+func TestParsePlan(t *testing.T) {
+    countries, err := os.ReadFile("testdata/plans/countries_select.json")
+    if err != nil {
+        t.Fatalf("os.ReadFile() error: %s", err)
+    }
+    tests := []struct {
+        name string
+        data string
+        maxScanRows int64
+        cost float64
+        tables []string
+        filtered []string
+        warnings []string
+    }{
+        {
+            name: "countries select",
+            data: string(countries),
+            maxScanRows: 100,
+            cost: 25.65,
+            tables: []string{"countries"},
+            filtered: []string{"10.00"},
+            warnings: []string{myMySQL.WARN_FILESORT, myMySQL.WARN_FULL_SCAN},
+        },
+        {
+            name: "countries select under the scan limit",
+            data: string(countries),
+            maxScanRows: 1000,
+            cost: 25.65,
+            tables: []string{"countries"},
+            filtered: []string{"10.00"},
+            warnings: []string{myMySQL.WARN_FILESORT},
+        },
+        {
+            name: "join with numeric filtered",
+            data: joinPlan,
+            maxScanRows: 1000,
+            cost: 120.5,
+            tables: []string{"u", "o"},
+            filtered: []string{"100", "33.33"},
+            warnings: []string{myMySQL.WARN_FILESORT, myMySQL.WARN_TEMPORARY, myMySQL.WARN_FULL_SCAN, myMySQL.WARN_UNUSED_INDEX},
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            plan, err := myMySQL.ParsePlan([]byte(tt.data))
+            if err != nil {
+                t.Fatalf("mysql.ParsePlan() error: %s", err)
+            }
+            if plan.Root.Cost != tt.cost {
+                t.Errorf("cost = %v, want %v", plan.Root.Cost, tt.cost)
+            }
+            var tables, filtered []string
+            for _, table := range plan.Tables() {
+                tables = append(tables, table.TableName)
+                filtered = append(filtered, table.Filtered.String())
+            }
+            if !reflect.DeepEqual(tables, tt.tables) {
+                t.Errorf("tables = %v, want %v", tables, tt.tables)
+            }
+            if !reflect.DeepEqual(filtered, tt.filtered) {
+                t.Errorf("filtered = %v, want %v", filtered, tt.filtered)
+            }
+            var warnings []string
+            for _, warning := range plan.Analyze(&myMySQL.AnalyzeOptions{MaxScanRows: tt.maxScanRows}) {
+                warnings = append(warnings, warning.Kind)
+            }
+            if !reflect.DeepEqual(warnings, tt.warnings) {
+                t.Errorf("warnings = %v, want %v", warnings, tt.warnings)
+            }
+        })
+    }
+}
+
+func TestParsePlanErrors(t *testing.T) {
+    for _, data := range []string{
+        ``,
+        `[]`,
+        `{"query": {}}`,
+        `{"query_block": {"table": {"table_name": "t", "filtered": "high"}}}`,
+    } {
+        if _, err := myMySQL.ParsePlan([]byte(data)); err == nil {
+            t.Errorf("mysql.ParsePlan(%q) succeeded", data)
+        }
+    }
+}
+
+func TestSummarizePlan(t *testing.T) {
+    data, err := os.ReadFile("testdata/plans/countries_select.json")
+    if err != nil {
+        t.Fatalf("os.ReadFile() error: %s", err)
+    }
+    summary, err := myMySQL.SummarizePlan(data)
+    if err != nil {
+        t.Fatalf("mysql.SummarizePlan() error: %s", err)
+    }
+    if want := "full scan: countries; rows examined: 249; index: none"; summary.String() != want {
+        t.Errorf("summary = %q, want %q", summary, want)
+    }
+}
@@ -0,0 +1,619 @@
+//////////////////////////////////////////////////////////////////////
+// export.go
+//
+// @usage
+//
+//     1. Import this package.
+//
+//         --------------------------------------------------
+//         import myMySQL "mysql"
+//         --------------------------------------------------
+//
+//     2. Export a table to any io.Writer.
+//
+//         --------------------------------------------------
+//         err := myMySQL.ExportTable(db, os.Stdout, "countries", &myMySQL.ExportOptions{
+//             Format: myMySQL.FORMAT_CSV,
+//         })
+//         --------------------------------------------------
+//
+//     3. Or export the result of an arbitrary query.
+//
+//         --------------------------------------------------
+//         err := myMySQL.ExportQuery(db, w, &myMySQL.ExportOptions{Format: myMySQL.FORMAT_NDJSON},
+//             "SELECT country_code,en FROM countries WHERE continent = ?", 3)
+//         --------------------------------------------------
+//
+//     Rows are streamed one by one, they are never loaded into memory at once.
+//
+//
+// MIT License
+//
+// Copyright (c) 2019 noknow.info
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+// PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+// HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+// OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTW//ARE.
+//////////////////////////////////////////////////////////////////////
+package mysql
+
+import (
+    "bufio"
+    "context"
+    "database/sql"
+    "encoding/base64"
+    "encoding/csv"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "io"
+    "strconv"
+    "strings"
+    "time"
+)
+
+const (
+    FORMAT_CSV = "csv"
+    FORMAT_JSON = "json"
+    FORMAT_NDJSON = "ndjson"
+    FORMAT_SQL = "sql"
+
+    DEFAULT_ROWS_PER_INSERT = 100
+    DEFAULT_CSV_NULL = "\\N"
+    SQL_DATETIME_LAYOUT = "2006-01-02 15:04:05.999999"
+)
+
+const (
+    kindString = iota
+    kindNumber
+    kindBinary
+    kindJSON
+    kindTime
+)
+
+type ExportOptions struct {
+    Format string
+    // Target table name of the INSERT statements. Defaults to the exported table.
+    Table string
+    // Number of rows in one INSERT statement (FORMAT_SQL only).
+    RowsPerInsert int
+    // Do not write the header line (FORMAT_CSV only).
+    NoHeader bool
+    // Representation of NULL (FORMAT_CSV only).
+    CsvNull string
+    // Mask the values of the columns. The rules are looked up by Table.
+    Masker *Masker
+    // Write the values of FORMAT_JSON as mysqltest.LoadFixtures() reads them.
+    fixture bool
+}
+
+type exportColumn struct {
+    name string
+    kind int
+}
+
+type rowWriter interface {
+    begin(columns []exportColumn) error
+    row(values []interface{}) error
+    end() error
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Export all rows of the table.
+//////////////////////////////////////////////////////////////////////
+func ExportTable(db *sql.DB, w io.Writer, table string, opts *ExportOptions) error {
+    o := ExportOptions{}
+    if opts != nil {
+        o = *opts
+    }
+    if o.Table == "" {
+        o.Table = table
+    }
+    if err := o.Masker.LoadColumnTypes(context.Background(), db); err != nil {
+        return err
+    }
+    return ExportQuery(db, w, &o, "SELECT * FROM " + QuoteIdentifier(table))
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Export the result of the query.
+//////////////////////////////////////////////////////////////////////
+func ExportQuery(db *sql.DB, w io.Writer, opts *ExportOptions, query string, args ...interface{}) error {
+    rows, err := db.Query(query, args...)
+    if err != nil {
+        return fmt.Errorf("db.Query() error: %w", err)
+    }
+    defer rows.Close()
+    return ExportRows(rows, w, opts)
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Export the rows. The rows are not closed.
+//////////////////////////////////////////////////////////////////////
+func ExportRows(rows *sql.Rows, w io.Writer, opts *ExportOptions) error {
+    o := ExportOptions{}
+    if opts != nil {
+        o = *opts
+    }
+    columns, err := exportColumns(rows)
+    if err != nil {
+        return err
+    }
+
+    bw := bufio.NewWriter(w)
+    rw, err := newRowWriter(bw, &o)
+    if err != nil {
+        return err
+    }
+    if err := rw.begin(columns); err != nil {
+        return err
+    }
+
+    scanned := make([]interface{}, len(columns))
+    dest := make([]interface{}, len(columns))
+    for i := range scanned {
+        dest[i] = &scanned[i]
+    }
+    masks, err := o.maskRules(columns)
+    if err != nil {
+        return err
+    }
+    values := make([]interface{}, len(columns))
+    for rows.Next() {
+        if err := rows.Scan(dest...); err != nil {
+            return fmt.Errorf("rows.Scan() error: %w", err)
+        }
+        for i, c := range columns {
+            values[i] = normalizeValue(c.kind, scanned[i])
+            if masks[i] != nil {
+                values[i] = o.Masker.apply(masks[i], values[i])
+            }
+        }
+        if err := rw.row(values); err != nil {
+            return err
+        }
+    }
+    if err := rows.Err(); err != nil {
+        return fmt.Errorf("rows.Next() error: %w", err)
+    }
+    if err := rw.end(); err != nil {
+        return err
+    }
+    return bw.Flush()
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Export rows which are already normalized.
+//////////////////////////////////////////////////////////////////////
+func exportValues(w io.Writer, o *ExportOptions, columns []exportColumn, rows [][]interface{}) error {
+    bw := bufio.NewWriter(w)
+    rw, err := newRowWriter(bw, o)
+    if err != nil {
+        return err
+    }
+    if err := rw.begin(columns); err != nil {
+        return err
+    }
+    masks, err := o.maskRules(columns)
+    if err != nil {
+        return err
+    }
+    values := make([]interface{}, len(columns))
+    for _, row := range rows {
+        for i, v := range row {
+            values[i] = v
+            if masks[i] != nil {
+                values[i] = o.Masker.apply(masks[i], v)
+            }
+        }
+        if err := rw.row(values); err != nil {
+            return err
+        }
+    }
+    if err := rw.end(); err != nil {
+        return err
+    }
+    return bw.Flush()
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get the masking rule of each column.
+//////////////////////////////////////////////////////////////////////
+func (o *ExportOptions) maskRules(columns []exportColumn) ([]*MaskRule, error) {
+    masks := make([]*MaskRule, len(columns))
+    for i, c := range columns {
+        var err error
+        if masks[i], err = o.Masker.columnRule(o.Table, c.name, c.kind); err != nil {
+            return nil, err
+        }
+    }
+    return masks, nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Quote an identifier with backticks.
+//////////////////////////////////////////////////////////////////////
+func QuoteIdentifier(name string) string {
+    return "`" + strings.Replace(name, "`", "``", -1) + "`"
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Quote a string as a MySQL string literal.
+//////////////////////////////////////////////////////////////////////
+func QuoteString(s string) string {
+    var b strings.Builder
+    b.Grow(len(s) + 2)
+    b.WriteByte('\'')
+    for i := 0; i < len(s); i++ {
+        switch c := s[i]; c {
+        case 0:
+            b.WriteString("\\0")
+        case '\n':
+            b.WriteString("\\n")
+        case '\r':
+            b.WriteString("\\r")
+        case '\\':
+            b.WriteString("\\\\")
+        case '\'':
+            b.WriteString("\\'")
+        case '"':
+            b.WriteString("\\\"")
+        case '\x1a':
+            b.WriteString("\\Z")
+        default:
+            b.WriteByte(c)
+        }
+    }
+    b.WriteByte('\'')
+    return b.String()
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Format a normalized value as a SQL literal.
+//////////////////////////////////////////////////////////////////////
+func sqlLiteral(v interface{}) string {
+    switch t := v.(type) {
+    case nil:
+        return "NULL"
+    case []byte:
+        if len(t) == 0 {
+            return "''"
+        }
+        return "0x" + hex.EncodeToString(t)
+    case time.Time:
+        return "'" + t.Format(SQL_DATETIME_LAYOUT) + "'"
+    case json.Number:
+        return string(t)
+    case string:
+        return QuoteString(t)
+    }
+    return QuoteString(fmt.Sprint(v))
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get the columns with their kind.
+//////////////////////////////////////////////////////////////////////
+func exportColumns(rows *sql.Rows) ([]exportColumn, error) {
+    types, err := rows.ColumnTypes()
+    if err != nil {
+        return nil, fmt.Errorf("rows.ColumnTypes() error: %w", err)
+    }
+    columns := make([]exportColumn, len(types))
+    for i, t := range types {
+        columns[i] = exportColumn{
+            name: t.Name(),
+            kind: columnKind(t.DatabaseTypeName()),
+        }
+    }
+    return columns, nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Classify a database type name.
+//////////////////////////////////////////////////////////////////////
+func columnKind(typeName string) int {
+    switch strings.TrimPrefix(strings.ToUpper(typeName), "UNSIGNED ") {
+    case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "INTEGER", "BIGINT", "DECIMAL", "FLOAT", "DOUBLE", "YEAR":
+        return kindNumber
+    case "BINARY", "VARBINARY", "TINYBLOB", "BLOB", "MEDIUMBLOB", "LONGBLOB", "BIT", "GEOMETRY":
+        return kindBinary
+    case "JSON":
+        return kindJSON
+    case "DATE", "DATETIME", "TIMESTAMP", "TIME":
+        return kindTime
+    }
+    return kindString
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Normalize a scanned value.
+// The result is nil, string, json.Number, []byte (binary) or time.Time.
+//////////////////////////////////////////////////////////////////////
+func normalizeValue(kind int, v interface{}) interface{} {
+    if v == nil {
+        return nil
+    }
+    if t, ok := v.(time.Time); ok {
+        return t
+    }
+    var text string
+    switch t := v.(type) {
+    case []byte:
+        if kind == kindBinary {
+            b := make([]byte, len(t))
+            copy(b, t)
+            return b
+        }
+        text = string(t)
+    case string:
+        text = t
+    default:
+        text = fmt.Sprint(t)
+    }
+    if kind == kindNumber {
+        return json.Number(text)
+    }
+    return text
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Create a row writer for the format.
+//////////////////////////////////////////////////////////////////////
+func newRowWriter(w *bufio.Writer, o *ExportOptions) (rowWriter, error) {
+    switch o.Format {
+    case FORMAT_CSV, "":
+        null := o.CsvNull
+        if null == "" {
+            null = DEFAULT_CSV_NULL
+        }
+        return &csvWriter{w: csv.NewWriter(w), header: !o.NoHeader, null: null}, nil
+    case FORMAT_JSON:
+        return &jsonWriter{w: w, array: true, fixture: o.fixture}, nil
+    case FORMAT_NDJSON:
+        return &jsonWriter{w: w}, nil
+    case FORMAT_SQL:
+        if o.Table == "" {
+            return nil, fmt.Errorf("table name is required for the format %q", o.Format)
+        }
+        n := o.RowsPerInsert
+        if n <= 0 {
+            n = DEFAULT_ROWS_PER_INSERT
+        }
+        return &sqlWriter{w: w, table: o.Table, rowsPerInsert: n}, nil
+    }
+    return nil, fmt.Errorf("unknown export format %q", o.Format)
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// CSV
+//////////////////////////////////////////////////////////////////////
+type csvWriter struct {
+    w *csv.Writer
+    header bool
+    null string
+    record []string
+}
+
+func (c *csvWriter) begin(columns []exportColumn) error {
+    c.record = make([]string, len(columns))
+    if !c.header {
+        return nil
+    }
+    for i, col := range columns {
+        c.record[i] = col.name
+    }
+    return c.w.Write(c.record)
+}
+
+func (c *csvWriter) row(values []interface{}) error {
+    for i, v := range values {
+        switch t := v.(type) {
+        case nil:
+            c.record[i] = c.null
+        case []byte:
+            c.record[i] = base64.StdEncoding.EncodeToString(t)
+        case time.Time:
+            c.record[i] = t.Format(SQL_DATETIME_LAYOUT)
+        default:
+            c.record[i] = fmt.Sprint(t)
+        }
+    }
+    return c.w.Write(c.record)
+}
+
+func (c *csvWriter) end() error {
+    c.w.Flush()
+    return c.w.Error()
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// JSON array and NDJSON
+//////////////////////////////////////////////////////////////////////
+type jsonWriter struct {
+    w *bufio.Writer
+    array bool
+    fixture bool
+    keys [][]byte
+    kinds []int
+    count int
+}
+
+func (j *jsonWriter) begin(columns []exportColumn) error {
+    j.keys = make([][]byte, len(columns))
+    j.kinds = make([]int, len(columns))
+    for i, col := range columns {
+        key, err := json.Marshal(col.name)
+        if err != nil {
+            return err
+        }
+        j.keys[i] = key
+        j.kinds[i] = col.kind
+    }
+    if j.array {
+        _, err := j.w.WriteString("[")
+        return err
+    }
+    return nil
+}
+
+func (j *jsonWriter) row(values []interface{}) error {
+    if j.array && j.count > 0 {
+        j.w.WriteString(",")
+    }
+    if j.array {
+        j.w.WriteString("\n")
+    }
+    j.w.WriteString("{")
+    for i, v := range values {
+        if i > 0 {
+            j.w.WriteString(",")
+        }
+        j.w.Write(j.keys[i])
+        j.w.WriteString(":")
+        var b []byte
+        var err error
+        if j.fixture {
+            v = fixtureValue(v)
+        }
+        switch t := v.(type) {
+        case nil:
+            b = []byte("null")
+        case string:
+            if j.kinds[i] == kindJSON && json.Valid([]byte(t)) && (!j.fixture || fixtureJSON(t)) {
+                b = []byte(t)
+            } else {
+                b, err = json.Marshal(t)
+            }
+        case time.Time:
+            b, err = json.Marshal(t.Format(time.RFC3339Nano))
+        default:
+            // json.Number and []byte (base64) are handled by encoding/json.
+            b, err = json.Marshal(t)
+        }
+        if err != nil {
+            return fmt.Errorf("json.Marshal() error: %w", err)
+        }
+        j.w.Write(b)
+    }
+    _, err := j.w.WriteString("}")
+    if !j.array {
+        _, err = j.w.WriteString("\n")
+    }
+    j.count++
+    return err
+}
+
+func (j *jsonWriter) end() error {
+    if !j.array {
+        return nil
+    }
+    _, err := j.w.WriteString("\n]\n")
+    return err
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Convert a value for a fixture. The fixture values are templates, so
+// binary values are decoded with the base64 function, and strings
+// with "{{" are quoted in a template.
+//////////////////////////////////////////////////////////////////////
+func fixtureValue(v interface{}) interface{} {
+    switch t := v.(type) {
+    case []byte:
+        return "{{ base64 " + strconv.Quote(base64.StdEncoding.EncodeToString(t)) + " }}"
+    case time.Time:
+        return t.Format(SQL_DATETIME_LAYOUT)
+    case string:
+        if strings.Contains(t, "{{") {
+            return "{{ " + strconv.Quote(t) + " }}"
+        }
+    }
+    return v
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Only JSON objects and arrays are written as JSON to a fixture. They
+// are stored as JSON again, and the others are read back as strings.
+//////////////////////////////////////////////////////////////////////
+func fixtureJSON(s string) bool {
+    s = strings.TrimSpace(s)
+    return strings.HasPrefix(s, "{") || strings.HasPrefix(s, "[")
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// SQL INSERT statements
+//////////////////////////////////////////////////////////////////////
+type sqlWriter struct {
+    w *bufio.Writer
+    table string
+    rowsPerInsert int
+    prefix string
+    count int
+}
+
+func (s *sqlWriter) begin(columns []exportColumn) error {
+    names := make([]string, len(columns))
+    for i, col := range columns {
+        names[i] = QuoteIdentifier(col.name)
+    }
+    s.prefix = "INSERT INTO " + QuoteIdentifier(s.table) + " (" + strings.Join(names, ",") + ") VALUES\n"
+    return nil
+}
+
+func (s *sqlWriter) row(values []interface{}) error {
+    if s.count % s.rowsPerInsert == 0 {
+        if s.count > 0 {
+            s.w.WriteString(";\n")
+        }
+        s.w.WriteString(s.prefix)
+    } else {
+        s.w.WriteString(",\n")
+    }
+    s.w.WriteString("(")
+    for i, v := range values {
+        if i > 0 {
+            s.w.WriteString(",")
+        }
+        s.w.WriteString(sqlLiteral(v))
+    }
+    _, err := s.w.WriteString(")")
+    s.count++
+    return err
+}
+
+func (s *sqlWriter) end() error {
+    if s.count == 0 {
+        return nil
+    }
+    _, err := s.w.WriteString(";\n")
+    return err
+}
@@ -0,0 +1,71 @@
+package mysql_test
+
+import (
+    "bytes"
+    "database/sql"
+    "testing"
+    myMySQL "mysql"
+    "mysql/mysqltest/embedded"
+)
+
+func TestExportTable(t *testing.T) {
+    db := embedded.NewDB(t, &embedded.Options{
+        Setup: []func(db *sql.DB) error{
+            func(db *sql.DB) error {
+                _, err := db.Exec("CREATE TABLE items (id INT PRIMARY KEY, name VARCHAR(20), data VARBINARY(8), doc JSON, created DATETIME(6))")
+                return err
+            },
+            func(db *sql.DB) error {
+                _, err := db.Exec("INSERT INTO items VALUES" +
+                        " (1, 'a,b', 0x0102ff, '{\"k\": [1, 2]}', '2024-01-02 03:04:05')," +
+                        " (2, NULL, NULL, NULL, NULL)," +
+                        " (3, 'line\\n\"q\"', '', '\"s\"', '2024-01-02 03:04:05.5')")
+                return err
+            },
+        },
+    })
+    tests := []struct {
+        name string
+        opts myMySQL.ExportOptions
+        want string
+    }{
+        {"csv", myMySQL.ExportOptions{Format: myMySQL.FORMAT_CSV}, `id,name,data,doc,created
+1,"a,b",AQL/,"{""k"": [1, 2]}",2024-01-02 03:04:05
+2,\N,\N,\N,\N
+3,"line
+""q""",,"""s""",2024-01-02 03:04:05.5
+`},
+        {"csv without header", myMySQL.ExportOptions{Format: myMySQL.FORMAT_CSV, NoHeader: true, CsvNull: "NULL"}, `1,"a,b",AQL/,"{""k"": [1, 2]}",2024-01-02 03:04:05
+2,NULL,NULL,NULL,NULL
+3,"line
+""q""",,"""s""",2024-01-02 03:04:05.5
+`},
+        {"ndjson", myMySQL.ExportOptions{Format: myMySQL.FORMAT_NDJSON}, `{"id":1,"name":"a,b","data":"AQL/","doc":{"k": [1, 2]},"created":"2024-01-02T03:04:05Z"}
+{"id":2,"name":null,"data":null,"doc":null,"created":null}
+{"id":3,"name":"line\n\"q\"","data":"","doc":"s","created":"2024-01-02T03:04:05.5Z"}
+`},
+        {"json", myMySQL.ExportOptions{Format: myMySQL.FORMAT_JSON}, `[
+{"id":1,"name":"a,b","data":"AQL/","doc":{"k": [1, 2]},"created":"2024-01-02T03:04:05Z"},
+{"id":2,"name":null,"data":null,"doc":null,"created":null},
+{"id":3,"name":"line\n\"q\"","data":"","doc":"s","created":"2024-01-02T03:04:05.5Z"}
+]
+`},
+        {"sql", myMySQL.ExportOptions{Format: myMySQL.FORMAT_SQL, Table: "copy", RowsPerInsert: 2}, `INSERT INTO ` + "`copy` (`id`,`name`,`data`,`doc`,`created`)" + ` VALUES
+(1,'a,b',0x0102ff,'{\"k\": [1, 2]}','2024-01-02 03:04:05'),
+(2,NULL,NULL,NULL,NULL);
+INSERT INTO ` + "`copy` (`id`,`name`,`data`,`doc`,`created`)" + ` VALUES
+(3,'line\n\"q\"','','\"s\"','2024-01-02 03:04:05.5');
+`},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            var b bytes.Buffer
+            if err := myMySQL.ExportTable(db, &b, "items", &tt.opts); err != nil {
+                t.Fatalf("ExportTable() error: %s", err)
+            }
+            if b.String() != tt.want {
+                t.Errorf("ExportTable() =\n%s\nwant\n%s", b.String(), tt.want)
+            }
+        })
+    }
+}
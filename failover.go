@@ -0,0 +1,511 @@
+//////////////////////////////////////////////////////////////////////
+// failover.go
+//
+// @usage
+//
+//     1. Import this package.
+//
+//         --------------------------------------------------
+//         import myMySQL "mysql"
+//         --------------------------------------------------
+//
+//     2. List the hosts in the DSN in priority order. Init() uses a
+//        failover connector when the address has several hosts.
+//
+//         --------------------------------------------------
+//         myMySQL.Init("DB_USER:DB_PASS@tcp(db1:3306,db2:3306)/DB_NAME?parseTime=true")
+//         --------------------------------------------------
+//
+//     3. Or create the connector yourself to get the events.
+//
+//         --------------------------------------------------
+//         connector, err := myMySQL.NewFailoverConnector(datasourceName, &myMySQL.FailoverOptions{
+//             CheckInterval: 5 * time.Second,
+//             OnFailover: func(event myMySQL.FailoverEvent) {
+//                 log.Printf("[WARN] failover %s -> %s: %s\n", event.From, event.To, event.Reason)
+//             },
+//         })
+//         if err != nil {
+//             // Error handling.
+//         }
+//         myMySQL.InitConnector(connector)
+//         --------------------------------------------------
+//
+//     New connections go to the current primary. When it is unreachable or
+//     read-only (@@global.read_only = 1), the hosts are tried in priority
+//     order and the first writable one becomes the primary. The primary is
+//     also checked every CheckInterval and after a connection or read-only
+//     error. The pooled connections to the old primary are discarded when
+//     they are returned to the pool.
+//
+//
+// MIT License
+//
+// Copyright (c) 2019 noknow.info
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+// PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+// HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+// OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTW//ARE.
+//////////////////////////////////////////////////////////////////////
+package mysql
+
+import (
+    "context"
+    "database/sql/driver"
+    "errors"
+    "fmt"
+    "net"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "time"
+    gomysql "github.com/go-sql-driver/mysql"
+)
+
+const (
+    DEFAULT_FAILOVER_CHECK_INTERVAL = 5 * time.Second
+    DEFAULT_FAILOVER_TIMEOUT = 3 * time.Second
+    DEFAULT_MYSQL_PORT = "3306"
+)
+
+type FailoverOptions struct {
+    // Addresses in priority order. Defaults to the comma separated
+    // addresses of the DSN.
+    Hosts []string
+    // Defaults to DEFAULT_FAILOVER_CHECK_INTERVAL. -1 disables the background check.
+    CheckInterval time.Duration
+    // Timeout to connect to and check a host. Defaults to DEFAULT_FAILOVER_TIMEOUT.
+    Timeout time.Duration
+    // Called after the primary changed.
+    OnFailover func(event FailoverEvent)
+    // Credentials of the new connections. Defaults to the ones of the DSN.
+    Credentials CredentialsProvider
+}
+
+type FailoverEvent struct {
+    From string
+    To string
+    Reason string
+    Time time.Time
+}
+
+type FailoverConnector struct {
+    o FailoverOptions
+    hosts []string
+    connectors []driver.Connector
+    mu sync.RWMutex
+    primary int
+    // Increased on each failover. The connections of an older generation are discarded.
+    generation uint64
+    checking atomic.Bool
+    stop chan struct{}
+    done chan struct{}
+    closeOnce sync.Once
+}
+
+type failoverConn struct {
+    conn driver.Conn
+    connector *FailoverConnector
+    host int
+    generation uint64
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Check whether the address of the DSN has several hosts.
+//////////////////////////////////////////////////////////////////////
+func IsMultiHostDSN(datasourceName string) bool {
+    cfg, err := gomysql.ParseDSN(datasourceName)
+    return err == nil && strings.Contains(cfg.Addr, ",")
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get the address of the DSN as written. ParseDSN() adds the default
+// port to the whole "host1,host2" as "[host1,host2]:3306".
+//////////////////////////////////////////////////////////////////////
+func dsnAddr(cfg *gomysql.Config) string {
+    addr := cfg.Addr
+    suffix := "]:" + DEFAULT_MYSQL_PORT
+    if cfg.Net == "tcp" && strings.HasPrefix(addr, "[") && strings.HasSuffix(addr, suffix) && strings.Contains(addr, ",") {
+        addr = addr[1:len(addr) - len(suffix)]
+    }
+    return addr
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Create a connector which follows the writable host.
+//////////////////////////////////////////////////////////////////////
+func NewFailoverConnector(datasourceName string, opts *FailoverOptions) (*FailoverConnector, error) {
+    cfg, err := gomysql.ParseDSN(datasourceName)
+    if err != nil {
+        return nil, fmt.Errorf("mysql.ParseDSN() error: %w", err)
+    }
+    c := &FailoverConnector{}
+    if opts != nil {
+        c.o = *opts
+    }
+    if c.o.CheckInterval == 0 {
+        c.o.CheckInterval = DEFAULT_FAILOVER_CHECK_INTERVAL
+    }
+    if c.o.Timeout <= 0 {
+        c.o.Timeout = DEFAULT_FAILOVER_TIMEOUT
+    }
+    c.hosts = append([]string{}, c.o.Hosts...)
+    if len(c.hosts) == 0 {
+        for _, host := range strings.Split(dsnAddr(cfg), ",") {
+            if host = strings.TrimSpace(host); host != "" {
+                c.hosts = append(c.hosts, host)
+            }
+        }
+    }
+    if len(c.hosts) == 0 {
+        return nil, fmt.Errorf("no hosts")
+    }
+    for i, host := range c.hosts {
+        if cfg.Net == "tcp" {
+            if _, _, err := net.SplitHostPort(host); err != nil {
+                c.hosts[i] = net.JoinHostPort(host, DEFAULT_MYSQL_PORT)
+            }
+        }
+        hostCfg := cfg.Clone()
+        hostCfg.Addr = c.hosts[i]
+        if hostCfg.TLS != nil && (hostCfg.TLS.ServerName == "" || strings.Contains(hostCfg.TLS.ServerName, ",")) && cfg.Net == "tcp" {
+            // ParseDSN() took the joined addresses as the server name, or
+            // left it empty for a config which verifies the certificate itself.
+            hostCfg.TLS = hostCfg.TLS.Clone()
+            hostCfg.TLS.ServerName, _, _ = net.SplitHostPort(c.hosts[i])
+        }
+        if c.o.Credentials != nil {
+            if err := applyCredentials(hostCfg, c.o.Credentials); err != nil {
+                return nil, err
+            }
+        }
+        connector, err := gomysql.NewConnector(hostCfg)
+        if err != nil {
+            return nil, fmt.Errorf("mysql.NewConnector() error: %w", err)
+        }
+        c.connectors = append(c.connectors, connector)
+    }
+
+    if c.o.CheckInterval > 0 {
+        c.stop = make(chan struct{})
+        c.done = make(chan struct{})
+        go c.monitor()
+    }
+    return c, nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get the address of the current primary.
+//////////////////////////////////////////////////////////////////////
+func (c *FailoverConnector) Primary() string {
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+    return c.hosts[c.primary]
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// driver.Connector
+//////////////////////////////////////////////////////////////////////
+func (c *FailoverConnector) Connect(ctx context.Context) (driver.Conn, error) {
+    c.mu.RLock()
+    primary, generation := c.primary, c.generation
+    c.mu.RUnlock()
+
+    // The current primary first, then the others in priority order.
+    order := []int{primary}
+    for i := range c.hosts {
+        if i != primary {
+            order = append(order, i)
+        }
+    }
+    var errs []error
+    for _, i := range order {
+        conn, err := c.connectWritable(ctx, i)
+        if err != nil {
+            errs = append(errs, fmt.Errorf("%s: %w", c.hosts[i], err))
+            continue
+        }
+        if i != primary {
+            generation = c.failover(primary, i, errors.Join(errs...))
+        }
+        return &failoverConn{conn: conn, connector: c, host: i, generation: generation}, nil
+    }
+    return nil, fmt.Errorf("no writable host: %w", errors.Join(errs...))
+}
+
+func (c *FailoverConnector) Driver() driver.Driver {
+    return c.connectors[0].Driver()
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Stop the background check. (*sql.DB) Close() calls it.
+//////////////////////////////////////////////////////////////////////
+func (c *FailoverConnector) Close() error {
+    c.closeOnce.Do(func() {
+        if c.stop != nil {
+            close(c.stop)
+            <-c.done
+        }
+    })
+    return nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Check the primary, and fail over when it is unreachable or read-only.
+//////////////////////////////////////////////////////////////////////
+func (c *FailoverConnector) Check(ctx context.Context) error {
+    c.mu.RLock()
+    primary := c.primary
+    c.mu.RUnlock()
+    conn, err := c.connectWritable(ctx, primary)
+    if err == nil {
+        return conn.Close()
+    }
+    conn, err = c.Connect(ctx)
+    if err != nil {
+        return err
+    }
+    return conn.Close()
+}
+
+func (c *FailoverConnector) monitor() {
+    defer close(c.done)
+    ticker := time.NewTicker(c.o.CheckInterval)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-c.stop:
+            return
+        case <-ticker.C:
+            c.checkInBackground()
+        }
+    }
+}
+
+func (c *FailoverConnector) checkInBackground() {
+    if !c.checking.CompareAndSwap(false, true) {
+        return
+    }
+    defer c.checking.Store(false)
+    ctx, cancel := context.WithTimeout(context.Background(), c.o.Timeout * time.Duration(len(c.hosts) + 1))
+    defer cancel()
+    c.Check(ctx)
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Connect to the host and check that it is writable.
+//////////////////////////////////////////////////////////////////////
+func (c *FailoverConnector) connectWritable(ctx context.Context, i int) (driver.Conn, error) {
+    ctx, cancel := context.WithTimeout(ctx, c.o.Timeout)
+    defer cancel()
+    conn, err := c.connectors[i].Connect(ctx)
+    if err != nil {
+        return nil, err
+    }
+    readOnly, err := isReadOnly(ctx, conn)
+    if err != nil {
+        conn.Close()
+        return nil, err
+    }
+    if readOnly {
+        conn.Close()
+        return nil, fmt.Errorf("read-only")
+    }
+    return conn, nil
+}
+
+func isReadOnly(ctx context.Context, conn driver.Conn) (bool, error) {
+    q, ok := conn.(driver.QueryerContext)
+    if !ok {
+        return false, fmt.Errorf("the connection does not support QueryContext")
+    }
+    rows, err := q.QueryContext(ctx, "SELECT @@global.read_only", nil)
+    if err != nil {
+        return false, err
+    }
+    defer rows.Close()
+    dest := make([]driver.Value, 1)
+    if err := rows.Next(dest); err != nil {
+        return false, err
+    }
+    switch v := dest[0].(type) {
+    case int64:
+        return v != 0, nil
+    case []byte:
+        return string(v) != "0", nil
+    case string:
+        return v != "0", nil
+    }
+    return false, fmt.Errorf("unexpected @@global.read_only: %v", dest[0])
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Make the host the primary and emit the event. It returns the new
+// generation.
+//////////////////////////////////////////////////////////////////////
+func (c *FailoverConnector) failover(from, to int, reason error) uint64 {
+    c.mu.Lock()
+    if c.primary != from {
+        // Another connection failed over already.
+        generation := c.generation
+        c.mu.Unlock()
+        return generation
+    }
+    c.primary = to
+    c.generation++
+    generation := c.generation
+    c.mu.Unlock()
+
+    if c.o.OnFailover != nil {
+        event := FailoverEvent{
+            From: c.hosts[from],
+            To: c.hosts[to],
+            Time: time.Now(),
+        }
+        if reason != nil {
+            event.Reason = reason.Error()
+        }
+        c.o.OnFailover(event)
+    }
+    return generation
+}
+
+func (c *FailoverConnector) stale(generation uint64) bool {
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+    return generation != c.generation
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Check the primary in the background after an error which may mean
+// that it went away or became read-only.
+//////////////////////////////////////////////////////////////////////
+func (fc *failoverConn) observe(err error) {
+    if err != nil && IsTransientError(err) {
+        go fc.connector.checkInBackground()
+    }
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// driver.Conn
+//////////////////////////////////////////////////////////////////////
+func (fc *failoverConn) Prepare(query string) (driver.Stmt, error) {
+    return fc.PrepareContext(context.Background(), query)
+}
+
+func (fc *failoverConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+    var stmt driver.Stmt
+    var err error
+    if p, ok := fc.conn.(driver.ConnPrepareContext); ok {
+        stmt, err = p.PrepareContext(ctx, query)
+    } else {
+        stmt, err = fc.conn.Prepare(query)
+    }
+    fc.observe(err)
+    return stmt, err
+}
+
+func (fc *failoverConn) Close() error {
+    return fc.conn.Close()
+}
+
+// The connector of the host of the connection, e.g. for KILL QUERY.
+func (fc *failoverConn) hostConnector() driver.Connector {
+    return fc.connector.connectors[fc.host]
+}
+
+func (fc *failoverConn) Begin() (driver.Tx, error) {
+    return fc.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (fc *failoverConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+    var tx driver.Tx
+    var err error
+    if b, ok := fc.conn.(driver.ConnBeginTx); ok {
+        tx, err = b.BeginTx(ctx, opts)
+    } else {
+        tx, err = fc.conn.Begin()
+    }
+    fc.observe(err)
+    return tx, err
+}
+
+func (fc *failoverConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+    q, ok := fc.conn.(driver.QueryerContext)
+    if !ok {
+        return nil, driver.ErrSkip
+    }
+    rows, err := q.QueryContext(ctx, query, args)
+    fc.observe(err)
+    return rows, err
+}
+
+func (fc *failoverConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+    e, ok := fc.conn.(driver.ExecerContext)
+    if !ok {
+        return nil, driver.ErrSkip
+    }
+    result, err := e.ExecContext(ctx, query, args)
+    fc.observe(err)
+    return result, err
+}
+
+func (fc *failoverConn) Ping(ctx context.Context) error {
+    if p, ok := fc.conn.(driver.Pinger); ok {
+        err := p.Ping(ctx)
+        fc.observe(err)
+        return err
+    }
+    return nil
+}
+
+func (fc *failoverConn) CheckNamedValue(nv *driver.NamedValue) error {
+    if c, ok := fc.conn.(driver.NamedValueChecker); ok {
+        return c.CheckNamedValue(nv)
+    }
+    return driver.ErrSkip
+}
+
+func (fc *failoverConn) ResetSession(ctx context.Context) error {
+    if fc.connector.stale(fc.generation) {
+        return driver.ErrBadConn
+    }
+    if r, ok := fc.conn.(driver.SessionResetter); ok {
+        return r.ResetSession(ctx)
+    }
+    return nil
+}
+
+func (fc *failoverConn) IsValid() bool {
+    if fc.connector.stale(fc.generation) {
+        return false
+    }
+    if v, ok := fc.conn.(driver.Validator); ok {
+        return v.IsValid()
+    }
+    return true
+}
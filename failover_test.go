@@ -0,0 +1,113 @@
+package mysql
+
+import (
+    "context"
+    "database/sql"
+    "net"
+    "strings"
+    "testing"
+    gomysql "github.com/go-sql-driver/mysql"
+    "mysql/mysqltest/embedded"
+)
+
+func TestIsMultiHostDSN(t *testing.T) {
+    tests := []struct {
+        dsn string
+        want bool
+    }{
+        {"root@tcp(db1:3306,db2:3306)/test", true},
+        {"root@tcp(db1,db2)/test", true},
+        {"root@tcp(db1:3306)/test", false},
+        {"root@unix(/tmp/mysql.sock)/test", false},
+        {"not a dsn", false},
+    }
+    for _, tt := range tests {
+        if got := IsMultiHostDSN(tt.dsn); got != tt.want {
+            t.Errorf("IsMultiHostDSN(%q) = %v, want %v", tt.dsn, got, tt.want)
+        }
+    }
+}
+
+func TestFailoverConnectorHosts(t *testing.T) {
+    c, err := NewFailoverConnector("root@tcp(db1,db2:3307)/test", &FailoverOptions{CheckInterval: -1})
+    if err != nil {
+        t.Fatalf("NewFailoverConnector() error: %s", err)
+    }
+    defer c.Close()
+    if got, want := strings.Join(c.hosts, ","), "db1:3306,db2:3307"; got != want {
+        t.Errorf("hosts = %s, want %s", got, want)
+    }
+    if got := c.Primary(); got != "db1:3306" {
+        t.Errorf("Primary() = %s, want db1:3306", got)
+    }
+}
+
+func TestFailoverConnectorFailover(t *testing.T) {
+    live := embeddedAddrT(t)
+    dead := closedAddrT(t)
+    var events []FailoverEvent
+    c, err := NewFailoverConnector("root@tcp(" + dead + "," + live + ")/test?parseTime=true", &FailoverOptions{
+        CheckInterval: -1,
+        OnFailover: func(event FailoverEvent) {
+            events = append(events, event)
+        },
+    })
+    if err != nil {
+        t.Fatalf("NewFailoverConnector() error: %s", err)
+    }
+    db := sql.OpenDB(c)
+    defer db.Close()
+
+    if err := db.Ping(); err != nil {
+        t.Fatalf("(*sql.DB) Ping() error: %s", err)
+    }
+    if got := c.Primary(); got != live {
+        t.Errorf("Primary() = %s, want %s", got, live)
+    }
+    if len(events) != 1 {
+        t.Fatalf("events = %d, want 1", len(events))
+    }
+    if events[0].From != dead || events[0].To != live || !strings.Contains(events[0].Reason, dead) {
+        t.Errorf("event = %+v", events[0])
+    }
+
+    // The new primary is healthy, so a check keeps it.
+    if err := c.Check(context.Background()); err != nil {
+        t.Fatalf("Check() error: %s", err)
+    }
+    if got := c.Primary(); got != live || len(events) != 1 {
+        t.Errorf("Primary() = %s with %d events after Check(), want %s with 1", got, len(events), live)
+    }
+}
+
+func TestFailoverConnectorNoWritableHost(t *testing.T) {
+    c, err := NewFailoverConnector("root@tcp(" + closedAddrT(t) + "," + closedAddrT(t) + ")/test", &FailoverOptions{CheckInterval: -1})
+    if err != nil {
+        t.Fatalf("NewFailoverConnector() error: %s", err)
+    }
+    defer c.Close()
+    if _, err := c.Connect(context.Background()); err == nil || !strings.Contains(err.Error(), "no writable host") {
+        t.Errorf("Connect() error = %v, want no writable host", err)
+    }
+}
+
+func embeddedAddrT(t testing.TB) string {
+    t.Helper()
+    cfg, err := gomysql.ParseDSN(embedded.NewDSN(t, nil))
+    if err != nil {
+        t.Fatalf("mysql.ParseDSN() error: %s", err)
+    }
+    return cfg.Addr
+}
+
+// An address which refuses connections.
+func closedAddrT(t testing.TB) string {
+    t.Helper()
+    listener, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatalf("net.Listen() error: %s", err)
+    }
+    addr := listener.Addr().String()
+    listener.Close()
+    return addr
+}
@@ -0,0 +1,371 @@
+//////////////////////////////////////////////////////////////////////
+// health.go
+//
+// @usage
+//
+//     1. Import this package.
+//
+//         --------------------------------------------------
+//         import myMySQL "mysql"
+//         --------------------------------------------------
+//
+//     2. Start the monitor after Init() and the other Register() calls.
+//        It pings every registered connection in the background.
+//
+//         --------------------------------------------------
+//         monitor := myMySQL.NewHealthMonitor(&myMySQL.HealthOptions{
+//             Interval: 10 * time.Second,
+//             Replicas: []string{"replica"},
+//             MaxReplicaLag: 30 * time.Second,
+//         })
+//         monitor.Start()
+//         defer monitor.Stop()
+//
+//         http.Handle("/healthz", monitor.Liveness())
+//         http.Handle("/readyz", monitor.Readiness())
+//         --------------------------------------------------
+//
+//     Readiness() responds 503 when a connection failed FailureThreshold
+//     pings in a row, a replica lags more than MaxReplicaLag or the pool
+//     saturation (in use / max open) reaches MaxSaturation. Liveness()
+//     always responds 200. Both write the report in JSON.
+//
+//
+// MIT License
+//
+// Copyright (c) 2019 noknow.info
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+// PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+// HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+// OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTW//ARE.
+//////////////////////////////////////////////////////////////////////
+package mysql
+
+import (
+    "context"
+    "database/sql"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "sort"
+    "strconv"
+    "sync"
+    "time"
+)
+
+const (
+    DEFAULT_HEALTH_INTERVAL = 10 * time.Second
+    DEFAULT_HEALTH_TIMEOUT = 2 * time.Second
+    DEFAULT_FAILURE_THRESHOLD = 3
+    DEFAULT_MAX_SATURATION = 1.0
+)
+
+type HealthOptions struct {
+    // Defaults to DEFAULT_HEALTH_INTERVAL.
+    Interval time.Duration
+    // Timeout of each ping. Defaults to DEFAULT_HEALTH_TIMEOUT.
+    Timeout time.Duration
+    // Consecutive failures to be not ready. Defaults to DEFAULT_FAILURE_THRESHOLD.
+    FailureThreshold int
+    // Names of the registered connections whose replica lag is checked.
+    Replicas []string
+    // Maximum replica lag to be ready. 0 means no limit.
+    MaxReplicaLag time.Duration
+    // Maximum in use / max open connections to be ready. Defaults to DEFAULT_MAX_SATURATION.
+    MaxSaturation float64
+}
+
+type ConnectionHealth struct {
+    Name string `json:"name"`
+    Ready bool `json:"ready"`
+    LastCheck time.Time `json:"last_check"`
+    LastSuccess time.Time `json:"last_success,omitzero"`
+    LatencyMs float64 `json:"latency_ms"`
+    ConsecutiveFailures int `json:"consecutive_failures"`
+    LastError string `json:"last_error,omitempty"`
+    // Seconds. nil when it is not a replica or the lag is unknown.
+    ReplicaLag *int64 `json:"replica_lag_seconds,omitempty"`
+    OpenConnections int `json:"open_connections"`
+    InUse int `json:"in_use"`
+    MaxOpenConnections int `json:"max_open_connections"`
+    Saturation float64 `json:"saturation"`
+    WaitCount int64 `json:"wait_count"`
+}
+
+type HealthReport struct {
+    Ready bool `json:"ready"`
+    Connections []ConnectionHealth `json:"connections"`
+}
+
+type HealthMonitor struct {
+    o HealthOptions
+    mu sync.RWMutex
+    status map[string]*ConnectionHealth
+    stop chan struct{}
+    done chan struct{}
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Create a health monitor of the registered connections.
+//////////////////////////////////////////////////////////////////////
+func NewHealthMonitor(opts *HealthOptions) *HealthMonitor {
+    o := HealthOptions{}
+    if opts != nil {
+        o = *opts
+    }
+    if o.Interval <= 0 {
+        o.Interval = DEFAULT_HEALTH_INTERVAL
+    }
+    if o.Timeout <= 0 {
+        o.Timeout = DEFAULT_HEALTH_TIMEOUT
+    }
+    if o.FailureThreshold <= 0 {
+        o.FailureThreshold = DEFAULT_FAILURE_THRESHOLD
+    }
+    if o.MaxSaturation <= 0 {
+        o.MaxSaturation = DEFAULT_MAX_SATURATION
+    }
+    return &HealthMonitor{
+        o: o,
+        status: map[string]*ConnectionHealth{},
+    }
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Check now and then every interval until Stop().
+//////////////////////////////////////////////////////////////////////
+func (m *HealthMonitor) Start() {
+    m.mu.Lock()
+    if m.stop != nil {
+        m.mu.Unlock()
+        return
+    }
+    m.stop = make(chan struct{})
+    m.done = make(chan struct{})
+    stop, done := m.stop, m.done
+    m.mu.Unlock()
+
+    m.Check(context.Background())
+    go func() {
+        defer close(done)
+        ticker := time.NewTicker(m.o.Interval)
+        defer ticker.Stop()
+        for {
+            select {
+            case <-stop:
+                return
+            case <-ticker.C:
+                m.Check(context.Background())
+            }
+        }
+    }()
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Stop the background checks.
+//////////////////////////////////////////////////////////////////////
+func (m *HealthMonitor) Stop() {
+    m.mu.Lock()
+    stop, done := m.stop, m.done
+    m.stop, m.done = nil, nil
+    m.mu.Unlock()
+    if stop != nil {
+        close(stop)
+        <-done
+    }
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Check all the registered connections once.
+//////////////////////////////////////////////////////////////////////
+func (m *HealthMonitor) Check(ctx context.Context) {
+    registered := Registered()
+    var wg sync.WaitGroup
+    for name, db := range registered {
+        wg.Add(1)
+        go func(name string, db *sql.DB) {
+            defer wg.Done()
+            m.checkConnection(ctx, name, db)
+        }(name, db)
+    }
+    wg.Wait()
+
+    m.mu.Lock()
+    for name := range m.status {
+        if _, ok := registered[name]; !ok {
+            delete(m.status, name)
+        }
+    }
+    m.mu.Unlock()
+}
+
+func (m *HealthMonitor) checkConnection(ctx context.Context, name string, db *sql.DB) {
+    ctx, cancel := context.WithTimeout(ctx, m.o.Timeout)
+    defer cancel()
+
+    start := time.Now()
+    err := db.PingContext(ctx)
+    latency := time.Since(start)
+    var lag *int64
+    if err == nil && m.isReplica(name) {
+        lag, err = replicaLag(ctx, db)
+    }
+    stats := db.Stats()
+
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    h, ok := m.status[name]
+    if !ok {
+        h = &ConnectionHealth{Name: name}
+        m.status[name] = h
+    }
+    h.LastCheck = start
+    h.LatencyMs = float64(latency.Microseconds()) / 1000
+    h.ReplicaLag = lag
+    h.OpenConnections = stats.OpenConnections
+    h.InUse = stats.InUse
+    h.MaxOpenConnections = stats.MaxOpenConnections
+    h.WaitCount = stats.WaitCount
+    h.Saturation = 0
+    if stats.MaxOpenConnections > 0 {
+        h.Saturation = float64(stats.InUse) / float64(stats.MaxOpenConnections)
+    }
+    if err != nil {
+        h.ConsecutiveFailures++
+        h.LastError = err.Error()
+    } else {
+        h.ConsecutiveFailures = 0
+        h.LastError = ""
+        h.LastSuccess = start
+    }
+    h.Ready = h.ConsecutiveFailures < m.o.FailureThreshold &&
+            !h.LastSuccess.IsZero() &&
+            h.Saturation < m.o.MaxSaturation &&
+            (m.o.MaxReplicaLag <= 0 || !m.isReplica(name) || (h.ReplicaLag != nil && time.Duration(*h.ReplicaLag) * time.Second <= m.o.MaxReplicaLag))
+}
+
+func (m *HealthMonitor) isReplica(name string) bool {
+    for _, replica := range m.o.Replicas {
+        if replica == name {
+            return true
+        }
+    }
+    return false
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get the replica lag in seconds from SHOW REPLICA STATUS, or
+// SHOW SLAVE STATUS on the servers before MySQL 8.0.22.
+//////////////////////////////////////////////////////////////////////
+func replicaLag(ctx context.Context, db *sql.DB) (*int64, error) {
+    rows, err := db.QueryContext(ctx, "SHOW REPLICA STATUS")
+    if err != nil {
+        if rows, err = db.QueryContext(ctx, "SHOW SLAVE STATUS"); err != nil {
+            return nil, fmt.Errorf("db.QueryContext() error: %w", err)
+        }
+    }
+    defer rows.Close()
+    columns, err := rows.Columns()
+    if err != nil {
+        return nil, fmt.Errorf("rows.Columns() error: %w", err)
+    }
+    if !rows.Next() {
+        if err := rows.Err(); err != nil {
+            return nil, fmt.Errorf("rows.Err() error: %w", err)
+        }
+        return nil, fmt.Errorf("not a replica")
+    }
+    values := make([]sql.RawBytes, len(columns))
+    dest := make([]interface{}, len(columns))
+    for i := range values {
+        dest[i] = &values[i]
+    }
+    if err := rows.Scan(dest...); err != nil {
+        return nil, fmt.Errorf("rows.Scan() error: %w", err)
+    }
+    for i, column := range columns {
+        if column != "Seconds_Behind_Source" && column != "Seconds_Behind_Master" {
+            continue
+        }
+        if values[i] == nil {
+            return nil, fmt.Errorf("replication is not running")
+        }
+        lag, err := strconv.ParseInt(string(values[i]), 10, 64)
+        if err != nil {
+            return nil, fmt.Errorf("strconv.ParseInt() error: %w", err)
+        }
+        return &lag, nil
+    }
+    return nil, fmt.Errorf("no Seconds_Behind_Source column")
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get the result of the last check.
+//////////////////////////////////////////////////////////////////////
+func (m *HealthMonitor) Report() HealthReport {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+    report := HealthReport{Ready: len(m.status) > 0, Connections: []ConnectionHealth{}}
+    for _, h := range m.status {
+        report.Connections = append(report.Connections, *h)
+        if !h.Ready {
+            report.Ready = false
+        }
+    }
+    sort.Slice(report.Connections, func(i, j int) bool {
+        return report.Connections[i].Name < report.Connections[j].Name
+    })
+    return report
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get an http.Handler for the liveness probe. It always responds 200.
+//////////////////////////////////////////////////////////////////////
+func (m *HealthMonitor) Liveness() http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        writeHealthReport(w, http.StatusOK, m.Report())
+    })
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get an http.Handler for the readiness probe. It responds 503 when
+// a connection is not ready.
+//////////////////////////////////////////////////////////////////////
+func (m *HealthMonitor) Readiness() http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        report := m.Report()
+        status := http.StatusOK
+        if !report.Ready {
+            status = http.StatusServiceUnavailable
+        }
+        writeHealthReport(w, status, report)
+    })
+}
+
+func writeHealthReport(w http.ResponseWriter, status int, report HealthReport) {
+    w.Header().Set("Content-Type", "application/json")
+    w.Header().Set("Cache-Control", "no-store")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,86 @@
+package mysql_test
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+    myMySQL "mysql"
+    "mysql/mysqltest"
+)
+
+func TestHealthReadiness(t *testing.T) {
+    tests := []struct {
+        name string
+        expect func(mock *mysqltest.Mock)
+        status int
+        lastError string
+    }{
+        {"ping", func(mock *mysqltest.Mock) {
+            mock.ExpectPing()
+        }, http.StatusOK, ""},
+        {"error", func(mock *mysqltest.Mock) {
+            mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+        }, http.StatusServiceUnavailable, "connection refused"},
+        {"timeout", func(mock *mysqltest.Mock) {
+            mock.ExpectPing().WillDelayFor(10 * time.Second)
+        }, http.StatusServiceUnavailable, context.DeadlineExceeded.Error()},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            db, mock := mysqltest.NewT(t)
+            tt.expect(mock)
+            myMySQL.Register("health", db)
+            defer myMySQL.Unregister("health")
+
+            monitor := myMySQL.NewHealthMonitor(&myMySQL.HealthOptions{
+                Timeout: 100 * time.Millisecond,
+                FailureThreshold: 1,
+            })
+            start := time.Now()
+            monitor.Check(context.Background())
+            if elapsed := time.Since(start); elapsed > 2 * time.Second {
+                t.Errorf("Check() took %s with the timeout 100ms", elapsed)
+            }
+
+            w := httptest.NewRecorder()
+            monitor.Readiness().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+            if w.Code != tt.status {
+                t.Errorf("status = %d, want %d", w.Code, tt.status)
+            }
+            if contentType := w.Header().Get("Content-Type"); contentType != "application/json" {
+                t.Errorf("Content-Type = %q, want application/json", contentType)
+            }
+            var report myMySQL.HealthReport
+            if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+                t.Fatalf("json.Unmarshal() error: %s\n%s", err, w.Body.String())
+            }
+            if report.Ready != (tt.status == http.StatusOK) || len(report.Connections) != 1 {
+                t.Fatalf("report = %s", w.Body.String())
+            }
+            h := report.Connections[0]
+            if h.Name != "health" || !strings.Contains(h.LastError, tt.lastError) || (tt.lastError == "") != (h.LastError == "") {
+                t.Errorf("connection = %+v, want the name health and the error %q", h, tt.lastError)
+            }
+        })
+    }
+}
+
+func TestHealthLiveness(t *testing.T) {
+    db, mock := mysqltest.NewT(t)
+    mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+    myMySQL.Register("health", db)
+    defer myMySQL.Unregister("health")
+
+    monitor := myMySQL.NewHealthMonitor(&myMySQL.HealthOptions{FailureThreshold: 1})
+    monitor.Check(context.Background())
+    w := httptest.NewRecorder()
+    monitor.Liveness().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+    if w.Code != http.StatusOK {
+        t.Errorf("status = %d, want %d", w.Code, http.StatusOK)
+    }
+}
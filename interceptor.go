@@ -0,0 +1,662 @@
+//////////////////////////////////////////////////////////////////////
+// interceptor.go
+//
+// @usage
+//
+//     1. Import this package.
+//
+//         --------------------------------------------------
+//         import myMySQL "mysql"
+//         --------------------------------------------------
+//
+//     2. Register interceptors before Init().
+//
+//         --------------------------------------------------
+//         myMySQL.Use(myMySQL.AfterHook(func(ctx context.Context, call *myMySQL.Call, d time.Duration, err error) {
+//             log.Printf("[DEBUG] %s %s %v (%s) %v\n", call.Op, call.Query, call.Args, d, err)
+//         }))
+//         myMySQL.Init(datasourceName)
+//         --------------------------------------------------
+//
+//     3. Or write a middleware which controls the call, e.g. fault injection.
+//
+//         --------------------------------------------------
+//         myMySQL.Use(func(ctx context.Context, call *myMySQL.Call, next myMySQL.Handler) error {
+//             if call.Op == myMySQL.OP_EXEC && strings.HasPrefix(call.Query, "DELETE") {
+//                 return errors.New("DELETE is not allowed")
+//             }
+//             return next(ctx, call)
+//         })
+//         --------------------------------------------------
+//
+//     The interceptors run in registration order around every query, exec,
+//     prepare, begin, commit and rollback on the connections of Conn(),
+//     and around opening a new connection.
+//     The call sites using Conn() do not change.
+//
+//
+// MIT License
+//
+// Copyright (c) 2019 noknow.info
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+// PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+// HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+// OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTW//ARE.
+//////////////////////////////////////////////////////////////////////
+package mysql
+
+import (
+    "context"
+    "database/sql"
+    "database/sql/driver"
+    "fmt"
+    "io"
+    "reflect"
+    "strings"
+    "sync"
+    "time"
+)
+
+const (
+    OP_QUERY = "query"
+    OP_EXEC = "exec"
+    OP_PREPARE = "prepare"
+    OP_BEGIN = "begin"
+    OP_COMMIT = "commit"
+    OP_ROLLBACK = "rollback"
+    OP_CONNECT = "connect"
+)
+
+var (
+    myInterceptors []Interceptor
+    myInterceptorsMu sync.Mutex
+)
+
+// A database call. Interceptors may change Query and Args before calling next.
+type Call struct {
+    Op string
+    Query string
+    Args []driver.NamedValue
+    // true when the query or the exec runs on a prepared statement.
+    Prepared bool
+    TxOptions driver.TxOptions
+    // Set when next returns without an error.
+    Rows driver.Rows
+    Result driver.Result
+    Stmt driver.Stmt
+    Tx driver.Tx
+    Conn driver.Conn
+}
+
+type Handler func(ctx context.Context, call *Call) error
+
+type Interceptor func(ctx context.Context, call *Call, next Handler) error
+
+type HookFunc func(ctx context.Context, call *Call, duration time.Duration, err error)
+
+// A driver.Connector which runs the interceptors around the calls of
+// the connections of the base connector.
+type Connector struct {
+    base driver.Connector
+    mu sync.RWMutex
+    interceptors []Interceptor
+    // Set by InitConnector() for Shutdown().
+    tracker *connTracker
+}
+
+type dsnConnector struct {
+    driver driver.Driver
+    dsn string
+}
+
+type interceptedConn struct {
+    conn driver.Conn
+    connector *Connector
+    // nil when the connector has no tracker.
+    state *connState
+}
+
+type interceptedStmt struct {
+    stmt driver.Stmt
+    query string
+    conn *interceptedConn
+}
+
+type interceptedTx struct {
+    tx driver.Tx
+    ctx context.Context
+    conn *interceptedConn
+}
+
+// Rows of an implicitly prepared statement, or of a tracked query.
+// The statement is closed and the query ends with the rows.
+type stmtRows struct {
+    driver.Rows
+    // nil when the statement is not implicitly prepared.
+    stmt driver.Stmt
+    // nil when the query is not tracked.
+    done func()
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Register interceptors for Init(). When Init() was already called,
+// they are added to the current connection too.
+//////////////////////////////////////////////////////////////////////
+func Use(interceptors ...Interceptor) {
+    myInterceptorsMu.Lock()
+    myInterceptors = append(myInterceptors, interceptors...)
+    connector := myConnector
+    myInterceptorsMu.Unlock()
+    if connector != nil {
+        connector.Use(interceptors...)
+    }
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Create an interceptor which is called after each call.
+//////////////////////////////////////////////////////////////////////
+func AfterHook(fn HookFunc) Interceptor {
+    return func(ctx context.Context, call *Call, next Handler) error {
+        start := time.Now()
+        err := next(ctx, call)
+        fn(ctx, call, time.Since(start), err)
+        return err
+    }
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get the operation name of the call: the first keyword of the
+// statement (SELECT, INSERT, ...) or the call type (BEGIN, COMMIT, ...).
+//////////////////////////////////////////////////////////////////////
+func (c *Call) Operation() string {
+    if c.Op == OP_PREPARE {
+        return strings.ToUpper(c.Op)
+    }
+    fields := queryFields(c.Query)
+    if len(fields) == 0 {
+        return strings.ToUpper(c.Op)
+    }
+    return strings.ToUpper(fields[0])
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get the main table of the statement, or "" when it is not found.
+//////////////////////////////////////////////////////////////////////
+func (c *Call) Table() string {
+    fields := queryFields(c.Query)
+    for i := 0; i < len(fields) - 1; i++ {
+        switch strings.ToUpper(fields[i]) {
+        case "FROM", "INTO", "UPDATE", "TABLE":
+            j := i + 1
+            for j < len(fields) - 1 && isTableModifier(fields[j]) {
+                j++
+            }
+            return strings.ReplaceAll(fields[j], "`", "")
+        }
+    }
+    return ""
+}
+
+func queryFields(query string) []string {
+    for {
+        query = strings.TrimLeft(query, " \t\r\n")
+        if !strings.HasPrefix(query, "/*") {
+            break
+        }
+        end := strings.Index(query, "*/")
+        if end < 0 {
+            return nil
+        }
+        query = query[end + 2:]
+    }
+    return strings.FieldsFunc(query, func(r rune) bool {
+        return r == ' ' || r == '\t' || r == '\r' || r == '\n' || r == '(' || r == ')' || r == ',' || r == ';'
+    })
+}
+
+func isTableModifier(field string) bool {
+    switch strings.ToUpper(field) {
+    case "IF", "NOT", "EXISTS", "LOW_PRIORITY", "IGNORE":
+        return true
+    }
+    return false
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Open a database of a registered driver with the interceptors.
+//////////////////////////////////////////////////////////////////////
+func OpenDB(driverName, datasourceName string, interceptors ...Interceptor) (*sql.DB, *Connector, error) {
+    base, err := baseConnector(driverName, datasourceName)
+    if err != nil {
+        return nil, nil, err
+    }
+    connector := NewConnector(base, interceptors...)
+    return sql.OpenDB(connector), connector, nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get the connector of a registered driver.
+//////////////////////////////////////////////////////////////////////
+func baseConnector(driverName, datasourceName string) (driver.Connector, error) {
+    db, err := sql.Open(driverName, datasourceName)
+    if err != nil {
+        return nil, fmt.Errorf("sql.Open() error: %w", err)
+    }
+    d := db.Driver()
+    db.Close()
+    if dc, ok := d.(driver.DriverContext); ok {
+        base, err := dc.OpenConnector(datasourceName)
+        if err != nil {
+            return nil, fmt.Errorf("OpenConnector() error: %w", err)
+        }
+        return base, nil
+    }
+    return &dsnConnector{driver: d, dsn: datasourceName}, nil
+}
+
+func (c *dsnConnector) Connect(ctx context.Context) (driver.Conn, error) {
+    return c.driver.Open(c.dsn)
+}
+
+func (c *dsnConnector) Driver() driver.Driver {
+    return c.driver
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Wrap the connector with the interceptors.
+//////////////////////////////////////////////////////////////////////
+func NewConnector(base driver.Connector, interceptors ...Interceptor) *Connector {
+    return &Connector{
+        base: base,
+        interceptors: append([]Interceptor{}, interceptors...),
+    }
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Add interceptors. They apply to the calls which start afterwards.
+//////////////////////////////////////////////////////////////////////
+func (c *Connector) Use(interceptors ...Interceptor) {
+    c.mu.Lock()
+    c.interceptors = append(append([]Interceptor{}, c.interceptors...), interceptors...)
+    c.mu.Unlock()
+}
+
+func (c *Connector) Connect(ctx context.Context) (driver.Conn, error) {
+    call := &Call{Op: OP_CONNECT}
+    err := c.run(ctx, call, func(ctx context.Context, call *Call) error {
+        var err error
+        call.Conn, err = c.base.Connect(ctx)
+        return err
+    })
+    if err != nil {
+        return nil, err
+    }
+    ic := &interceptedConn{conn: call.Conn, connector: c}
+    if c.tracker != nil {
+        c.tracker.add(ctx, ic)
+    }
+    return ic, nil
+}
+
+func (c *Connector) Driver() driver.Driver {
+    return c.base.Driver()
+}
+
+// Called by (*sql.DB) Close().
+func (c *Connector) Close() error {
+    if closer, ok := c.base.(io.Closer); ok {
+        return closer.Close()
+    }
+    return nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Run the call through the interceptors and then the final handler.
+//////////////////////////////////////////////////////////////////////
+func (c *Connector) run(ctx context.Context, call *Call, final Handler) error {
+    c.mu.RLock()
+    interceptors := c.interceptors
+    c.mu.RUnlock()
+
+    var next func(i int) Handler
+    next = func(i int) Handler {
+        if i == len(interceptors) {
+            return final
+        }
+        return func(ctx context.Context, call *Call) error {
+            return interceptors[i](ctx, call, next(i + 1))
+        }
+    }
+    return next(0)(ctx, call)
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Run a call of the connection, and track it for Shutdown().
+//////////////////////////////////////////////////////////////////////
+func (ic *interceptedConn) run(ctx context.Context, call *Call, final Handler) error {
+    if ic.state == nil {
+        return ic.connector.run(ctx, call, final)
+    }
+    ic.state.start(call)
+    err := ic.connector.run(ctx, call, final)
+    if err == nil && call.Op == OP_QUERY && call.Rows != nil {
+        // The query is running until the rows are read and closed.
+        call.Rows = &stmtRows{Rows: call.Rows, done: func() {
+            ic.state.end(call, nil)
+        }}
+        return nil
+    }
+    ic.state.end(call, err)
+    return err
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// driver.Conn
+//////////////////////////////////////////////////////////////////////
+func (ic *interceptedConn) Prepare(query string) (driver.Stmt, error) {
+    return ic.PrepareContext(context.Background(), query)
+}
+
+func (ic *interceptedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+    call := &Call{Op: OP_PREPARE, Query: query}
+    err := ic.run(ctx, call, func(ctx context.Context, call *Call) error {
+        var err error
+        call.Stmt, err = ic.prepare(ctx, call.Query)
+        return err
+    })
+    if err != nil {
+        return nil, err
+    }
+    return &interceptedStmt{stmt: call.Stmt, query: call.Query, conn: ic}, nil
+}
+
+func (ic *interceptedConn) prepare(ctx context.Context, query string) (driver.Stmt, error) {
+    if p, ok := ic.conn.(driver.ConnPrepareContext); ok {
+        return p.PrepareContext(ctx, query)
+    }
+    return ic.conn.Prepare(query)
+}
+
+func (ic *interceptedConn) Close() error {
+    if ic.connector.tracker != nil {
+        ic.connector.tracker.remove(ic)
+    }
+    return ic.conn.Close()
+}
+
+func (ic *interceptedConn) Begin() (driver.Tx, error) {
+    return ic.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (ic *interceptedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+    call := &Call{Op: OP_BEGIN, TxOptions: opts}
+    err := ic.run(ctx, call, func(ctx context.Context, call *Call) error {
+        var err error
+        if b, ok := ic.conn.(driver.ConnBeginTx); ok {
+            call.Tx, err = b.BeginTx(ctx, call.TxOptions)
+        } else {
+            call.Tx, err = ic.conn.Begin()
+        }
+        return err
+    })
+    if err != nil {
+        return nil, err
+    }
+    return &interceptedTx{tx: call.Tx, ctx: context.WithoutCancel(ctx), conn: ic}, nil
+}
+
+func (ic *interceptedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+    call := &Call{Op: OP_QUERY, Query: query, Args: args}
+    err := ic.run(ctx, call, func(ctx context.Context, call *Call) error {
+        var err error
+        if q, ok := ic.conn.(driver.QueryerContext); ok {
+            call.Rows, err = q.QueryContext(ctx, call.Query, call.Args)
+            if err != driver.ErrSkip {
+                return err
+            }
+        }
+        stmt, err := ic.prepare(ctx, call.Query)
+        if err != nil {
+            return err
+        }
+        rows, err := stmtQuery(ctx, stmt, call.Args)
+        if err != nil {
+            stmt.Close()
+            return err
+        }
+        call.Rows = &stmtRows{Rows: rows, stmt: stmt}
+        return nil
+    })
+    return call.Rows, err
+}
+
+func (ic *interceptedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+    call := &Call{Op: OP_EXEC, Query: query, Args: args}
+    err := ic.run(ctx, call, func(ctx context.Context, call *Call) error {
+        var err error
+        if e, ok := ic.conn.(driver.ExecerContext); ok {
+            call.Result, err = e.ExecContext(ctx, call.Query, call.Args)
+            if err != driver.ErrSkip {
+                return err
+            }
+        }
+        stmt, err := ic.prepare(ctx, call.Query)
+        if err != nil {
+            return err
+        }
+        defer stmt.Close()
+        call.Result, err = stmtExec(ctx, stmt, call.Args)
+        return err
+    })
+    return call.Result, err
+}
+
+func (ic *interceptedConn) Ping(ctx context.Context) error {
+    if p, ok := ic.conn.(driver.Pinger); ok {
+        return p.Ping(ctx)
+    }
+    return nil
+}
+
+func (ic *interceptedConn) ResetSession(ctx context.Context) error {
+    if r, ok := ic.conn.(driver.SessionResetter); ok {
+        return r.ResetSession(ctx)
+    }
+    return nil
+}
+
+func (ic *interceptedConn) IsValid() bool {
+    if v, ok := ic.conn.(driver.Validator); ok {
+        return v.IsValid()
+    }
+    return true
+}
+
+func (ic *interceptedConn) CheckNamedValue(nv *driver.NamedValue) error {
+    if c, ok := ic.conn.(driver.NamedValueChecker); ok {
+        return c.CheckNamedValue(nv)
+    }
+    return driver.ErrSkip
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// driver.Stmt
+//////////////////////////////////////////////////////////////////////
+func (is *interceptedStmt) Close() error {
+    return is.stmt.Close()
+}
+
+func (is *interceptedStmt) NumInput() int {
+    return is.stmt.NumInput()
+}
+
+func (is *interceptedStmt) Exec(args []driver.Value) (driver.Result, error) {
+    return is.ExecContext(context.Background(), namedValues(args))
+}
+
+func (is *interceptedStmt) Query(args []driver.Value) (driver.Rows, error) {
+    return is.QueryContext(context.Background(), namedValues(args))
+}
+
+func (is *interceptedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+    call := &Call{Op: OP_EXEC, Query: is.query, Args: args, Prepared: true}
+    err := is.conn.run(ctx, call, func(ctx context.Context, call *Call) error {
+        var err error
+        call.Result, err = stmtExec(ctx, is.stmt, call.Args)
+        return err
+    })
+    return call.Result, err
+}
+
+func (is *interceptedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+    call := &Call{Op: OP_QUERY, Query: is.query, Args: args, Prepared: true}
+    err := is.conn.run(ctx, call, func(ctx context.Context, call *Call) error {
+        var err error
+        call.Rows, err = stmtQuery(ctx, is.stmt, call.Args)
+        return err
+    })
+    return call.Rows, err
+}
+
+func (is *interceptedStmt) CheckNamedValue(nv *driver.NamedValue) error {
+    if c, ok := is.stmt.(driver.NamedValueChecker); ok {
+        return c.CheckNamedValue(nv)
+    }
+    return is.conn.CheckNamedValue(nv)
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// driver.Tx
+//////////////////////////////////////////////////////////////////////
+func (it *interceptedTx) Commit() error {
+    return it.conn.run(it.ctx, &Call{Op: OP_COMMIT}, func(ctx context.Context, call *Call) error {
+        return it.tx.Commit()
+    })
+}
+
+func (it *interceptedTx) Rollback() error {
+    return it.conn.run(it.ctx, &Call{Op: OP_ROLLBACK}, func(ctx context.Context, call *Call) error {
+        return it.tx.Rollback()
+    })
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// driver.Rows of an implicitly prepared statement or a tracked query
+//////////////////////////////////////////////////////////////////////
+func (r *stmtRows) Close() error {
+    err := r.Rows.Close()
+    if r.stmt != nil {
+        r.stmt.Close()
+    }
+    if r.done != nil {
+        r.done()
+    }
+    return err
+}
+
+func (r *stmtRows) ColumnTypeDatabaseTypeName(index int) string {
+    if t, ok := r.Rows.(driver.RowsColumnTypeDatabaseTypeName); ok {
+        return t.ColumnTypeDatabaseTypeName(index)
+    }
+    return ""
+}
+
+func (r *stmtRows) ColumnTypeNullable(index int) (bool, bool) {
+    if t, ok := r.Rows.(driver.RowsColumnTypeNullable); ok {
+        return t.ColumnTypeNullable(index)
+    }
+    return false, false
+}
+
+func (r *stmtRows) ColumnTypeLength(index int) (int64, bool) {
+    if t, ok := r.Rows.(driver.RowsColumnTypeLength); ok {
+        return t.ColumnTypeLength(index)
+    }
+    return 0, false
+}
+
+func (r *stmtRows) ColumnTypePrecisionScale(index int) (int64, int64, bool) {
+    if t, ok := r.Rows.(driver.RowsColumnTypePrecisionScale); ok {
+        return t.ColumnTypePrecisionScale(index)
+    }
+    return 0, 0, false
+}
+
+func (r *stmtRows) ColumnTypeScanType(index int) reflect.Type {
+    if t, ok := r.Rows.(driver.RowsColumnTypeScanType); ok {
+        return t.ColumnTypeScanType(index)
+    }
+    return reflect.TypeOf(new(interface{})).Elem()
+}
+
+func (r *stmtRows) HasNextResultSet() bool {
+    if n, ok := r.Rows.(driver.RowsNextResultSet); ok {
+        return n.HasNextResultSet()
+    }
+    return false
+}
+
+func (r *stmtRows) NextResultSet() error {
+    if n, ok := r.Rows.(driver.RowsNextResultSet); ok {
+        return n.NextResultSet()
+    }
+    return fmt.Errorf("no more result sets")
+}
+
+
+func stmtQuery(ctx context.Context, stmt driver.Stmt, args []driver.NamedValue) (driver.Rows, error) {
+    if q, ok := stmt.(driver.StmtQueryContext); ok {
+        return q.QueryContext(ctx, args)
+    }
+    return stmt.Query(values(args))
+}
+
+func stmtExec(ctx context.Context, stmt driver.Stmt, args []driver.NamedValue) (driver.Result, error) {
+    if e, ok := stmt.(driver.StmtExecContext); ok {
+        return e.ExecContext(ctx, args)
+    }
+    return stmt.Exec(values(args))
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+    named := make([]driver.NamedValue, len(args))
+    for i, v := range args {
+        named[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+    }
+    return named
+}
+
+func values(args []driver.NamedValue) []driver.Value {
+    result := make([]driver.Value, len(args))
+    for i, nv := range args {
+        result[i] = nv.Value
+    }
+    return result
+}
@@ -0,0 +1,463 @@
+//////////////////////////////////////////////////////////////////////
+// mask.go
+//
+// @usage
+//
+//     1. Import this package.
+//
+//         --------------------------------------------------
+//         import myMySQL "mysql"
+//         --------------------------------------------------
+//
+//     2. Declare the masking rules in a JSON config.
+//
+//         --------------------------------------------------
+//         {
+//             "secret": "change-me",
+//             "rules": [
+//                 {"table": "users", "column": "id", "method": "hash"},
+//                 {"table": "orders", "column": "user_id", "method": "hash"},
+//                 {"table": "users", "column": "email", "method": "email"},
+//                 {"table": "users", "column": "name", "method": "name"},
+//                 {"table": "users", "column": "phone", "method": "keep_format"},
+//                 {"table": "users", "column": "memo", "method": "truncate", "length": 8},
+//                 {"column": "password", "method": "null"}
+//             ]
+//         }
+//         --------------------------------------------------
+//
+//     3. Load it and pass the masker to an export or a dump.
+//
+//         --------------------------------------------------
+//         f, _ := os.Open("mask.json")
+//         config, err := myMySQL.LoadMaskConfig(f)
+//         masker, err := myMySQL.NewMasker(config)
+//         err = myMySQL.Dump(ctx, db, w, &myMySQL.DumpOptions{Masker: masker})
+//         --------------------------------------------------
+//
+//     The masked values are derived from HMAC-SHA256 of the original value
+//     with the secret. The same value is always masked to the same result,
+//     so the foreign key relations still join when the referencing and the
+//     referenced columns use the same method.
+//
+//     MASK_HASH maps the integers from 1 to 10^length - 1 one to one (a keyed
+//     permutation), so masked primary keys stay unique. Other numbers are
+//     hashed and may collide. The length of an integer column defaults to
+//     the digits its type holds, at most DEFAULT_MASK_NUMBER_DIGITS.
+//     Hashed strings are cut to the length of the rule, never to the one of
+//     the column, so give the columns of a foreign key the same length. A
+//     short length makes collisions likely, which break unique keys.
+//
+//     Dump(), Subset() and ExportTable() load the column types from the
+//     schema, and fail when a masked value does not fit its column.
+//
+//
+// MIT License
+//
+// Copyright (c) 2019 noknow.info
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+// PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+// HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+// OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTW//ARE.
+//////////////////////////////////////////////////////////////////////
+package mysql
+
+import (
+    "context"
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/binary"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "io"
+    "math/bits"
+    "strconv"
+    "sync"
+    "time"
+    "unicode"
+)
+
+const (
+    MASK_HASH = "hash"
+    MASK_EMAIL = "email"
+    MASK_NAME = "name"
+    MASK_NULL = "null"
+    MASK_KEEP_FORMAT = "keep_format"
+    MASK_TRUNCATE = "truncate"
+
+    DEFAULT_MASK_NUMBER_DIGITS = 9
+    MASK_EMAIL_DOMAIN = "example.com"
+    // Length of a masked e-mail address: "user_" + 12 hex + "@" + domain.
+    MASK_EMAIL_LENGTH = 5 + 12 + 1 + len(MASK_EMAIL_DOMAIN)
+    // Length of a hex HMAC-SHA256.
+    MASK_HASH_MAX_LENGTH = sha256.Size * 2
+
+    // Feistel rounds of the number permutation.
+    maskRounds = 8
+    // Counters of sum() for the rounds, apart from the other counters.
+    maskRoundCounter = 1 << 24
+)
+
+var (
+    maskFirstNames = []string{
+        "Alex", "Ayaka", "Carlos", "Chen", "Daniel", "Elena", "Fatima", "Hana",
+        "Ivan", "James", "Kenji", "Laura", "Lucas", "Maria", "Mohammed", "Olivia",
+        "Pedro", "Sara", "Sophie", "Thomas", "Wei", "Yuki", "Zoe", "Omar",
+    }
+    maskLastNames = []string{
+        "Brown", "Garcia", "Ivanova", "Johnson", "Kim", "Kowalski", "Li", "Martin",
+        "Meyer", "Muller", "Nguyen", "Rossi", "Sato", "Silva", "Smith", "Suzuki",
+        "Tanaka", "Wang", "Williams", "Yilmaz", "Dubois", "Hansen", "Novak", "Khan",
+    }
+)
+
+type MaskRule struct {
+    // Table name. The rule applies to every table when empty.
+    Table string `json:"table"`
+    Column string `json:"column"`
+    Method string `json:"method"`
+    // Number of characters for MASK_TRUNCATE (required) and MASK_HASH,
+    // number of digits for MASK_HASH on numeric columns.
+    Length int `json:"length"`
+}
+
+type MaskConfig struct {
+    Secret string `json:"secret"`
+    Rules []MaskRule `json:"rules"`
+}
+
+type Masker struct {
+    secret []byte
+    rules map[string]*MaskRule
+    mu sync.Mutex
+    // Types of the columns by table.column.
+    columns map[string]ColumnType
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Load a masking config from JSON.
+//////////////////////////////////////////////////////////////////////
+func LoadMaskConfig(r io.Reader) (*MaskConfig, error) {
+    config := &MaskConfig{}
+    if err := json.NewDecoder(r).Decode(config); err != nil {
+        return nil, fmt.Errorf("json.Decode() error: %w", err)
+    }
+    return config, nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Create a masker from the config.
+//////////////////////////////////////////////////////////////////////
+func NewMasker(config *MaskConfig) (*Masker, error) {
+    if config.Secret == "" {
+        return nil, fmt.Errorf("mask secret is required")
+    }
+    m := &Masker{
+        secret: []byte(config.Secret),
+        rules: make(map[string]*MaskRule),
+    }
+    for i := range config.Rules {
+        rule := config.Rules[i]
+        switch rule.Method {
+        case MASK_HASH, MASK_EMAIL, MASK_NAME, MASK_NULL, MASK_KEEP_FORMAT:
+        case MASK_TRUNCATE:
+            // Length 0 would empty every value.
+            if rule.Length <= 0 {
+                return nil, fmt.Errorf("length is required for the mask method %q of %s.%s", rule.Method, rule.Table, rule.Column)
+            }
+        default:
+            return nil, fmt.Errorf("unknown mask method %q for %s.%s", rule.Method, rule.Table, rule.Column)
+        }
+        if rule.Column == "" {
+            return nil, fmt.Errorf("column is required for the mask method %q", rule.Method)
+        }
+        m.rules[rule.Table + "." + rule.Column] = &rule
+    }
+    return m, nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get the rule of the column. A table specific rule has priority.
+//////////////////////////////////////////////////////////////////////
+func (m *Masker) Rule(table, column string) *MaskRule {
+    if m == nil {
+        return nil
+    }
+    if rule, ok := m.rules[table + "." + column]; ok {
+        return rule
+    }
+    return m.rules["." + column]
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Load the column types of the current database, to check that the
+// masked values fit the columns.
+//////////////////////////////////////////////////////////////////////
+func (m *Masker) LoadColumnTypes(ctx context.Context, q Queryer) error {
+    if m == nil {
+        return nil
+    }
+    types, err := ColumnTypes(ctx, q)
+    if err != nil {
+        return err
+    }
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    if m.columns == nil {
+        m.columns = make(map[string]ColumnType)
+    }
+    for table, columns := range types {
+        for column, t := range columns {
+            m.columns[table + "." + column] = t
+        }
+    }
+    return nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get the rule of the column for a value of the kind. The digits of a
+// hashed integer are bounded by the column type, and an error is
+// returned when the masked values do not fit the column.
+//////////////////////////////////////////////////////////////////////
+func (m *Masker) columnRule(table, column string, kind int) (*MaskRule, error) {
+    rule := m.Rule(table, column)
+    if rule == nil {
+        return nil, nil
+    }
+    m.mu.Lock()
+    t, ok := m.columns[table + "." + column]
+    m.mu.Unlock()
+    if !ok {
+        return rule, nil
+    }
+    if rule.Method == MASK_HASH && kind == kindNumber {
+        digits := t.IntegerDigits()
+        if digits <= 0 || (rule.Length > 0 && rule.Length <= digits) {
+            return rule, nil
+        }
+        if rule.Length > 0 {
+            return nil, fmt.Errorf("%s.%s (%s) is too small for %d masked digits", table, column, t.DataType, rule.Length)
+        }
+        bounded := *rule
+        bounded.Length = DEFAULT_MASK_NUMBER_DIGITS
+        if digits < bounded.Length {
+            bounded.Length = digits
+        }
+        return &bounded, nil
+    }
+    if width := maskWidth(rule); width > 0 && t.Length > 0 && width > t.Length {
+        return nil, fmt.Errorf("%s.%s of length %d is too short for the mask method %q of length %d", table, column, t.Length, rule.Method, width)
+    }
+    return rule, nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get the maximum length of the strings masked by the rule, or 0 when
+// they are not longer than the original ones.
+//////////////////////////////////////////////////////////////////////
+func maskWidth(rule *MaskRule) int {
+    switch rule.Method {
+    case MASK_HASH:
+        if rule.Length > 0 && rule.Length < MASK_HASH_MAX_LENGTH {
+            return rule.Length
+        }
+        return MASK_HASH_MAX_LENGTH
+    case MASK_EMAIL:
+        return MASK_EMAIL_LENGTH
+    case MASK_NAME:
+        width := 0
+        for _, first := range maskFirstNames {
+            for _, last := range maskLastNames {
+                if n := len(first) + 1 + len(last); n > width {
+                    width = n
+                }
+            }
+        }
+        return width
+    }
+    return 0
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Mask a value of the column. NULL is kept as it is.
+//////////////////////////////////////////////////////////////////////
+func (m *Masker) Mask(table, column string, v interface{}) interface{} {
+    rule := m.Rule(table, column)
+    if rule == nil {
+        return v
+    }
+    return m.apply(rule, v)
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Apply the rule to a normalized value.
+//////////////////////////////////////////////////////////////////////
+func (m *Masker) apply(rule *MaskRule, v interface{}) interface{} {
+    if v == nil || rule.Method == MASK_NULL {
+        return nil
+    }
+
+    var text string
+    switch t := v.(type) {
+    case json.Number:
+        if rule.Method == MASK_HASH {
+            return m.hashNumber(string(t), rule.Length)
+        }
+        text = string(t)
+    case []byte:
+        text = string(t)
+    case time.Time:
+        text = t.Format(SQL_DATETIME_LAYOUT)
+    case string:
+        text = t
+    default:
+        text = fmt.Sprint(t)
+    }
+
+    var masked string
+    switch rule.Method {
+    case MASK_HASH:
+        masked = hex.EncodeToString(m.sum(text, 0))
+        if rule.Length > 0 && rule.Length < len(masked) {
+            masked = masked[:rule.Length]
+        }
+    case MASK_EMAIL:
+        masked = "user_" + hex.EncodeToString(m.sum(text, 0))[:12] + "@" + MASK_EMAIL_DOMAIN
+    case MASK_NAME:
+        sum := m.sum(text, 0)
+        masked = maskFirstNames[int(sum[0]) % len(maskFirstNames)] + " " + maskLastNames[int(sum[1]) % len(maskLastNames)]
+    case MASK_KEEP_FORMAT:
+        masked = m.keepFormat(text)
+    case MASK_TRUNCATE:
+        runes := []rune(text)
+        if rule.Length < len(runes) {
+            runes = runes[:rule.Length]
+        }
+        masked = string(runes)
+    }
+
+    switch v.(type) {
+    case []byte:
+        return []byte(masked)
+    case json.Number:
+        if _, err := strconv.ParseFloat(masked, 64); err == nil {
+            return json.Number(masked)
+        }
+    }
+    return masked
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// HMAC-SHA256 of the value. counter derives more independent bytes.
+//////////////////////////////////////////////////////////////////////
+func (m *Masker) sum(text string, counter uint32) []byte {
+    mac := hmac.New(sha256.New, m.secret)
+    var c [4]byte
+    binary.BigEndian.PutUint32(c[:], counter)
+    mac.Write(c[:])
+    mac.Write([]byte(text))
+    return mac.Sum(nil)
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Mask a number to a positive integer of at most digits digits.
+// The integers in the range are permuted, the others are hashed.
+//////////////////////////////////////////////////////////////////////
+func (m *Masker) hashNumber(text string, digits int) json.Number {
+    if digits <= 0 || digits > 18 {
+        digits = DEFAULT_MASK_NUMBER_DIGITS
+    }
+    max := uint64(1)
+    for i := 0; i < digits; i++ {
+        max *= 10
+    }
+    if n, err := strconv.ParseUint(text, 10, 64); err == nil && n > 0 && n < max {
+        return json.Number(strconv.FormatUint(m.permute(n - 1, max - 1) + 1, 10))
+    }
+    n := binary.BigEndian.Uint64(m.sum(text, 0)) % (max - 1) + 1
+    return json.Number(strconv.FormatUint(n, 10))
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Keyed permutation of 0 to size - 1: a Feistel network over the
+// smallest even number of bits, applied again until the result is in
+// the range (cycle walking).
+//////////////////////////////////////////////////////////////////////
+func (m *Masker) permute(x, size uint64) uint64 {
+    width := bits.Len64(size - 1)
+    if width < 2 {
+        width = 2
+    }
+    width += width % 2
+    half := uint(width / 2)
+    mask := uint64(1) << half - 1
+    for {
+        left, right := x >> half, x & mask
+        for round := uint32(0); round < maskRounds; round++ {
+            f := binary.BigEndian.Uint64(m.sum(strconv.FormatUint(right, 10), maskRoundCounter + round))
+            left, right = right, left ^ (f & mask)
+        }
+        x = left << half | right
+        if x < size {
+            return x
+        }
+    }
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Replace letters with letters and digits with digits.
+// The case, the length and the other characters are kept.
+//////////////////////////////////////////////////////////////////////
+func (m *Masker) keepFormat(text string) string {
+    runes := []rune(text)
+    var stream []byte
+    var counter uint32
+    next := func() byte {
+        if len(stream) == 0 {
+            stream = m.sum(text, counter)
+            counter++
+        }
+        b := stream[0]
+        stream = stream[1:]
+        return b
+    }
+    for i, r := range runes {
+        switch {
+        case r >= '0' && r <= '9':
+            runes[i] = rune('0' + next() % 10)
+        case r >= 'a' && r <= 'z':
+            runes[i] = rune('a' + next() % 26)
+        case r >= 'A' && r <= 'Z':
+            runes[i] = rune('A' + next() % 26)
+        case unicode.IsLetter(r):
+            runes[i] = rune('x' + next() % 2)
+        }
+    }
+    return string(runes)
+}
@@ -0,0 +1,127 @@
+package mysql_test
+
+import (
+    "bytes"
+    "database/sql"
+    "encoding/json"
+    "strconv"
+    "strings"
+    "testing"
+    myMySQL "mysql"
+    "mysql/mysqltest/embedded"
+)
+
+func TestMaskHashNumberIsOneToOne(t *testing.T) {
+    masker, err := myMySQL.NewMasker(&myMySQL.MaskConfig{
+        Secret: "secret",
+        Rules: []myMySQL.MaskRule{{Table: "users", Column: "id", Method: myMySQL.MASK_HASH, Length: 3}},
+    })
+    if err != nil {
+        t.Fatalf("mysql.NewMasker() error: %s", err)
+    }
+    seen := make(map[json.Number]int)
+    for i := 1; i < 1000; i++ {
+        masked, ok := masker.Mask("users", "id", json.Number(strconv.Itoa(i))).(json.Number)
+        if !ok {
+            t.Fatalf("Mask(%d) is not a number", i)
+        }
+        n, err := strconv.Atoi(string(masked))
+        if err != nil || n < 1 || n > 999 {
+            t.Fatalf("Mask(%d) = %s, want 1 to 999", i, masked)
+        }
+        if j, ok := seen[masked]; ok {
+            t.Fatalf("Mask(%d) = Mask(%d) = %s", i, j, masked)
+        }
+        seen[masked] = i
+    }
+    if again := masker.Mask("users", "id", json.Number("42")); again != masker.Mask("users", "id", json.Number("42")) {
+        t.Errorf("Mask(42) is not stable")
+    }
+}
+
+func TestMaskColumnTypes(t *testing.T) {
+    setup := func(db *sql.DB) error {
+        for _, query := range []string{
+            "CREATE TABLE users (id TINYINT NOT NULL, code VARCHAR(16) NOT NULL, email VARCHAR(20) NOT NULL, PRIMARY KEY(id))",
+            "CREATE TABLE orders (id INT NOT NULL, user_code VARCHAR(8) NOT NULL, quantity SMALLINT NOT NULL, PRIMARY KEY(id))",
+            "INSERT INTO users VALUES (1, 'abc', 'a@example.com'), (2, 'def', 'd@example.com')",
+            "INSERT INTO orders VALUES (1, 'abc', 7)",
+        } {
+            if _, err := db.Exec(query); err != nil {
+                return err
+            }
+        }
+        return nil
+    }
+    db := embedded.NewDB(t, &embedded.Options{Setup: []func(db *sql.DB) error{setup}})
+    export := func(t *testing.T, table string, rules ...myMySQL.MaskRule) ([]map[string]interface{}, error) {
+        masker, err := myMySQL.NewMasker(&myMySQL.MaskConfig{Secret: "secret", Rules: rules})
+        if err != nil {
+            t.Fatalf("mysql.NewMasker() error: %s", err)
+        }
+        var b bytes.Buffer
+        if err := myMySQL.ExportTable(db, &b, table, &myMySQL.ExportOptions{Format: myMySQL.FORMAT_JSON, Masker: masker}); err != nil {
+            return nil, err
+        }
+        var rows []map[string]interface{}
+        if err := json.Unmarshal(b.Bytes(), &rows); err != nil {
+            t.Fatalf("json.Unmarshal() error: %s", err)
+        }
+        return rows, nil
+    }
+
+    t.Run("foreign key with another length", func(t *testing.T) {
+        rule := myMySQL.MaskRule{Column: "code", Method: myMySQL.MASK_HASH, Length: 8}
+        users, err := export(t, "users", rule)
+        if err != nil {
+            t.Fatalf("ExportTable() error: %s", err)
+        }
+        rule.Column = "user_code"
+        orders, err := export(t, "orders", rule)
+        if err != nil {
+            t.Fatalf("ExportTable() error: %s", err)
+        }
+        code, _ := users[0]["code"].(string)
+        if len(code) != 8 || code == "abc" || code != orders[0]["user_code"] {
+            t.Errorf("users.code = %q, orders.user_code = %v, want the same 8 hashed characters", code, orders[0]["user_code"])
+        }
+    })
+    t.Run("integer bounded by the type", func(t *testing.T) {
+        users, err := export(t, "users", myMySQL.MaskRule{Table: "users", Column: "id", Method: myMySQL.MASK_HASH})
+        if err != nil {
+            t.Fatalf("ExportTable() error: %s", err)
+        }
+        a, _ := users[0]["id"].(float64)
+        b, _ := users[1]["id"].(float64)
+        if a < 1 || a > 99 || b < 1 || b > 99 || a == b {
+            t.Errorf("masked ids = %v, %v, want two TINYINT values of 2 digits", a, b)
+        }
+    })
+    errorTests := []struct {
+        name string
+        table string
+        rule myMySQL.MaskRule
+        err string
+    }{
+        {"hash longer than the column", "orders", myMySQL.MaskRule{Column: "user_code", Method: myMySQL.MASK_HASH}, "too short"},
+        {"email longer than the column", "users", myMySQL.MaskRule{Column: "email", Method: myMySQL.MASK_EMAIL}, "too short"},
+        {"digits more than the type", "orders", myMySQL.MaskRule{Column: "quantity", Method: myMySQL.MASK_HASH, Length: 5}, "too small"},
+    }
+    for _, tt := range errorTests {
+        t.Run(tt.name, func(t *testing.T) {
+            if _, err := export(t, tt.table, tt.rule); err == nil || !strings.Contains(err.Error(), tt.err) {
+                t.Errorf("ExportTable() error = %v, want %q", err, tt.err)
+            }
+        })
+    }
+}
+
+func TestNewMaskerTruncateLength(t *testing.T) {
+    _, err := myMySQL.NewMasker(&myMySQL.MaskConfig{
+        Secret: "secret",
+        Rules: []myMySQL.MaskRule{{Column: "memo", Method: myMySQL.MASK_TRUNCATE}},
+    })
+    if err == nil {
+        t.Error("NewMasker() with a truncate rule without length succeeded")
+    }
+}
@@ -16,6 +16,14 @@
 //         myMySQL.Init(driverName)
 //         --------------------------------------------------
 //
+//         With several hosts, the connections follow the writable one (see failover.go).
+//
+//         --------------------------------------------------
+//         driverName := "DB_USER:DB_PASS@tcp(db1:3306,db2:3306)/DB_NAME?parseTime=true"
+//         --------------------------------------------------
+//
+//         For TLS, add a config to the DSN with WithTLS() (see tls.go).
+//
 //     3. Now, you can use it!!
 //
 //         3-1. When getting a connection.
@@ -24,6 +32,12 @@
 //             db := myMySQL.Conn()
 //             --------------------------------------------------
 //
+//     4. Shut it down gracefully on exit (see shutdown.go).
+//
+//         --------------------------------------------------
+//         report, err := myMySQL.Shutdown(ctx)
+//         --------------------------------------------------
+//
 //
 // MIT License
 //
@@ -51,6 +65,7 @@ package mysql
 import (
     _ "github.com/go-sql-driver/mysql"
     "database/sql"
+    "database/sql/driver"
     "log"
 )
 
@@ -60,6 +75,7 @@ const (
 
 var (
     myDb *sql.DB
+    myConnector *Connector
 )
 
 
@@ -67,24 +83,50 @@ var (
 // Initialize database.
 //////////////////////////////////////////////////////////////////////
 func Init(datasourceName string) {
-    db, err := sql.Open(DRIVER_NAME, datasourceName)
+    var base driver.Connector
+    var err error
+    if IsMultiHostDSN(datasourceName) {
+        base, err = NewFailoverConnector(datasourceName, nil)
+    } else {
+        base, err = baseConnector(DRIVER_NAME, datasourceName)
+    }
     if err != nil {
-        log.Fatalf("[FATAL] sql.Open() error: %s\n", err)
+        log.Fatalf("[FATAL] connector error: %s\n", err)
     }
+    InitConnector(base)
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Initialize database with a connector, e.g. a failover connector.
+//////////////////////////////////////////////////////////////////////
+func InitConnector(base driver.Connector) {
+    myInterceptorsMu.Lock()
+    interceptors := append([]Interceptor{}, myInterceptors...)
+    myInterceptorsMu.Unlock()
+    connector := NewConnector(base, interceptors...)
+    connector.tracker = newConnTracker()
+    db := sql.OpenDB(connector)
     if err := db.Ping(); err != nil {
         db.Close()
         log.Fatalf("[FATAL] (*sql.DB) Ping() error: %s\n", err)
         return
     }
     myDb = db
+    myInterceptorsMu.Lock()
+    myConnector = connector
+    myInterceptorsMu.Unlock()
+    Register(DEFAULT_CONNECTION_NAME, db)
 }
 
 
 //////////////////////////////////////////////////////////////////////
-// Close database.
+// Close database. The connections in use are closed when they are
+// released. Use Shutdown() to wait for them.
 //////////////////////////////////////////////////////////////////////
 func Close() {
     if myDb != nil {
+        Unregister(DEFAULT_CONNECTION_NAME)
         myDb.Close()
     }
 }
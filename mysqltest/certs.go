@@ -0,0 +1,213 @@
+//////////////////////////////////////////////////////////////////////
+// certs.go
+//
+// @usage
+//
+//     1. Generate a local CA with a server and a client certificate in
+//        your test.
+//
+//         --------------------------------------------------
+//         certs := mysqltest.GenerateCertsT(t, "localhost", "127.0.0.1")
+//         serverConfig, err := certs.ServerTLSConfig()
+//         --------------------------------------------------
+//
+//     2. Serve serverConfig from the test server and connect with them.
+//
+//         --------------------------------------------------
+//         datasourceName, err := myMySQL.WithTLS(datasourceName, &myMySQL.TLSOptions{
+//             CAFile: certs.CAFile,
+//             CertFile: certs.ClientCertFile,
+//             KeyFile: certs.ClientKeyFile,
+//         })
+//         --------------------------------------------------
+//
+//     The certificates are valid for a day. Call GenerateCerts() again on
+//     the same directory to test a rotation.
+//
+//
+// MIT License
+//
+// Copyright (c) 2019 noknow.info
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+// PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+// HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+// OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTW//ARE.
+//////////////////////////////////////////////////////////////////////
+package mysqltest
+
+import (
+    "crypto/ecdsa"
+    "crypto/elliptic"
+    "crypto/rand"
+    "crypto/tls"
+    "crypto/x509"
+    "crypto/x509/pkix"
+    "encoding/pem"
+    "fmt"
+    "math/big"
+    "net"
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+)
+
+const (
+    CERT_VALIDITY = 24 * time.Hour
+)
+
+type Certs struct {
+    CAFile string
+    ServerCertFile string
+    ServerKeyFile string
+    ClientCertFile string
+    ClientKeyFile string
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Write a new CA, a server certificate for the hosts, and a client
+// certificate as PEM files in dir. hosts defaults to localhost and
+// 127.0.0.1.
+//////////////////////////////////////////////////////////////////////
+func GenerateCerts(dir string, hosts ...string) (*Certs, error) {
+    if len(hosts) == 0 {
+        hosts = []string{"localhost", "127.0.0.1"}
+    }
+    c := &Certs{
+        CAFile: filepath.Join(dir, "ca.pem"),
+        ServerCertFile: filepath.Join(dir, "server-cert.pem"),
+        ServerKeyFile: filepath.Join(dir, "server-key.pem"),
+        ClientCertFile: filepath.Join(dir, "client-cert.pem"),
+        ClientKeyFile: filepath.Join(dir, "client-key.pem"),
+    }
+
+    caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+    if err != nil {
+        return nil, fmt.Errorf("ecdsa.GenerateKey() error: %w", err)
+    }
+    caTemplate := certTemplate("mysqltest CA")
+    caTemplate.IsCA = true
+    caTemplate.BasicConstraintsValid = true
+    caTemplate.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+    caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+    if err != nil {
+        return nil, fmt.Errorf("x509.CreateCertificate() error: %w", err)
+    }
+    ca, err := x509.ParseCertificate(caDER)
+    if err != nil {
+        return nil, fmt.Errorf("x509.ParseCertificate() error: %w", err)
+    }
+    if err := writePEM(c.CAFile, "CERTIFICATE", caDER); err != nil {
+        return nil, err
+    }
+
+    serverTemplate := certTemplate(hosts[0])
+    serverTemplate.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+    for _, host := range hosts {
+        if ip := net.ParseIP(host); ip != nil {
+            serverTemplate.IPAddresses = append(serverTemplate.IPAddresses, ip)
+        } else {
+            serverTemplate.DNSNames = append(serverTemplate.DNSNames, host)
+        }
+    }
+    if err := writeCert(c.ServerCertFile, c.ServerKeyFile, serverTemplate, ca, caKey); err != nil {
+        return nil, err
+    }
+
+    clientTemplate := certTemplate("mysqltest client")
+    clientTemplate.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+    if err := writeCert(c.ClientCertFile, c.ClientKeyFile, clientTemplate, ca, caKey); err != nil {
+        return nil, err
+    }
+    return c, nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Generate the certificates in a temporary directory of the test.
+//////////////////////////////////////////////////////////////////////
+func GenerateCertsT(t testing.TB, hosts ...string) *Certs {
+    t.Helper()
+    c, err := GenerateCerts(t.TempDir(), hosts...)
+    if err != nil {
+        t.Fatalf("mysqltest.GenerateCerts() error: %s", err)
+    }
+    return c
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get a server TLS config with the server certificate, which verifies
+// the client certificates given by the clients.
+//////////////////////////////////////////////////////////////////////
+func (c *Certs) ServerTLSConfig() (*tls.Config, error) {
+    cert, err := tls.LoadX509KeyPair(c.ServerCertFile, c.ServerKeyFile)
+    if err != nil {
+        return nil, fmt.Errorf("tls.LoadX509KeyPair() error: %w", err)
+    }
+    data, err := os.ReadFile(c.CAFile)
+    if err != nil {
+        return nil, fmt.Errorf("os.ReadFile() error: %w", err)
+    }
+    clientCAs := x509.NewCertPool()
+    clientCAs.AppendCertsFromPEM(data)
+    return &tls.Config{
+        Certificates: []tls.Certificate{cert},
+        ClientCAs: clientCAs,
+        ClientAuth: tls.VerifyClientCertIfGiven,
+        MinVersion: tls.VersionTLS12,
+    }, nil
+}
+
+func certTemplate(commonName string) *x509.Certificate {
+    serial, _ := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 62))
+    now := time.Now()
+    return &x509.Certificate{
+        SerialNumber: serial,
+        Subject: pkix.Name{CommonName: commonName},
+        NotBefore: now.Add(-time.Minute),
+        NotAfter: now.Add(CERT_VALIDITY),
+        KeyUsage: x509.KeyUsageDigitalSignature,
+    }
+}
+
+func writeCert(certFile, keyFile string, template, ca *x509.Certificate, caKey *ecdsa.PrivateKey) error {
+    key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+    if err != nil {
+        return fmt.Errorf("ecdsa.GenerateKey() error: %w", err)
+    }
+    der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+    if err != nil {
+        return fmt.Errorf("x509.CreateCertificate() error: %w", err)
+    }
+    keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+    if err != nil {
+        return fmt.Errorf("x509.MarshalPKCS8PrivateKey() error: %w", err)
+    }
+    if err := writePEM(keyFile, "PRIVATE KEY", keyDER); err != nil {
+        return err
+    }
+    return writePEM(certFile, "CERTIFICATE", der)
+}
+
+func writePEM(path, blockType string, der []byte) error {
+    data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+    if err := os.WriteFile(path, data, 0600); err != nil {
+        return fmt.Errorf("os.WriteFile() error: %w", err)
+    }
+    return nil
+}
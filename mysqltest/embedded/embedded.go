@@ -0,0 +1,208 @@
+//////////////////////////////////////////////////////////////////////
+// embedded.go
+//
+// @usage
+//
+//     1. Import this package in your test.
+//
+//         --------------------------------------------------
+//         import "mysql/mysqltest/embedded"
+//         --------------------------------------------------
+//
+//     2. Register the migrations and the seeds of your package once.
+//        They are applied to every new database.
+//
+//         --------------------------------------------------
+//         func TestMain(m *testing.M) {
+//             embedded.RegisterSetup(func(db *sql.DB) error {
+//                 myCountries.Init(db)
+//                 return nil
+//             })
+//             os.Exit(m.Run())
+//         }
+//         --------------------------------------------------
+//
+//     3. Get an isolated database in each test.
+//
+//         --------------------------------------------------
+//         db := embedded.NewDB(t, nil)
+//         --------------------------------------------------
+//
+//        Or get the DSN to open it yourself, e.g. with interceptors.
+//
+//         --------------------------------------------------
+//         db, connector, err := myMySQL.OpenDB("mysql", embedded.NewDSN(t, nil), interceptor)
+//         --------------------------------------------------
+//
+//     Each database is served by its own in-process MySQL protocol server
+//     (go-mysql-server with the in-memory backend) on a random local port,
+//     or on a unix socket with Options.UnixSocket. The server is shut down
+//     on t.Cleanup.
+//
+//     Note that packages like countries keep the *sql.DB given to Init in
+//     a package variable, so the last initialized database is the one they use.
+//
+//
+// MIT License
+//
+// Copyright (c) 2019 noknow.info
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+// PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+// HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+// OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTW//ARE.
+//////////////////////////////////////////////////////////////////////
+package embedded
+
+import (
+    "database/sql"
+    "fmt"
+    "net"
+    "path/filepath"
+    "sync"
+    "testing"
+    "time"
+    sqle "github.com/dolthub/go-mysql-server"
+    "github.com/dolthub/go-mysql-server/memory"
+    "github.com/dolthub/go-mysql-server/server"
+    gmsql "github.com/dolthub/go-mysql-server/sql"
+    _ "github.com/go-sql-driver/mysql"
+)
+
+const (
+    DRIVER_NAME = "mysql"
+    DATABASE_NAME = "test"
+    PING_TIMEOUT = 5 * time.Second
+)
+
+var (
+    setupsMu sync.Mutex
+    setups []func(db *sql.DB) error
+)
+
+type Options struct {
+    // Listen on a unix socket instead of a random local TCP port.
+    UnixSocket bool
+    // Name of the database. Defaults to DATABASE_NAME.
+    Database string
+    // Applied after the registered setups.
+    Setup []func(db *sql.DB) error
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Register a setup (migrations, seeds) for every new database.
+//////////////////////////////////////////////////////////////////////
+func RegisterSetup(fn func(db *sql.DB) error) {
+    setupsMu.Lock()
+    setups = append(setups, fn)
+    setupsMu.Unlock()
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Start a server and get a ready database.
+// The server is shut down on t.Cleanup.
+//////////////////////////////////////////////////////////////////////
+func NewDB(t testing.TB, opts *Options) *sql.DB {
+    t.Helper()
+    db, err := sql.Open(DRIVER_NAME, NewDSN(t, opts))
+    if err != nil {
+        t.Fatalf("sql.Open() error: %s", err)
+    }
+    t.Cleanup(func() {
+        db.Close()
+    })
+    return db
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Start a server with a ready database and get its DSN, e.g. to open
+// it with other parameters. The server is shut down on t.Cleanup.
+//////////////////////////////////////////////////////////////////////
+func NewDSN(t testing.TB, opts *Options) string {
+    t.Helper()
+    o := Options{}
+    if opts != nil {
+        o = *opts
+    }
+    if o.Database == "" {
+        o.Database = DATABASE_NAME
+    }
+
+    database := memory.NewDatabase(o.Database)
+    database.BaseDatabase.EnablePrimaryKeyIndexes()
+    provider := memory.NewDBProvider(database)
+    engine := sqle.NewDefault(provider)
+
+    var listener net.Listener
+    var err error
+    var dsn string
+    if o.UnixSocket {
+        socket := filepath.Join(t.TempDir(), "mysql.sock")
+        if listener, err = net.Listen("unix", socket); err != nil {
+            t.Fatalf("net.Listen() error: %s", err)
+        }
+        dsn = fmt.Sprintf("root@unix(%s)/%s?parseTime=true", socket, o.Database)
+    } else {
+        if listener, err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+            t.Fatalf("net.Listen() error: %s", err)
+        }
+        dsn = fmt.Sprintf("root@tcp(%s)/%s?parseTime=true", listener.Addr().String(), o.Database)
+    }
+
+    config := server.Config{
+        Protocol: listener.Addr().Network(),
+        Address: listener.Addr().String(),
+        Listener: listener,
+    }
+    s, err := server.NewServer(config, engine, gmsql.NewContext, memory.NewSessionBuilder(provider), nil)
+    if err != nil {
+        listener.Close()
+        t.Fatalf("server.NewServer() error: %s", err)
+    }
+    go s.Start()
+    t.Cleanup(func() {
+        s.Close()
+    })
+
+    db, err := sql.Open(DRIVER_NAME, dsn)
+    if err != nil {
+        t.Fatalf("sql.Open() error: %s", err)
+    }
+    defer db.Close()
+    deadline := time.Now().Add(PING_TIMEOUT)
+    for {
+        err := db.Ping()
+        if err == nil {
+            break
+        }
+        if time.Now().After(deadline) {
+            t.Fatalf("(*sql.DB) Ping() error: %s", err)
+        }
+        time.Sleep(10 * time.Millisecond)
+    }
+
+    setupsMu.Lock()
+    all := append(append([]func(db *sql.DB) error{}, setups...), o.Setup...)
+    setupsMu.Unlock()
+    for i, fn := range all {
+        if err := fn(db); err != nil {
+            t.Fatalf("setup %d error: %s", i + 1, err)
+        }
+    }
+    return dsn
+}
@@ -0,0 +1,487 @@
+//////////////////////////////////////////////////////////////////////
+// fixtures.go
+//
+// @usage
+//
+//     1. Write the fixtures in YAML (or JSON) files. The top level keys are
+//        table names, the rows are a list or a mapping of named rows.
+//
+//         --------------------------------------------------
+//         # fixtures/countries.yml
+//         countries:
+//           japan:
+//             country_code: JP
+//             ar: اليابان
+//             de: Japan
+//             en: Japan
+//             es: Japón
+//             fr: Japon
+//             ja: 日本
+//             pt: Japão
+//             ru: Япония
+//             zh_cn: 日本
+//             zh_tw: 日本
+//             continent: 2
+//             status: 1
+//
+//         # fixtures/users.yml
+//         users:
+//           alice:
+//             id: '{{ seq "users" }}'
+//             country_code: '{{ ref "countries.japan.country_code" }}'
+//             created_at: '{{ now }}'
+//         posts:
+//           - user_id: '{{ ref "users.alice.id" }}'
+//             tags: [go, mysql]
+//         --------------------------------------------------
+//
+//     2. Load them in the test. The tables in the fixtures are truncated
+//        first, so the countries table only has the countries above.
+//
+//         --------------------------------------------------
+//         //go:embed fixtures
+//         var fixtures embed.FS
+//
+//         func TestSomething(t *testing.T) {
+//             mysqltest.LoadFixturesT(t, db, fixtures, "fixtures/*.yml")
+//         }
+//         --------------------------------------------------
+//
+//     LoadFixturesT puts the original rows of the tables back on t.Cleanup.
+//     With LoadFixtures, call (*Fixtures).Load() again to reset the tables.
+//
+//     The values are templates with these functions:
+//         now                     current time in UTC as "2006-01-02 15:04:05"
+//         seq "name"              1, 2, 3, ... per name
+//         ref "table.row.column"  value of a named row loaded before. When
+//                                 the column was not given, the last insert id.
+//         base64 "text"           binary value of the base64 text.
+//
+//     The times are in UTC: the TIMESTAMP values are loaded in the session
+//     time zone '+00:00'.
+//
+//     mysql.Subset() with FORMAT_FIXTURE writes a fixture in this format.
+//
+//
+// MIT License
+//
+// Copyright (c) 2019 noknow.info
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+// PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+// HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+// OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTW//ARE.
+//////////////////////////////////////////////////////////////////////
+package mysqltest
+
+import (
+    "bytes"
+    "context"
+    "database/sql"
+    "encoding/base64"
+    "encoding/json"
+    "fmt"
+    "io/fs"
+    "strings"
+    "testing"
+    "text/template"
+    "time"
+    "gopkg.in/yaml.v3"
+    myMySQL "mysql"
+)
+
+const (
+    FIXTURE_TIME_LAYOUT = "2006-01-02 15:04:05"
+)
+
+type Fixtures struct {
+    db *sql.DB
+    tables []string
+    rows []fixtureRow
+}
+
+type fixtureRow struct {
+    file string
+    table string
+    // Empty for the rows in a list.
+    name string
+    columns []string
+    values []interface{}
+}
+
+type fixtureState struct {
+    now time.Time
+    seqs map[string]int64
+    // "table.name" -> column -> value
+    named map[string]map[string]interface{}
+    insertIds map[string]int64
+}
+
+type tableSnapshot struct {
+    table string
+    columns []string
+    rows [][]interface{}
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Parse the fixture files matching the patterns and load them.
+//////////////////////////////////////////////////////////////////////
+func LoadFixtures(db *sql.DB, fsys fs.FS, patterns ...string) (*Fixtures, error) {
+    rows, tables, err := parseFixtureFiles(fsys, patterns)
+    if err != nil {
+        return nil, err
+    }
+    f := &Fixtures{db: db, tables: tables, rows: rows}
+    if err := f.Load(); err != nil {
+        return nil, err
+    }
+    return f, nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Load the fixtures and put the original rows back on t.Cleanup.
+//////////////////////////////////////////////////////////////////////
+func LoadFixturesT(t testing.TB, db *sql.DB, fsys fs.FS, patterns ...string) *Fixtures {
+    t.Helper()
+    rows, tables, err := parseFixtureFiles(fsys, patterns)
+    if err != nil {
+        t.Fatalf("mysqltest.LoadFixtures() error: %s", err)
+    }
+    snapshots, err := snapshotTables(db, tables)
+    if err != nil {
+        t.Fatalf("mysqltest.LoadFixtures() error: %s", err)
+    }
+    t.Cleanup(func() {
+        if err := restoreTables(db, snapshots); err != nil {
+            t.Errorf("mysqltest: restoring the fixture tables error: %s", err)
+        }
+    })
+    f := &Fixtures{db: db, tables: tables, rows: rows}
+    if err := f.Load(); err != nil {
+        t.Fatalf("mysqltest.LoadFixtures() error: %s", err)
+    }
+    return f
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Tables in the fixtures in the order of appearance.
+//////////////////////////////////////////////////////////////////////
+func (f *Fixtures) Tables() []string {
+    return f.tables
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Truncate the tables and insert the rows.
+// The foreign key checks are disabled while loading, and the session
+// time zone is UTC like in the output of mysql.Dump() and mysql.Subset().
+//////////////////////////////////////////////////////////////////////
+func (f *Fixtures) Load() error {
+    ctx := context.Background()
+    conn, err := f.db.Conn(ctx)
+    if err != nil {
+        return fmt.Errorf("db.Conn() error: %w", err)
+    }
+    defer conn.Close()
+    if _, err := conn.ExecContext(ctx, "SET FOREIGN_KEY_CHECKS=0"); err != nil {
+        return fmt.Errorf("conn.ExecContext() error: %w", err)
+    }
+    defer conn.ExecContext(ctx, "SET FOREIGN_KEY_CHECKS=1")
+    if _, err := conn.ExecContext(ctx, "SET @OLD_TIME_ZONE=@@TIME_ZONE, TIME_ZONE='+00:00'"); err != nil {
+        return fmt.Errorf("conn.ExecContext() error: %w", err)
+    }
+    defer conn.ExecContext(ctx, "SET TIME_ZONE=@OLD_TIME_ZONE")
+
+    for _, table := range f.tables {
+        if _, err := conn.ExecContext(ctx, "TRUNCATE TABLE " + myMySQL.QuoteIdentifier(table)); err != nil {
+            return fmt.Errorf("TRUNCATE TABLE %s error: %w", table, err)
+        }
+    }
+
+    state := &fixtureState{
+        now: time.Now(),
+        seqs: make(map[string]int64),
+        named: make(map[string]map[string]interface{}),
+        insertIds: make(map[string]int64),
+    }
+    for _, row := range f.rows {
+        values := make([]interface{}, len(row.values))
+        for i, v := range row.values {
+            if values[i], err = state.render(v); err != nil {
+                return fmt.Errorf("%s: %s.%s: %w", row.file, row.table, row.columns[i], err)
+            }
+        }
+        placeholders := strings.TrimSuffix(strings.Repeat("?,", len(values)), ",")
+        query := "INSERT INTO " + myMySQL.QuoteIdentifier(row.table) + " (" + strings.Join(quoteIdentifiers(row.columns), ",") + ") VALUES (" + placeholders + ")"
+        result, err := conn.ExecContext(ctx, query, values...)
+        if err != nil {
+            return fmt.Errorf("%s: inserting into %s error: %w", row.file, row.table, err)
+        }
+        if row.name != "" {
+            key := row.table + "." + row.name
+            columns := make(map[string]interface{})
+            for i, column := range row.columns {
+                columns[column] = values[i]
+            }
+            state.named[key] = columns
+            if id, err := result.LastInsertId(); err == nil {
+                state.insertIds[key] = id
+            }
+        }
+    }
+    return nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Render a templated value.
+//////////////////////////////////////////////////////////////////////
+func (s *fixtureState) render(v interface{}) (interface{}, error) {
+    text, ok := v.(string)
+    if !ok || !strings.Contains(text, "{{") {
+        return v, nil
+    }
+    tmpl, err := template.New("value").Funcs(template.FuncMap{
+        "now": func() string {
+            return s.now.UTC().Format(FIXTURE_TIME_LAYOUT)
+        },
+        "base64": func(text string) (string, error) {
+            b, err := base64.StdEncoding.DecodeString(text)
+            return string(b), err
+        },
+        "seq": func(name string) int64 {
+            s.seqs[name]++
+            return s.seqs[name]
+        },
+        "ref": func(path string) (interface{}, error) {
+            i := strings.LastIndex(path, ".")
+            if i < 0 {
+                return nil, fmt.Errorf("ref %q is not table.row.column", path)
+            }
+            key, column := path[:i], path[i + 1:]
+            columns, ok := s.named[key]
+            if !ok {
+                return nil, fmt.Errorf("ref %q: row %s is not loaded yet", path, key)
+            }
+            if value, ok := columns[column]; ok {
+                return value, nil
+            }
+            return s.insertIds[key], nil
+        },
+    }).Parse(text)
+    if err != nil {
+        return nil, err
+    }
+    var b bytes.Buffer
+    if err := tmpl.Execute(&b, nil); err != nil {
+        return nil, err
+    }
+    return b.String(), nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Parse a fixture file.
+//////////////////////////////////////////////////////////////////////
+func parseFixtures(file string, data []byte) ([]fixtureRow, error) {
+    var doc yaml.Node
+    if err := yaml.Unmarshal(data, &doc); err != nil {
+        return nil, fmt.Errorf("%s: yaml.Unmarshal() error: %w", file, err)
+    }
+    if len(doc.Content) == 0 {
+        return nil, nil
+    }
+    root := doc.Content[0]
+    if root.Kind != yaml.MappingNode {
+        return nil, fmt.Errorf("%s: the top level must be a mapping of table names", file)
+    }
+
+    var result []fixtureRow
+    for i := 0; i + 1 < len(root.Content); i += 2 {
+        table := root.Content[i].Value
+        rows := root.Content[i + 1]
+        switch rows.Kind {
+        case yaml.SequenceNode:
+            for _, node := range rows.Content {
+                row, err := parseFixtureRow(file, table, "", node)
+                if err != nil {
+                    return nil, err
+                }
+                result = append(result, row)
+            }
+        case yaml.MappingNode:
+            for j := 0; j + 1 < len(rows.Content); j += 2 {
+                row, err := parseFixtureRow(file, table, rows.Content[j].Value, rows.Content[j + 1])
+                if err != nil {
+                    return nil, err
+                }
+                result = append(result, row)
+            }
+        default:
+            return nil, fmt.Errorf("%s: rows of %s must be a list or a mapping", file, table)
+        }
+    }
+    return result, nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Parse a row. Nested values are stored as JSON.
+//////////////////////////////////////////////////////////////////////
+func parseFixtureRow(file, table, name string, node *yaml.Node) (fixtureRow, error) {
+    row := fixtureRow{file: file, table: table, name: name}
+    if node.Kind != yaml.MappingNode {
+        return row, fmt.Errorf("%s:%d: a row of %s must be a mapping", file, node.Line, table)
+    }
+    for i := 0; i + 1 < len(node.Content); i += 2 {
+        var v interface{}
+        if value := node.Content[i + 1]; value.Kind == yaml.ScalarNode && value.Tag == "!!float" {
+            // As written, e.g. DECIMAL(30,10).
+            v = value.Value
+        } else if err := value.Decode(&v); err != nil {
+            return row, fmt.Errorf("%s:%d: %w", file, value.Line, err)
+        }
+        switch v.(type) {
+        case map[string]interface{}, []interface{}:
+            b, err := json.Marshal(v)
+            if err != nil {
+                return row, fmt.Errorf("%s:%d: %w", file, node.Content[i + 1].Line, err)
+            }
+            v = string(b)
+        }
+        row.columns = append(row.columns, node.Content[i].Value)
+        row.values = append(row.values, v)
+    }
+    return row, nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Parse the fixture files matching the patterns.
+// The tables are returned in the order of appearance.
+//////////////////////////////////////////////////////////////////////
+func parseFixtureFiles(fsys fs.FS, patterns []string) ([]fixtureRow, []string, error) {
+    var result []fixtureRow
+    var tables []string
+    seen := make(map[string]bool)
+    for _, pattern := range patterns {
+        files, err := fs.Glob(fsys, pattern)
+        if err != nil {
+            return nil, nil, fmt.Errorf("fs.Glob() error: %w", err)
+        }
+        if len(files) == 0 {
+            return nil, nil, fmt.Errorf("no fixture files match %q", pattern)
+        }
+        for _, file := range files {
+            data, err := fs.ReadFile(fsys, file)
+            if err != nil {
+                return nil, nil, fmt.Errorf("fs.ReadFile() error: %w", err)
+            }
+            rows, err := parseFixtures(file, data)
+            if err != nil {
+                return nil, nil, err
+            }
+            for _, row := range rows {
+                if !seen[row.table] {
+                    seen[row.table] = true
+                    tables = append(tables, row.table)
+                }
+            }
+            result = append(result, rows...)
+        }
+    }
+    return result, tables, nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Save all rows of the tables.
+//////////////////////////////////////////////////////////////////////
+func snapshotTables(db *sql.DB, tables []string) ([]tableSnapshot, error) {
+    var result []tableSnapshot
+    for _, table := range tables {
+        rows, err := db.Query("SELECT * FROM " + myMySQL.QuoteIdentifier(table))
+        if err != nil {
+            return nil, fmt.Errorf("db.Query() error: %w", err)
+        }
+        snapshot := tableSnapshot{table: table}
+        if snapshot.columns, err = rows.Columns(); err != nil {
+            rows.Close()
+            return nil, fmt.Errorf("rows.Columns() error: %w", err)
+        }
+        for rows.Next() {
+            values := make([]interface{}, len(snapshot.columns))
+            dest := make([]interface{}, len(values))
+            for i := range values {
+                dest[i] = &values[i]
+            }
+            if err := rows.Scan(dest...); err != nil {
+                rows.Close()
+                return nil, fmt.Errorf("rows.Scan() error: %w", err)
+            }
+            snapshot.rows = append(snapshot.rows, values)
+        }
+        err = rows.Err()
+        rows.Close()
+        if err != nil {
+            return nil, fmt.Errorf("rows.Next() error: %w", err)
+        }
+        result = append(result, snapshot)
+    }
+    return result, nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Replace the rows of the tables with the saved ones.
+//////////////////////////////////////////////////////////////////////
+func restoreTables(db *sql.DB, snapshots []tableSnapshot) error {
+    ctx := context.Background()
+    conn, err := db.Conn(ctx)
+    if err != nil {
+        return fmt.Errorf("db.Conn() error: %w", err)
+    }
+    defer conn.Close()
+    if _, err := conn.ExecContext(ctx, "SET FOREIGN_KEY_CHECKS=0"); err != nil {
+        return fmt.Errorf("conn.ExecContext() error: %w", err)
+    }
+    defer conn.ExecContext(ctx, "SET FOREIGN_KEY_CHECKS=1")
+
+    for _, snapshot := range snapshots {
+        if _, err := conn.ExecContext(ctx, "TRUNCATE TABLE " + myMySQL.QuoteIdentifier(snapshot.table)); err != nil {
+            return fmt.Errorf("TRUNCATE TABLE %s error: %w", snapshot.table, err)
+        }
+        placeholders := strings.TrimSuffix(strings.Repeat("?,", len(snapshot.columns)), ",")
+        query := "INSERT INTO " + myMySQL.QuoteIdentifier(snapshot.table) + " (" + strings.Join(quoteIdentifiers(snapshot.columns), ",") + ") VALUES (" + placeholders + ")"
+        for _, row := range snapshot.rows {
+            if _, err := conn.ExecContext(ctx, query, row...); err != nil {
+                return fmt.Errorf("inserting into %s error: %w", snapshot.table, err)
+            }
+        }
+    }
+    return nil
+}
+
+
+func quoteIdentifiers(names []string) []string {
+    quoted := make([]string, len(names))
+    for i, name := range names {
+        quoted[i] = myMySQL.QuoteIdentifier(name)
+    }
+    return quoted
+}
@@ -0,0 +1,160 @@
+package mysqltest_test
+
+import (
+    "bytes"
+    "context"
+    "database/sql"
+    "database/sql/driver"
+    "strings"
+    "testing"
+    "testing/fstest"
+    "time"
+    myMySQL "mysql"
+    "mysql/mysqltest"
+    "mysql/mysqltest/embedded"
+)
+
+func createItems(db *sql.DB) error {
+    _, err := db.Exec("CREATE TABLE items (id INT NOT NULL, name VARCHAR(64) NOT NULL, data VARBINARY(16) NOT NULL, price DECIMAL(30,10) NOT NULL, attrs JSON NOT NULL, created_at DATETIME NOT NULL, PRIMARY KEY(id))")
+    return err
+}
+
+func TestSubsetFixtureRoundTrip(t *testing.T) {
+    src := embedded.NewDB(t, &embedded.Options{Setup: []func(db *sql.DB) error{createItems}})
+    created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+    if _, err := src.Exec("INSERT INTO items VALUES (1, 'a {{ now }}', ?, '12345678901234567890.0123456789', '{\"tags\":[\"go\"]}', ?)", []byte{0, 1, 0xfe, 0xff}, created); err != nil {
+        t.Fatalf("db.Exec() error: %s", err)
+    }
+
+    var b bytes.Buffer
+    if err := myMySQL.Subset(context.Background(), src, &b, &myMySQL.SubsetOptions{
+        Seeds: []myMySQL.SubsetSeed{{Table: "items"}},
+        Format: myMySQL.FORMAT_FIXTURE,
+    }); err != nil {
+        t.Fatalf("mysql.Subset() error: %s", err)
+    }
+
+    dst := embedded.NewDB(t, &embedded.Options{Setup: []func(db *sql.DB) error{createItems}})
+    fsys := fstest.MapFS{"items.json": &fstest.MapFile{Data: b.Bytes()}}
+    if _, err := mysqltest.LoadFixtures(dst, fsys, "*.json"); err != nil {
+        t.Fatalf("mysqltest.LoadFixtures() error: %s\n%s", err, b.String())
+    }
+
+    var name, price, attrs string
+    var data []byte
+    var createdAt time.Time
+    if err := dst.QueryRow("SELECT name, data, price, attrs, created_at FROM items WHERE id = 1").Scan(&name, &data, &price, &attrs, &createdAt); err != nil {
+        t.Fatalf("row.Scan() error: %s", err)
+    }
+    if name != "a {{ now }}" {
+        t.Errorf("name = %q", name)
+    }
+    if !bytes.Equal(data, []byte{0, 1, 0xfe, 0xff}) {
+        t.Errorf("data = %x", data)
+    }
+    if price != "12345678901234567890.0123456789" {
+        t.Errorf("price = %s", price)
+    }
+    if attrs != `{"tags": ["go"]}` && attrs != `{"tags":["go"]}` {
+        t.Errorf("attrs = %s", attrs)
+    }
+    if !createdAt.Equal(created) {
+        t.Errorf("created_at = %s, want %s", createdAt, created)
+    }
+}
+
+func TestSubsetTimestampRoundTrip(t *testing.T) {
+    createEvents := func(db *sql.DB) error {
+        _, err := db.Exec("CREATE TABLE events (id INT NOT NULL, created_at TIMESTAMP NOT NULL, PRIMARY KEY(id))")
+        return err
+    }
+    src := embedded.NewDB(t, &embedded.Options{Setup: []func(db *sql.DB) error{createEvents}})
+    created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+    if _, err := src.Exec("INSERT INTO events VALUES (1, ?)", created); err != nil {
+        t.Fatalf("db.Exec() error: %s", err)
+    }
+
+    tests := []struct {
+        format string
+        load func(dst *sql.DB, b *bytes.Buffer) error
+    }{
+        {myMySQL.FORMAT_SQL, func(dst *sql.DB, b *bytes.Buffer) error {
+            return myMySQL.Restore(context.Background(), dst, b, nil)
+        }},
+        {myMySQL.FORMAT_FIXTURE, func(dst *sql.DB, b *bytes.Buffer) error {
+            fsys := fstest.MapFS{"events.json": &fstest.MapFile{Data: b.Bytes()}}
+            _, err := mysqltest.LoadFixtures(dst, fsys, "*.json")
+            return err
+        }},
+    }
+    for _, tt := range tests {
+        t.Run(tt.format, func(t *testing.T) {
+            var b bytes.Buffer
+            if err := myMySQL.Subset(context.Background(), src, &b, &myMySQL.SubsetOptions{
+                Seeds: []myMySQL.SubsetSeed{{Table: "events"}},
+                Format: tt.format,
+            }); err != nil {
+                t.Fatalf("mysql.Subset() error: %s", err)
+            }
+
+            dst := embedded.NewDB(t, &embedded.Options{Setup: []func(db *sql.DB) error{createEvents}})
+            dst.SetMaxOpenConns(1)
+            if _, err := dst.Exec("SET SESSION time_zone='+09:00'"); err != nil {
+                t.Fatalf("db.Exec() error: %s", err)
+            }
+            // The embedded server ignores the session time zone for TIMESTAMP
+            // values, so the statements are checked too.
+            if tt.format == myMySQL.FORMAT_SQL {
+                setTimeZone := strings.Index(b.String(), "SET TIME_ZONE='+00:00'")
+                if setTimeZone < 0 || setTimeZone > strings.Index(b.String(), "INSERT INTO") {
+                    t.Errorf("no SET TIME_ZONE before the rows:\n%s", b.String())
+                }
+            }
+            if err := tt.load(dst, &b); err != nil {
+                t.Fatalf("loading the subset error: %s\n%s", err, b.String())
+            }
+
+            var timeZone string
+            var createdAt int64
+            if err := dst.QueryRow("SELECT @@SESSION.time_zone, UNIX_TIMESTAMP(created_at) FROM events WHERE id = 1").Scan(&timeZone, &createdAt); err != nil {
+                t.Fatalf("row.Scan() error: %s", err)
+            }
+            if timeZone != "+09:00" {
+                t.Errorf("time_zone after loading = %q, want %q", timeZone, "+09:00")
+            }
+            if createdAt != created.Unix() {
+                t.Errorf("created_at = %s, want %s", time.Unix(createdAt, 0).UTC(), created)
+            }
+        })
+    }
+}
+
+type utcNow struct{}
+
+func (utcNow) Match(v driver.Value) bool {
+    s, ok := v.(string)
+    if !ok {
+        return false
+    }
+    now, err := time.ParseInLocation(mysqltest.FIXTURE_TIME_LAYOUT, s, time.UTC)
+    d := time.Since(now)
+    return err == nil && d > -time.Minute && d < time.Minute
+}
+
+func TestLoadFixturesTimeZone(t *testing.T) {
+    db, mock := mysqltest.NewT(t)
+    mock.ExpectExec("SET FOREIGN_KEY_CHECKS=0")
+    mock.ExpectExec("SET @OLD_TIME_ZONE=@@TIME_ZONE, TIME_ZONE='+00:00'")
+    mock.ExpectExec("TRUNCATE TABLE `events`")
+    mock.ExpectExec("INSERT INTO `events` (`id`,`created_at`) VALUES (?,?)").WithArgs(1, utcNow{})
+    mock.ExpectExec("SET TIME_ZONE=@OLD_TIME_ZONE")
+    mock.ExpectExec("SET FOREIGN_KEY_CHECKS=1")
+
+    fsys := fstest.MapFS{"events.yml": &fstest.MapFile{Data: []byte("events:\n  - id: 1\n    created_at: '{{ now }}'\n")}}
+    if _, err := mysqltest.LoadFixtures(db, fsys, "*.yml"); err != nil {
+        t.Fatalf("mysqltest.LoadFixtures() error: %s", err)
+    }
+    if err := mock.ExpectationsWereMet(); err != nil {
+        t.Error(err)
+    }
+}
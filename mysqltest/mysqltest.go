@@ -0,0 +1,785 @@
+//////////////////////////////////////////////////////////////////////
+// mysqltest.go
+//
+// @usage
+//
+//     1. Import this package in your test.
+//
+//         --------------------------------------------------
+//         import "mysql/mysqltest"
+//         --------------------------------------------------
+//
+//     2. Get a database which is backed by the fake driver.
+//
+//         --------------------------------------------------
+//         db, mock := mysqltest.NewT(t)
+//         --------------------------------------------------
+//
+//     3. Script the expected queries and their results.
+//
+//         --------------------------------------------------
+//         mock.ExpectExecRegexp("^CREATE TABLE IF NOT EXISTS countries").WillReturnResult(0, 0)
+//         mock.ExpectExecRegexp("^INSERT IGNORE INTO countries").WillReturnResult(0, 249)
+//         // countries prepares its queries (see mysql.StmtCache).
+//         mock.ExpectPrepare("SELECT * FROM countries WHERE continent=? AND status=? ORDER BY country_code ASC")
+//         mock.ExpectQuery("SELECT * FROM countries WHERE continent=? AND status=? ORDER BY country_code ASC").
+//             WithArgs(1, 1).
+//             WillReturnRows(mysqltest.NewRows("country_code", "ar", "de", "en", "es", "fr", "ja", "pt", "ru", "zh_cn", "zh_tw", "continent", "status").
+//                 AddRow("ZM", "زامبيا", "Sambia", "Zambia", "Zambia", "Zambie", "ザンビア", "Zâmbia", "Замбия", "尚比亞", "尚比亞", 1, 1))
+//         mock.ExpectExec("UPDATE countries SET status = ? WHERE country_code = ?").
+//             WithArgs(0, "JP").
+//             WillReturnError(errors.New("read only"))
+//
+//         myCountries.Init(db)
+//         result := myCountries.GetAfricaOnlyActive("en")
+//         --------------------------------------------------
+//
+//        See countries/countries_test.go.
+//
+//     4. NewT fails the test when an expectation was not met.
+//        Call mock.ExpectationsWereMet() yourself when using New().
+
+//     5. The mock is also registered as the driver DRIVER_NAME, e.g. to test
+//        the interceptors with mysql.OpenDB().
+//
+//         --------------------------------------------------
+//         _, mock := mysqltest.NewT(t)
+//         db, _, err := myMySQL.OpenDB(mysqltest.DRIVER_NAME, mock.DSN(), interceptor)
+//         --------------------------------------------------
+//
+//     An exact query matches when it is equal to the executed query after
+//     collapsing white spaces. The expectations are matched in order unless
+//     mock.MatchExpectationsInOrder(false) is called.
+//
+//
+// MIT License
+//
+// Copyright (c) 2019 noknow.info
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+// PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+// HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+// OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTW//ARE.
+//////////////////////////////////////////////////////////////////////
+package mysqltest
+
+import (
+    "context"
+    "database/sql"
+    "database/sql/driver"
+    "fmt"
+    "io"
+    "reflect"
+    "regexp"
+    "strconv"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+)
+
+const (
+    DRIVER_NAME = "mysqltest"
+
+    EXPECT_QUERY = "query"
+    EXPECT_EXEC = "exec"
+    EXPECT_PREPARE = "prepare"
+    EXPECT_BEGIN = "begin"
+    EXPECT_COMMIT = "commit"
+    EXPECT_ROLLBACK = "rollback"
+    EXPECT_PING = "ping"
+)
+
+var (
+    registerOnce sync.Once
+    mocksMu sync.Mutex
+    mocks = make(map[string]*Mock)
+    mockSeq int
+)
+
+// Custom argument matcher, e.g. AnyArg().
+type Argument interface {
+    Match(v driver.Value) bool
+}
+
+type anyArg struct{}
+
+func (anyArg) Match(v driver.Value) bool {
+    return true
+}
+
+type Mock struct {
+    mu sync.Mutex
+    dsn string
+    ordered bool
+    // Pings are matched once ExpectPing() is called.
+    pings bool
+    expectations []*Expectation
+}
+
+type Expectation struct {
+    kind string
+    query string
+    pattern *regexp.Regexp
+    args []interface{}
+    argsSet bool
+    rows *Rows
+    result driver.Result
+    err error
+    delay time.Duration
+    fulfilled bool
+}
+
+type Rows struct {
+    columns []string
+    types []string
+    values [][]driver.Value
+}
+
+type fakeDriver struct{}
+
+// Connector of one mock, which is unregistered when the database is closed.
+type fakeConnector struct {
+    mock *Mock
+}
+
+type fakeConn struct {
+    mock *Mock
+}
+
+type fakeStmt struct {
+    conn *fakeConn
+    query string
+}
+
+type fakeTx struct {
+    conn *fakeConn
+}
+
+type fakeRows struct {
+    rows *Rows
+    pos int
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get a new database and its mock. Close the database when done.
+//////////////////////////////////////////////////////////////////////
+func New() (*sql.DB, *Mock, error) {
+    registerOnce.Do(func() {
+        sql.Register(DRIVER_NAME, fakeDriver{})
+    })
+    mocksMu.Lock()
+    mockSeq++
+    m := &Mock{
+        dsn: "mysqltest_" + strconv.Itoa(mockSeq),
+        ordered: true,
+    }
+    mocks[m.dsn] = m
+    mocksMu.Unlock()
+
+    return sql.OpenDB(&fakeConnector{mock: m}), m, nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get a new database and its mock for the test.
+// The expectations are checked and the database is closed on cleanup.
+//////////////////////////////////////////////////////////////////////
+func NewT(t testing.TB) (*sql.DB, *Mock) {
+    t.Helper()
+    db, mock, err := New()
+    if err != nil {
+        t.Fatalf("mysqltest.New() error: %s", err)
+    }
+    t.Cleanup(func() {
+        db.Close()
+        if err := mock.ExpectationsWereMet(); err != nil {
+            t.Error(err)
+        }
+    })
+    return db, mock
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Match any argument.
+//////////////////////////////////////////////////////////////////////
+func AnyArg() Argument {
+    return anyArg{}
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Data source name of the mock for sql.Open(DRIVER_NAME, dsn).
+// Valid until the database of New() is closed.
+//////////////////////////////////////////////////////////////////////
+func (m *Mock) DSN() string {
+    return m.dsn
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Set whether the expectations must be met in order (default: true).
+//////////////////////////////////////////////////////////////////////
+func (m *Mock) MatchExpectationsInOrder(ordered bool) {
+    m.mu.Lock()
+    m.ordered = ordered
+    m.mu.Unlock()
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Expect a query which is equal to sql.
+//////////////////////////////////////////////////////////////////////
+func (m *Mock) ExpectQuery(sql string) *Expectation {
+    return m.expect(&Expectation{kind: EXPECT_QUERY, query: normalizeQuery(sql)})
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Expect a query which matches the regular expression.
+//////////////////////////////////////////////////////////////////////
+func (m *Mock) ExpectQueryRegexp(pattern string) *Expectation {
+    return m.expect(&Expectation{kind: EXPECT_QUERY, pattern: regexp.MustCompile(pattern)})
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Expect an exec which is equal to sql.
+//////////////////////////////////////////////////////////////////////
+func (m *Mock) ExpectExec(sql string) *Expectation {
+    return m.expect(&Expectation{kind: EXPECT_EXEC, query: normalizeQuery(sql)})
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Expect an exec which matches the regular expression.
+//////////////////////////////////////////////////////////////////////
+func (m *Mock) ExpectExecRegexp(pattern string) *Expectation {
+    return m.expect(&Expectation{kind: EXPECT_EXEC, pattern: regexp.MustCompile(pattern)})
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Expect an explicit prepare of sql. Statements which are prepared
+// implicitly by database/sql are not matched against it.
+//////////////////////////////////////////////////////////////////////
+func (m *Mock) ExpectPrepare(sql string) *Expectation {
+    return m.expect(&Expectation{kind: EXPECT_PREPARE, query: normalizeQuery(sql)})
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Expect the beginning of a transaction.
+//////////////////////////////////////////////////////////////////////
+func (m *Mock) ExpectBegin() *Expectation {
+    return m.expect(&Expectation{kind: EXPECT_BEGIN})
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Expect the commit of a transaction.
+//////////////////////////////////////////////////////////////////////
+func (m *Mock) ExpectCommit() *Expectation {
+    return m.expect(&Expectation{kind: EXPECT_COMMIT})
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Expect the rollback of a transaction.
+//////////////////////////////////////////////////////////////////////
+func (m *Mock) ExpectRollback() *Expectation {
+    return m.expect(&Expectation{kind: EXPECT_ROLLBACK})
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Expect a ping. The pings succeed without an expectation until this
+// is called, then they are matched like the other calls.
+//////////////////////////////////////////////////////////////////////
+func (m *Mock) ExpectPing() *Expectation {
+    m.mu.Lock()
+    m.pings = true
+    m.mu.Unlock()
+    return m.expect(&Expectation{kind: EXPECT_PING})
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get an error describing the expectations which were not met.
+//////////////////////////////////////////////////////////////////////
+func (m *Mock) ExpectationsWereMet() error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    var missing []string
+    for _, e := range m.expectations {
+        if !e.fulfilled {
+            missing = append(missing, "  " + e.String())
+        }
+    }
+    if len(missing) == 0 {
+        return nil
+    }
+    return fmt.Errorf("mysqltest: %d expectation(s) were not met:\n%s", len(missing), strings.Join(missing, "\n"))
+}
+
+func (m *Mock) expect(e *Expectation) *Expectation {
+    m.mu.Lock()
+    m.expectations = append(m.expectations, e)
+    m.mu.Unlock()
+    return e
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Find the expectation for the call and mark it as fulfilled.
+//////////////////////////////////////////////////////////////////////
+func (m *Mock) match(kind, query string, args []driver.NamedValue) (*Expectation, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    call := kind
+    if query != "" {
+        call += " " + strconv.Quote(query)
+    }
+    for _, e := range m.expectations {
+        if e.fulfilled {
+            continue
+        }
+        err := e.matches(kind, query, args)
+        if err == nil {
+            e.fulfilled = true
+            return e, nil
+        }
+        if m.ordered {
+            return nil, fmt.Errorf("mysqltest: call %s was not expected, next expectation is %s: %s", call, e, err)
+        }
+    }
+    return nil, fmt.Errorf("mysqltest: call %s was not expected", call)
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Expect the arguments. An Argument matches with its Match method.
+//////////////////////////////////////////////////////////////////////
+func (e *Expectation) WithArgs(args ...interface{}) *Expectation {
+    e.args = args
+    e.argsSet = true
+    return e
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Return the rows for the query.
+//////////////////////////////////////////////////////////////////////
+func (e *Expectation) WillReturnRows(rows *Rows) *Expectation {
+    e.rows = rows
+    return e
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Return the result for the exec.
+//////////////////////////////////////////////////////////////////////
+func (e *Expectation) WillReturnResult(lastInsertId, rowsAffected int64) *Expectation {
+    e.result = fakeResult{lastInsertId: lastInsertId, rowsAffected: rowsAffected}
+    return e
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Return the error for the call.
+//////////////////////////////////////////////////////////////////////
+func (e *Expectation) WillReturnError(err error) *Expectation {
+    e.err = err
+    return e
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Delay the response. The call returns the context error when the
+// context is done first.
+//////////////////////////////////////////////////////////////////////
+func (e *Expectation) WillDelayFor(d time.Duration) *Expectation {
+    e.delay = d
+    return e
+}
+
+func (e *Expectation) String() string {
+    s := e.kind
+    if e.pattern != nil {
+        s += " matching " + strconv.Quote(e.pattern.String())
+    } else if e.query != "" {
+        s += " " + strconv.Quote(e.query)
+    }
+    if e.argsSet {
+        s += fmt.Sprintf(" with args %v", e.args)
+    }
+    return s
+}
+
+func (e *Expectation) matches(kind, query string, args []driver.NamedValue) error {
+    if e.kind != kind {
+        return fmt.Errorf("kind is %s", e.kind)
+    }
+    if e.pattern != nil && !e.pattern.MatchString(query) {
+        return fmt.Errorf("query does not match")
+    }
+    if e.pattern == nil && e.query != "" && e.query != normalizeQuery(query) {
+        return fmt.Errorf("query is different")
+    }
+    if !e.argsSet {
+        return nil
+    }
+    if len(e.args) != len(args) {
+        return fmt.Errorf("expected %d args, got %d", len(e.args), len(args))
+    }
+    for i, expected := range e.args {
+        if matcher, ok := expected.(Argument); ok {
+            if !matcher.Match(args[i].Value) {
+                return fmt.Errorf("arg %d does not match", i + 1)
+            }
+            continue
+        }
+        v, err := driver.DefaultParameterConverter.ConvertValue(expected)
+        if err != nil {
+            return fmt.Errorf("arg %d: %s", i + 1, err)
+        }
+        if !equalValue(v, args[i].Value) {
+            return fmt.Errorf("arg %d is %v, expected %v", i + 1, args[i].Value, v)
+        }
+    }
+    return nil
+}
+
+func (e *Expectation) wait(ctx context.Context) error {
+    if e.delay <= 0 {
+        return nil
+    }
+    timer := time.NewTimer(e.delay)
+    defer timer.Stop()
+    select {
+    case <-timer.C:
+        return nil
+    case <-ctx.Done():
+        return ctx.Err()
+    }
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Create rows with the columns.
+//////////////////////////////////////////////////////////////////////
+func NewRows(columns ...string) *Rows {
+    return &Rows{columns: columns}
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Set the database type names of the columns, e.g. "VARCHAR", "INT".
+//////////////////////////////////////////////////////////////////////
+func (r *Rows) ColumnTypes(types ...string) *Rows {
+    r.types = types
+    return r
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Add a row. Panics when a value is not convertible to a driver value.
+//////////////////////////////////////////////////////////////////////
+func (r *Rows) AddRow(values ...interface{}) *Rows {
+    if len(values) != len(r.columns) {
+        panic(fmt.Sprintf("mysqltest: %d values for %d columns", len(values), len(r.columns)))
+    }
+    row := make([]driver.Value, len(values))
+    for i, v := range values {
+        converted, err := driver.DefaultParameterConverter.ConvertValue(v)
+        if err != nil {
+            panic(fmt.Sprintf("mysqltest: value %d: %s", i + 1, err))
+        }
+        row[i] = converted
+    }
+    r.values = append(r.values, row)
+    return r
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// driver.Driver
+//////////////////////////////////////////////////////////////////////
+func (fakeDriver) Open(dsn string) (driver.Conn, error) {
+    mocksMu.Lock()
+    m, ok := mocks[dsn]
+    mocksMu.Unlock()
+    if !ok {
+        return nil, fmt.Errorf("mysqltest: unknown dsn %q", dsn)
+    }
+    return &fakeConn{mock: m}, nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// driver.Connector
+//////////////////////////////////////////////////////////////////////
+func (c *fakeConnector) Connect(ctx context.Context) (driver.Conn, error) {
+    return &fakeConn{mock: c.mock}, nil
+}
+
+func (c *fakeConnector) Driver() driver.Driver {
+    return fakeDriver{}
+}
+
+// Called by (*sql.DB) Close().
+func (c *fakeConnector) Close() error {
+    mocksMu.Lock()
+    delete(mocks, c.mock.dsn)
+    mocksMu.Unlock()
+    return nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// driver.Conn
+//////////////////////////////////////////////////////////////////////
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+    return c.PrepareContext(context.Background(), query)
+}
+
+func (c *fakeConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+    c.mock.mu.Lock()
+    expected := false
+    for _, e := range c.mock.expectations {
+        if !e.fulfilled && e.kind == EXPECT_PREPARE {
+            expected = true
+            break
+        }
+    }
+    c.mock.mu.Unlock()
+    if expected {
+        e, err := c.mock.match(EXPECT_PREPARE, query, nil)
+        if err != nil {
+            return nil, err
+        }
+        if err := e.wait(ctx); err != nil {
+            return nil, err
+        }
+        if e.err != nil {
+            return nil, e.err
+        }
+    }
+    return &fakeStmt{conn: c, query: query}, nil
+}
+
+func (c *fakeConn) Close() error {
+    return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+    return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+    e, err := c.mock.match(EXPECT_BEGIN, "", nil)
+    if err != nil {
+        return nil, err
+    }
+    if err := e.wait(ctx); err != nil {
+        return nil, err
+    }
+    if e.err != nil {
+        return nil, e.err
+    }
+    return &fakeTx{conn: c}, nil
+}
+
+func (c *fakeConn) Ping(ctx context.Context) error {
+    c.mock.mu.Lock()
+    pings := c.mock.pings
+    c.mock.mu.Unlock()
+    if !pings {
+        return nil
+    }
+    e, err := c.mock.match(EXPECT_PING, "", nil)
+    if err != nil {
+        return err
+    }
+    if err := e.wait(ctx); err != nil {
+        return err
+    }
+    return e.err
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+    e, err := c.mock.match(EXPECT_QUERY, query, args)
+    if err != nil {
+        return nil, err
+    }
+    if err := e.wait(ctx); err != nil {
+        return nil, err
+    }
+    if e.err != nil {
+        return nil, e.err
+    }
+    if e.rows == nil {
+        return &fakeRows{rows: &Rows{}}, nil
+    }
+    return &fakeRows{rows: e.rows}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+    e, err := c.mock.match(EXPECT_EXEC, query, args)
+    if err != nil {
+        return nil, err
+    }
+    if err := e.wait(ctx); err != nil {
+        return nil, err
+    }
+    if e.err != nil {
+        return nil, e.err
+    }
+    if e.result == nil {
+        return fakeResult{}, nil
+    }
+    return e.result, nil
+}
+
+func (c *fakeConn) CheckNamedValue(nv *driver.NamedValue) error {
+    v, err := driver.DefaultParameterConverter.ConvertValue(nv.Value)
+    if err != nil {
+        return err
+    }
+    nv.Value = v
+    return nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// driver.Stmt
+//////////////////////////////////////////////////////////////////////
+func (s *fakeStmt) Close() error {
+    return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+    return -1
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+    return s.conn.ExecContext(context.Background(), s.query, namedValues(args))
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+    return s.conn.QueryContext(context.Background(), s.query, namedValues(args))
+}
+
+func (s *fakeStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+    return s.conn.ExecContext(ctx, s.query, args)
+}
+
+func (s *fakeStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+    return s.conn.QueryContext(ctx, s.query, args)
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// driver.Tx
+//////////////////////////////////////////////////////////////////////
+func (t *fakeTx) Commit() error {
+    e, err := t.conn.mock.match(EXPECT_COMMIT, "", nil)
+    if err != nil {
+        return err
+    }
+    return e.err
+}
+
+func (t *fakeTx) Rollback() error {
+    e, err := t.conn.mock.match(EXPECT_ROLLBACK, "", nil)
+    if err != nil {
+        return err
+    }
+    return e.err
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// driver.Rows
+//////////////////////////////////////////////////////////////////////
+func (r *fakeRows) Columns() []string {
+    return r.rows.columns
+}
+
+func (r *fakeRows) Close() error {
+    return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+    if r.pos >= len(r.rows.values) {
+        return io.EOF
+    }
+    copy(dest, r.rows.values[r.pos])
+    r.pos++
+    return nil
+}
+
+func (r *fakeRows) ColumnTypeDatabaseTypeName(index int) string {
+    if index < len(r.rows.types) {
+        return r.rows.types[index]
+    }
+    return ""
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// driver.Result
+//////////////////////////////////////////////////////////////////////
+type fakeResult struct {
+    lastInsertId int64
+    rowsAffected int64
+}
+
+func (r fakeResult) LastInsertId() (int64, error) {
+    return r.lastInsertId, nil
+}
+
+func (r fakeResult) RowsAffected() (int64, error) {
+    return r.rowsAffected, nil
+}
+
+
+func normalizeQuery(query string) string {
+    return strings.Join(strings.Fields(query), " ")
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+    named := make([]driver.NamedValue, len(args))
+    for i, v := range args {
+        named[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+    }
+    return named
+}
+
+func equalValue(expected, actual driver.Value) bool {
+    if e, ok := expected.([]byte); ok {
+        expected = string(e)
+    }
+    if a, ok := actual.([]byte); ok {
+        actual = string(a)
+    }
+    if e, ok := expected.(time.Time); ok {
+        a, ok := actual.(time.Time)
+        return ok && e.Equal(a)
+    }
+    return reflect.DeepEqual(expected, actual)
+}
@@ -0,0 +1,61 @@
+package mysqltest_test
+
+import (
+    "context"
+    "database/sql"
+    "testing"
+    myMySQL "mysql"
+    "mysql/mysqltest"
+)
+
+func TestDSN(t *testing.T) {
+    mockDb, mock := mysqltest.NewT(t)
+    mock.ExpectQuery("SELECT name FROM users WHERE id = ?").
+        WithArgs(1).
+        WillReturnRows(mysqltest.NewRows("name").AddRow("alice"))
+    mock.ExpectExec("DELETE FROM users").WillReturnResult(0, 3)
+
+    db, err := sql.Open(mysqltest.DRIVER_NAME, mock.DSN())
+    if err != nil {
+        t.Fatalf("sql.Open() error: %s", err)
+    }
+    defer db.Close()
+    var name string
+    if err := db.QueryRow("SELECT name FROM users WHERE id = ?", 1).Scan(&name); err != nil {
+        t.Fatalf("row.Scan() error: %s", err)
+    }
+    if name != "alice" {
+        t.Errorf("name = %q, want %q", name, "alice")
+    }
+
+    var ops []string
+    interceptor := func(ctx context.Context, call *myMySQL.Call, next myMySQL.Handler) error {
+        ops = append(ops, call.Op)
+        return next(ctx, call)
+    }
+    intercepted, _, err := myMySQL.OpenDB(mysqltest.DRIVER_NAME, mock.DSN(), interceptor)
+    if err != nil {
+        t.Fatalf("mysql.OpenDB() error: %s", err)
+    }
+    defer intercepted.Close()
+    result, err := intercepted.Exec("DELETE FROM users")
+    if err != nil {
+        t.Fatalf("db.Exec() error: %s", err)
+    }
+    if n, _ := result.RowsAffected(); n != 3 {
+        t.Errorf("RowsAffected() = %d, want 3", n)
+    }
+    if len(ops) == 0 || ops[len(ops) - 1] != myMySQL.OP_EXEC {
+        t.Errorf("intercepted ops = %q, want the last one %q", ops, myMySQL.OP_EXEC)
+    }
+
+    mockDb.Close()
+    closed, err := sql.Open(mysqltest.DRIVER_NAME, mock.DSN())
+    if err != nil {
+        t.Fatalf("sql.Open() error: %s", err)
+    }
+    defer closed.Close()
+    if err := closed.Ping(); err == nil {
+        t.Error("Ping() after closing the mock database = nil, want an error")
+    }
+}
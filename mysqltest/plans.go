@@ -0,0 +1,87 @@
+//////////////////////////////////////////////////////////////////////
+// plans.go
+//
+// @usage
+//
+//     1. Register the queries with myMySQL.RegisterPlanCheck().
+//
+//     2. Assert the plans against a real MySQL server in your test.
+//        The test fails on a warning which is not allowed by the check.
+//
+//         --------------------------------------------------
+//         func TestPlans(t *testing.T) {
+//             db, err := sql.Open("mysql", os.Getenv("MYSQL_DSN"))
+//             if err != nil {
+//                 t.Fatal(err)
+//             }
+//             mysqltest.AssertPlans(t, db, &myMySQL.AnalyzeOptions{MaxScanRows: 500})
+//         }
+//         --------------------------------------------------
+//
+//     3. Or assert a single query.
+//
+//         --------------------------------------------------
+//         mysqltest.AssertPlan(t, db, nil, "SELECT * FROM countries WHERE country_code = ?", "JP")
+//         --------------------------------------------------
+//
+//
+// MIT License
+//
+// Copyright (c) 2019 noknow.info
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+// PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+// HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+// OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTW//ARE.
+//////////////////////////////////////////////////////////////////////
+package mysqltest
+
+import (
+    "context"
+    "testing"
+    myMySQL "mysql"
+)
+
+
+//////////////////////////////////////////////////////////////////////
+// Check the plans of the registered queries.
+//////////////////////////////////////////////////////////////////////
+func AssertPlans(t testing.TB, q myMySQL.Queryer, opts *myMySQL.AnalyzeOptions) {
+    t.Helper()
+    for _, check := range myMySQL.PlanChecks() {
+        assertPlanCheck(t, q, opts, check)
+    }
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Check the plan of the query. Any warning fails the test.
+//////////////////////////////////////////////////////////////////////
+func AssertPlan(t testing.TB, q myMySQL.Queryer, opts *myMySQL.AnalyzeOptions, query string, args ...interface{}) {
+    t.Helper()
+    assertPlanCheck(t, q, opts, myMySQL.PlanCheck{Name: query, Query: query, Args: args})
+}
+
+func assertPlanCheck(t testing.TB, q myMySQL.Queryer, opts *myMySQL.AnalyzeOptions, check myMySQL.PlanCheck) {
+    t.Helper()
+    warnings, err := check.Run(context.Background(), q, opts)
+    if err != nil {
+        t.Errorf("plan %q: %s", check.Name, err)
+        return
+    }
+    for _, warning := range warnings {
+        t.Errorf("plan %q: %s", check.Name, warning)
+    }
+}
@@ -0,0 +1,316 @@
+//////////////////////////////////////////////////////////////////////
+// named.go
+//
+// @usage
+//
+//     1. Import this package.
+//
+//         --------------------------------------------------
+//         import myMySQL "mysql"
+//         --------------------------------------------------
+//
+//     2. Write :name parameters and pass a map or a struct.
+//
+//         --------------------------------------------------
+//         rows, err := myMySQL.NamedQueryContext(ctx, myMySQL.Conn(),
+//             "SELECT * FROM countries WHERE continent = :continent AND status = :status",
+//             map[string]interface{}{"continent": 2, "status": 1})
+//
+//         result, err := myMySQL.NamedExecContext(ctx, tx,
+//             "UPDATE countries SET status = :status WHERE country_code IN (:codes)",
+//             struct {
+//                 Status int
+//                 Codes []string
+//             }{0, []string{"JP", "KR"}})
+//         --------------------------------------------------
+//
+//     3. Or rewrite the query yourself.
+//
+//         --------------------------------------------------
+//         query, args, err := myMySQL.Named(query, arg)
+//         --------------------------------------------------
+//
+//     A name may appear several times. A slice becomes "?, ?, ?", so
+//     write the parentheses of IN yourself. The struct fields are named
+//     by the db tag or in snake case (CountryCode is country_code), and
+//     the fields of the embedded structs are included.
+//     The colons in the string literals, the quoted identifiers and the
+//     comments are not parameters, nor the := operator.
+//
+//
+// MIT License
+//
+// Copyright (c) 2019 noknow.info
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+// PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+// HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+// OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTW//ARE.
+//////////////////////////////////////////////////////////////////////
+package mysql
+
+import (
+    "context"
+    "database/sql"
+    "database/sql/driver"
+    "fmt"
+    "reflect"
+    "strings"
+    "unicode"
+)
+
+const (
+    NAMED_TAG = "db"
+)
+
+type Execer interface {
+    ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Run the query with named parameters.
+//////////////////////////////////////////////////////////////////////
+func NamedQueryContext(ctx context.Context, q Queryer, query string, arg interface{}) (*sql.Rows, error) {
+    query, args, err := Named(query, arg)
+    if err != nil {
+        return nil, err
+    }
+    return q.QueryContext(ctx, query, args...)
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Run the statement with named parameters.
+//////////////////////////////////////////////////////////////////////
+func NamedExecContext(ctx context.Context, e Execer, query string, arg interface{}) (sql.Result, error) {
+    query, args, err := Named(query, arg)
+    if err != nil {
+        return nil, err
+    }
+    return e.ExecContext(ctx, query, args...)
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Rewrite the :name parameters to ? placeholders, and get the values of
+// the map or the struct in the order of the placeholders.
+//////////////////////////////////////////////////////////////////////
+func Named(query string, arg interface{}) (string, []interface{}, error) {
+    lookup, err := namedLookup(arg)
+    if err != nil {
+        return "", nil, err
+    }
+    var b strings.Builder
+    args := []interface{}{}
+    i := 0
+    for i < len(query) {
+        end := skipNonCode(query, i)
+        if end > i {
+            b.WriteString(query[i:end])
+            i = end
+            continue
+        }
+        c := query[i]
+        if c == '?' {
+            return "", nil, fmt.Errorf("? placeholder at %d in a query with named parameters", i)
+        }
+        if c != ':' || i + 1 >= len(query) || !isNameStart(query[i + 1]) || (i > 0 && (query[i - 1] == ':' || isNamePart(query[i - 1]))) {
+            b.WriteByte(c)
+            i++
+            continue
+        }
+        end = i + 1
+        for end < len(query) && isNamePart(query[end]) {
+            end++
+        }
+        name := query[i + 1:end]
+        value, ok := lookup(name)
+        if !ok {
+            return "", nil, fmt.Errorf("no value for :%s", name)
+        }
+        if values, ok := expandSlice(value); ok {
+            if len(values) == 0 {
+                return "", nil, fmt.Errorf("empty slice for :%s", name)
+            }
+            b.WriteString(strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", "))
+            args = append(args, values...)
+        } else {
+            b.WriteByte('?')
+            args = append(args, value)
+        }
+        i = end
+    }
+    return b.String(), args, nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get the end of the string literal, the quoted identifier or the
+// comment at i, or i when there is none.
+//////////////////////////////////////////////////////////////////////
+func skipNonCode(query string, i int) int {
+    switch c := query[i]; {
+    case c == '\'' || c == '"' || c == '`':
+        for j := i + 1; j < len(query); j++ {
+            switch query[j] {
+            case '\\':
+                if c != '`' {
+                    j++
+                }
+            case c:
+                // A doubled quote is an escaped quote.
+                if j + 1 < len(query) && query[j + 1] == c {
+                    j++
+                    continue
+                }
+                return j + 1
+            }
+        }
+        return len(query)
+    case c == '#' || (c == '-' && strings.HasPrefix(query[i:], "--") &&
+            (i + 2 == len(query) || unicode.IsSpace(rune(query[i + 2])))):
+        if end := strings.IndexByte(query[i:], '\n'); end >= 0 {
+            return i + end + 1
+        }
+        return len(query)
+    case c == '/' && strings.HasPrefix(query[i:], "/*"):
+        if end := strings.Index(query[i + 2:], "*/"); end >= 0 {
+            return i + 2 + end + 2
+        }
+        return len(query)
+    }
+    return i
+}
+
+func isNameStart(c byte) bool {
+    return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNamePart(c byte) bool {
+    return isNameStart(c) || (c >= '0' && c <= '9')
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get a lookup of the values of a map with string keys or a struct.
+//////////////////////////////////////////////////////////////////////
+func namedLookup(arg interface{}) (func(name string) (interface{}, bool), error) {
+    if m, ok := arg.(map[string]interface{}); ok {
+        return func(name string) (interface{}, bool) {
+            value, ok := m[name]
+            return value, ok
+        }, nil
+    }
+    v := reflect.ValueOf(arg)
+    for v.Kind() == reflect.Pointer && !v.IsNil() {
+        v = v.Elem()
+    }
+    switch {
+    case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String:
+        return func(name string) (interface{}, bool) {
+            value := v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+            if !value.IsValid() {
+                return nil, false
+            }
+            return value.Interface(), true
+        }, nil
+    case v.Kind() == reflect.Struct:
+        fields := map[string]interface{}{}
+        structFields(v, fields)
+        return func(name string) (interface{}, bool) {
+            value, ok := fields[name]
+            return value, ok
+        }, nil
+    }
+    return nil, fmt.Errorf("named parameters need a map or a struct, not %T", arg)
+}
+
+func structFields(v reflect.Value, fields map[string]interface{}) {
+    t := v.Type()
+    // The outer fields win over the ones of the embedded structs.
+    embeddedFields := map[string]interface{}{}
+    for i := 0; i < t.NumField(); i++ {
+        field := t.Field(i)
+        if field.Anonymous {
+            embedded := v.Field(i)
+            if embedded.Kind() == reflect.Pointer {
+                if embedded.IsNil() {
+                    continue
+                }
+                embedded = embedded.Elem()
+            }
+            if embedded.Kind() == reflect.Struct {
+                structFields(embedded, embeddedFields)
+                continue
+            }
+        }
+        if !field.IsExported() {
+            continue
+        }
+        name := field.Tag.Get(NAMED_TAG)
+        if name == "-" {
+            continue
+        }
+        if name == "" {
+            name = snakeCase(field.Name)
+        }
+        fields[name] = v.Field(i).Interface()
+    }
+    for name, value := range embeddedFields {
+        if _, ok := fields[name]; !ok {
+            fields[name] = value
+        }
+    }
+}
+
+func snakeCase(name string) string {
+    var b strings.Builder
+    runes := []rune(name)
+    for i, r := range runes {
+        if unicode.IsUpper(r) {
+            // "CountryCode" is country_code and "HTTPStatus" is http_status.
+            if i > 0 && (unicode.IsLower(runes[i - 1]) || (i + 1 < len(runes) && unicode.IsLower(runes[i + 1]) && unicode.IsUpper(runes[i - 1]))) {
+                b.WriteByte('_')
+            }
+            r = unicode.ToLower(r)
+        }
+        b.WriteRune(r)
+    }
+    return b.String()
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get the elements of a slice or an array argument. []byte and the
+// driver.Valuer are single values.
+//////////////////////////////////////////////////////////////////////
+func expandSlice(value interface{}) ([]interface{}, bool) {
+    if value == nil {
+        return nil, false
+    }
+    if _, ok := value.(driver.Valuer); ok {
+        return nil, false
+    }
+    v := reflect.ValueOf(value)
+    if (v.Kind() != reflect.Slice && v.Kind() != reflect.Array) || v.Type().Elem().Kind() == reflect.Uint8 {
+        return nil, false
+    }
+    values := make([]interface{}, v.Len())
+    for i := range values {
+        values[i] = v.Index(i).Interface()
+    }
+    return values, true
+}
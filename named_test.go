@@ -0,0 +1,129 @@
+package mysql_test
+
+import (
+    "context"
+    "database/sql"
+    "reflect"
+    "strings"
+    "testing"
+    myMySQL "mysql"
+    "mysql/mysqltest/embedded"
+)
+
+type namedBase struct {
+    ID int
+    Status int
+}
+
+type namedCountry struct {
+    namedBase
+    CountryCode string
+    Status int `db:"state"`
+    Secret string `db:"-"`
+    note string
+}
+
+func TestNamed(t *testing.T) {
+    tests := []struct {
+        name string
+        query string
+        arg interface{}
+        want string
+        args []interface{}
+        err string
+    }{
+        {"map", "SELECT * FROM t WHERE a = :a AND b = :b", map[string]interface{}{"a": 1, "b": "x"},
+            "SELECT * FROM t WHERE a = ? AND b = ?", []interface{}{1, "x"}, ""},
+        {"map pointer", "SELECT :a", &map[string]int{"a": 1},
+            "SELECT ?", []interface{}{1}, ""},
+        {"repeated name", "SELECT :a, :a", map[string]interface{}{"a": 1},
+            "SELECT ?, ?", []interface{}{1, 1}, ""},
+        {"slice", "SELECT * FROM t WHERE code IN (:codes)", map[string]interface{}{"codes": []string{"JP", "KR"}},
+            "SELECT * FROM t WHERE code IN (?, ?)", []interface{}{"JP", "KR"}, ""},
+        {"bytes", "SELECT :b", map[string]interface{}{"b": []byte("ab")},
+            "SELECT ?", []interface{}{[]byte("ab")}, ""},
+        {"struct", "UPDATE t SET state = :state WHERE country_code = :country_code AND id = :id",
+            namedCountry{namedBase: namedBase{ID: 3, Status: 9}, CountryCode: "JP", Status: 1},
+            "UPDATE t SET state = ? WHERE country_code = ? AND id = ?", []interface{}{1, "JP", 3}, ""},
+        {"embedded field", "SELECT :status", &namedCountry{namedBase: namedBase{Status: 9}},
+            "SELECT ?", []interface{}{9}, ""},
+        {"string literals", `SELECT ':a', ":a", 'it''s :a', 'back\' :a', :b`, map[string]interface{}{"b": 1},
+            `SELECT ':a', ":a", 'it''s :a', 'back\' :a', ?`, []interface{}{1}, ""},
+        {"quoted identifier", "SELECT `:a` FROM t WHERE x = :b", map[string]interface{}{"b": 1},
+            "SELECT `:a` FROM t WHERE x = ?", []interface{}{1}, ""},
+        {"comments", "SELECT /* :a */ :b # :a\n-- :a\n, 1", map[string]interface{}{"b": 1},
+            "SELECT /* :a */ ? # :a\n-- :a\n, 1", []interface{}{1}, ""},
+        {"not parameters", "SET @x := 1, @y = a::b, c = 10:30", map[string]interface{}{},
+            "SET @x := 1, @y = a::b, c = 10:30", []interface{}{}, ""},
+        {"missing name", "SELECT :a", map[string]interface{}{}, "", nil, "no value for :a"},
+        {"ignored field", "SELECT :secret", namedCountry{}, "", nil, "no value for :secret"},
+        {"unexported field", "SELECT :note", namedCountry{}, "", nil, "no value for :note"},
+        {"empty slice", "SELECT :a", map[string]interface{}{"a": []int{}}, "", nil, "empty slice"},
+        {"? placeholder", "SELECT ?, :a", map[string]interface{}{"a": 1}, "", nil, "? placeholder"},
+        {"not a map or a struct", "SELECT :a", 1, "", nil, "need a map or a struct"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            query, args, err := myMySQL.Named(tt.query, tt.arg)
+            if tt.err != "" {
+                if err == nil || !strings.Contains(err.Error(), tt.err) {
+                    t.Errorf("Named() error = %v, want %q", err, tt.err)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("Named() error: %s", err)
+            }
+            if query != tt.want {
+                t.Errorf("query = %q, want %q", query, tt.want)
+            }
+            if !reflect.DeepEqual(args, tt.args) {
+                t.Errorf("args = %#v, want %#v", args, tt.args)
+            }
+        })
+    }
+}
+
+func TestNamedQueryAndExec(t *testing.T) {
+    db := embedded.NewDB(t, &embedded.Options{
+        Setup: []func(db *sql.DB) error{
+            func(db *sql.DB) error {
+                _, err := db.Exec("CREATE TABLE t (code VARCHAR(2) PRIMARY KEY, status INT)")
+                return err
+            },
+            func(db *sql.DB) error {
+                _, err := db.Exec("INSERT INTO t VALUES ('JP', 1), ('KR', 1), ('US', 1)")
+                return err
+            },
+        },
+    })
+    ctx := context.Background()
+    result, err := myMySQL.NamedExecContext(ctx, db, "UPDATE t SET status = :status WHERE code IN (:codes)", struct {
+        Status int
+        Codes []string
+    }{0, []string{"JP", "KR"}})
+    if err != nil {
+        t.Fatalf("NamedExecContext() error: %s", err)
+    }
+    if n, _ := result.RowsAffected(); n != 2 {
+        t.Errorf("RowsAffected() = %d, want 2", n)
+    }
+
+    rows, err := myMySQL.NamedQueryContext(ctx, db, "SELECT code FROM t WHERE status = :status ORDER BY code",
+        map[string]interface{}{"status": 0})
+    if err != nil {
+        t.Fatalf("NamedQueryContext() error: %s", err)
+    }
+    defer rows.Close()
+    codes := []string{}
+    for rows.Next() {
+        var code string
+        if err := rows.Scan(&code); err != nil {
+            t.Fatalf("Scan() error: %s", err)
+        }
+        codes = append(codes, code)
+    }
+    if strings.Join(codes, ",") != "JP,KR" {
+        t.Errorf("codes = %v, want [JP KR]", codes)
+    }
+}
@@ -0,0 +1,306 @@
+//////////////////////////////////////////////////////////////////////
+// otelmysql.go
+//
+// @usage
+//
+//     1. Import this package.
+//
+//         --------------------------------------------------
+//         import (
+//             myMySQL "mysql"
+//             "mysql/otelmysql"
+//         )
+//         --------------------------------------------------
+//
+//     2. Register the interceptor before Init().
+//        The global TracerProvider and MeterProvider are used by default.
+//
+//         --------------------------------------------------
+//         interceptor, err := otelmysql.NewInterceptor(&otelmysql.Options{
+//             DBName: "mydb",
+//         })
+//         if err != nil {
+//             // Error handling.
+//         }
+//         myMySQL.Use(interceptor)
+//         myMySQL.Init(datasourceName)
+//         --------------------------------------------------
+//
+//     3. Export the pool stats of the database.
+//
+//         --------------------------------------------------
+//         registration, err := otelmysql.RegisterDBStats(myMySQL.Conn(), nil)
+//         if err != nil {
+//             // Error handling.
+//         }
+//         defer registration.Unregister()
+//         --------------------------------------------------
+//
+//     Each query, exec, prepare, begin, commit, rollback and connect becomes
+//     a client span with db.system, db.name, db.operation, the sanitized
+//     db.statement and db.sql.rows_affected. String and number literals in
+//     the statement are replaced with "?".
+//
+//     Metrics:
+//         db.client.operation.duration   histogram (s)  db.system, db.operation
+//         db.client.errors               counter        db.system, db.operation, db.mysql.error_number
+//         db.client.connections.*        gauges         db.system, db.name
+//
+//
+// MIT License
+//
+// Copyright (c) 2019 noknow.info
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+// PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+// HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+// OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTW//ARE.
+//////////////////////////////////////////////////////////////////////
+package otelmysql
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+    "time"
+    gomysql "github.com/go-sql-driver/mysql"
+    "go.opentelemetry.io/otel"
+    "go.opentelemetry.io/otel/attribute"
+    "go.opentelemetry.io/otel/codes"
+    "go.opentelemetry.io/otel/metric"
+    "go.opentelemetry.io/otel/trace"
+    myMySQL "mysql"
+)
+
+const (
+    INSTRUMENTATION_NAME = "mysql/otelmysql"
+    DB_SYSTEM = "mysql"
+
+    ATTR_DB_SYSTEM = "db.system"
+    ATTR_DB_NAME = "db.name"
+    ATTR_DB_STATEMENT = "db.statement"
+    ATTR_DB_OPERATION = "db.operation"
+    ATTR_DB_ROWS_AFFECTED = "db.sql.rows_affected"
+    ATTR_DB_PREPARED = "db.sql.prepared"
+    ATTR_DB_ERROR_NUMBER = "db.mysql.error_number"
+    ATTR_DB_CONNECTION_STATE = "state"
+
+    METRIC_OPERATION_DURATION = "db.client.operation.duration"
+    METRIC_ERRORS = "db.client.errors"
+    METRIC_CONNECTIONS_MAX = "db.client.connections.max"
+    METRIC_CONNECTIONS_USAGE = "db.client.connections.usage"
+    METRIC_CONNECTIONS_WAIT_COUNT = "db.client.connections.wait_count"
+    METRIC_CONNECTIONS_WAIT_TIME = "db.client.connections.wait_time"
+    METRIC_CONNECTIONS_CLOSED = "db.client.connections.closed"
+)
+
+type Options struct {
+    // Defaults to otel.GetTracerProvider().
+    TracerProvider trace.TracerProvider
+    // Defaults to otel.GetMeterProvider().
+    MeterProvider metric.MeterProvider
+    // Value of db.name.
+    DBName string
+    // Do not record db.statement.
+    DisableStatement bool
+    // Record the statement as it is, without replacing the literals.
+    RawStatement bool
+}
+
+type instrumentation struct {
+    o Options
+    tracer trace.Tracer
+    duration metric.Float64Histogram
+    errors metric.Int64Counter
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Create an interceptor which emits spans and metrics for each call.
+//////////////////////////////////////////////////////////////////////
+func NewInterceptor(opts *Options) (myMySQL.Interceptor, error) {
+    in := &instrumentation{}
+    if opts != nil {
+        in.o = *opts
+    }
+    if in.o.TracerProvider == nil {
+        in.o.TracerProvider = otel.GetTracerProvider()
+    }
+    if in.o.MeterProvider == nil {
+        in.o.MeterProvider = otel.GetMeterProvider()
+    }
+    in.tracer = in.o.TracerProvider.Tracer(INSTRUMENTATION_NAME)
+    meter := in.o.MeterProvider.Meter(INSTRUMENTATION_NAME)
+
+    var err error
+    in.duration, err = meter.Float64Histogram(METRIC_OPERATION_DURATION,
+        metric.WithDescription("Duration of database client operations."),
+        metric.WithUnit("s"))
+    if err != nil {
+        return nil, fmt.Errorf("meter.Float64Histogram() error: %w", err)
+    }
+    in.errors, err = meter.Int64Counter(METRIC_ERRORS,
+        metric.WithDescription("Number of failed database client operations."),
+        metric.WithUnit("{error}"))
+    if err != nil {
+        return nil, fmt.Errorf("meter.Int64Counter() error: %w", err)
+    }
+    return in.intercept, nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Run the call in a span.
+//////////////////////////////////////////////////////////////////////
+func (in *instrumentation) intercept(ctx context.Context, call *myMySQL.Call, next myMySQL.Handler) error {
+    operation := call.Operation()
+    attrs := []attribute.KeyValue{
+        attribute.String(ATTR_DB_SYSTEM, DB_SYSTEM),
+        attribute.String(ATTR_DB_OPERATION, operation),
+    }
+    if in.o.DBName != "" {
+        attrs = append(attrs, attribute.String(ATTR_DB_NAME, in.o.DBName))
+    }
+    spanAttrs := append([]attribute.KeyValue{}, attrs...)
+    if call.Query != "" && !in.o.DisableStatement {
+        statement := call.Query
+        if !in.o.RawStatement {
+            statement = Sanitize(statement)
+        }
+        spanAttrs = append(spanAttrs, attribute.String(ATTR_DB_STATEMENT, statement))
+    }
+    if call.Prepared {
+        spanAttrs = append(spanAttrs, attribute.Bool(ATTR_DB_PREPARED, true))
+    }
+
+    ctx, span := in.tracer.Start(ctx, operation,
+        trace.WithSpanKind(trace.SpanKindClient),
+        trace.WithAttributes(spanAttrs...))
+    defer span.End()
+
+    start := time.Now()
+    err := next(ctx, call)
+    in.duration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(attrs...))
+
+    if err != nil {
+        number := 0
+        var mysqlErr *gomysql.MySQLError
+        if errors.As(err, &mysqlErr) {
+            number = int(mysqlErr.Number)
+            span.SetAttributes(attribute.Int(ATTR_DB_ERROR_NUMBER, number))
+        }
+        span.RecordError(err)
+        span.SetStatus(codes.Error, err.Error())
+        in.errors.Add(ctx, 1, metric.WithAttributes(append(attrs, attribute.Int(ATTR_DB_ERROR_NUMBER, number))...))
+        return err
+    }
+    if call.Result != nil {
+        if n, err := call.Result.RowsAffected(); err == nil {
+            span.SetAttributes(attribute.Int64(ATTR_DB_ROWS_AFFECTED, n))
+        }
+    }
+    return nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Register gauges which observe the pool stats of the database.
+//////////////////////////////////////////////////////////////////////
+func RegisterDBStats(db *sql.DB, opts *Options) (metric.Registration, error) {
+    o := Options{}
+    if opts != nil {
+        o = *opts
+    }
+    if o.MeterProvider == nil {
+        o.MeterProvider = otel.GetMeterProvider()
+    }
+    meter := o.MeterProvider.Meter(INSTRUMENTATION_NAME)
+
+    maxOpen, err := meter.Int64ObservableGauge(METRIC_CONNECTIONS_MAX,
+        metric.WithDescription("Maximum number of open connections allowed."),
+        metric.WithUnit("{connection}"))
+    if err != nil {
+        return nil, fmt.Errorf("meter.Int64ObservableGauge() error: %w", err)
+    }
+    usage, err := meter.Int64ObservableGauge(METRIC_CONNECTIONS_USAGE,
+        metric.WithDescription("Number of connections by state."),
+        metric.WithUnit("{connection}"))
+    if err != nil {
+        return nil, fmt.Errorf("meter.Int64ObservableGauge() error: %w", err)
+    }
+    waitCount, err := meter.Int64ObservableCounter(METRIC_CONNECTIONS_WAIT_COUNT,
+        metric.WithDescription("Total number of connections waited for."),
+        metric.WithUnit("{wait}"))
+    if err != nil {
+        return nil, fmt.Errorf("meter.Int64ObservableCounter() error: %w", err)
+    }
+    waitTime, err := meter.Float64ObservableCounter(METRIC_CONNECTIONS_WAIT_TIME,
+        metric.WithDescription("Total time blocked waiting for a new connection."),
+        metric.WithUnit("s"))
+    if err != nil {
+        return nil, fmt.Errorf("meter.Float64ObservableCounter() error: %w", err)
+    }
+    closed, err := meter.Int64ObservableCounter(METRIC_CONNECTIONS_CLOSED,
+        metric.WithDescription("Total number of connections closed by the pool limits."),
+        metric.WithUnit("{connection}"))
+    if err != nil {
+        return nil, fmt.Errorf("meter.Int64ObservableCounter() error: %w", err)
+    }
+
+    attrs := []attribute.KeyValue{attribute.String(ATTR_DB_SYSTEM, DB_SYSTEM)}
+    if o.DBName != "" {
+        attrs = append(attrs, attribute.String(ATTR_DB_NAME, o.DBName))
+    }
+    common := metric.WithAttributes(attrs...)
+    idle := metric.WithAttributes(append(attrs, attribute.String(ATTR_DB_CONNECTION_STATE, "idle"))...)
+    used := metric.WithAttributes(append(attrs, attribute.String(ATTR_DB_CONNECTION_STATE, "used"))...)
+
+    registration, err := meter.RegisterCallback(func(ctx context.Context, observer metric.Observer) error {
+        stats := db.Stats()
+        observer.ObserveInt64(maxOpen, int64(stats.MaxOpenConnections), common)
+        observer.ObserveInt64(usage, int64(stats.Idle), idle)
+        observer.ObserveInt64(usage, int64(stats.InUse), used)
+        observer.ObserveInt64(waitCount, stats.WaitCount, common)
+        observer.ObserveFloat64(waitTime, stats.WaitDuration.Seconds(), common)
+        observer.ObserveInt64(closed, stats.MaxIdleClosed + stats.MaxIdleTimeClosed + stats.MaxLifetimeClosed, common)
+        return nil
+    }, maxOpen, usage, waitCount, waitTime, closed)
+    if err != nil {
+        return nil, fmt.Errorf("meter.RegisterCallback() error: %w", err)
+    }
+    return registration, nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get the W3C traceparent of the span in the context, or "".
+// Use it as myMySQL.AnnotateOptions.TraceParent.
+//////////////////////////////////////////////////////////////////////
+func TraceParent(ctx context.Context) string {
+    sc := trace.SpanContextFromContext(ctx)
+    if !sc.IsValid() {
+        return ""
+    }
+    return fmt.Sprintf("00-%s-%s-%s", sc.TraceID(), sc.SpanID(), sc.TraceFlags())
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Replace the string and number literals in the query with "?".
+//////////////////////////////////////////////////////////////////////
+func Sanitize(query string) string {
+    return myMySQL.SanitizeQuery(query)
+}
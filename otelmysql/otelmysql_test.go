@@ -0,0 +1,180 @@
+package otelmysql_test
+
+import (
+    "context"
+    "database/sql"
+    "testing"
+    myMySQL "mysql"
+    "mysql/mysqltest/embedded"
+    "mysql/otelmysql"
+    "go.opentelemetry.io/otel/attribute"
+    "go.opentelemetry.io/otel/codes"
+    sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+    "go.opentelemetry.io/otel/sdk/metric/metricdata"
+    sdktrace "go.opentelemetry.io/otel/sdk/trace"
+    "go.opentelemetry.io/otel/sdk/trace/tracetest"
+    "go.opentelemetry.io/otel/trace"
+)
+
+type telemetry struct {
+    spans *tracetest.SpanRecorder
+    tracerProvider *sdktrace.TracerProvider
+    reader *sdkmetric.ManualReader
+}
+
+func openDB(t *testing.T) (*sql.DB, *telemetry) {
+    t.Helper()
+    tel := &telemetry{
+        spans: tracetest.NewSpanRecorder(),
+        reader: sdkmetric.NewManualReader(),
+    }
+    tel.tracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(tel.spans))
+    interceptor, err := otelmysql.NewInterceptor(&otelmysql.Options{
+        TracerProvider: tel.tracerProvider,
+        MeterProvider: sdkmetric.NewMeterProvider(sdkmetric.WithReader(tel.reader)),
+        DBName: "test",
+    })
+    if err != nil {
+        t.Fatalf("otelmysql.NewInterceptor() error: %s", err)
+    }
+    setup := func(db *sql.DB) error {
+        _, err := db.Exec("CREATE TABLE users (id INT NOT NULL, name VARCHAR(64) NOT NULL, PRIMARY KEY(id))")
+        return err
+    }
+    db, _, err := myMySQL.OpenDB("mysql", embedded.NewDSN(t, &embedded.Options{Setup: []func(db *sql.DB) error{setup}}), interceptor)
+    if err != nil {
+        t.Fatalf("mysql.OpenDB() error: %s", err)
+    }
+    t.Cleanup(func() {
+        db.Close()
+    })
+    return db, tel
+}
+
+// The spans of the operation, e.g. without the connect spans.
+func (tel *telemetry) ended(operation string) []sdktrace.ReadOnlySpan {
+    var result []sdktrace.ReadOnlySpan
+    for _, span := range tel.spans.Ended() {
+        if span.Name() == operation {
+            result = append(result, span)
+        }
+    }
+    return result
+}
+
+func attrs(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+    result := make(map[attribute.Key]attribute.Value)
+    for _, kv := range span.Attributes() {
+        result[kv.Key] = kv.Value
+    }
+    return result
+}
+
+func TestSpanAttributes(t *testing.T) {
+    db, tel := openDB(t)
+    if _, err := db.Exec("INSERT INTO users VALUES (1, 'alice'), (2, 'bob')"); err != nil {
+        t.Fatalf("db.Exec() error: %s", err)
+    }
+
+    spans := tel.ended("INSERT")
+    if len(spans) != 1 {
+        t.Fatalf("INSERT spans = %d, want 1", len(spans))
+    }
+    span := spans[0]
+    if span.SpanKind() != trace.SpanKindClient {
+        t.Errorf("span kind = %s, want client", span.SpanKind())
+    }
+    a := attrs(span)
+    want := map[attribute.Key]string{
+        otelmysql.ATTR_DB_SYSTEM: otelmysql.DB_SYSTEM,
+        otelmysql.ATTR_DB_NAME: "test",
+        otelmysql.ATTR_DB_OPERATION: "INSERT",
+        otelmysql.ATTR_DB_STATEMENT: "INSERT INTO users VALUES (?, ?), (?, ?)",
+    }
+    for key, value := range want {
+        if a[key].AsString() != value {
+            t.Errorf("%s = %q, want %q", key, a[key].AsString(), value)
+        }
+    }
+    if n := a[otelmysql.ATTR_DB_ROWS_AFFECTED].AsInt64(); n != 2 {
+        t.Errorf("%s = %d, want 2", otelmysql.ATTR_DB_ROWS_AFFECTED, n)
+    }
+    if span.Status().Code != codes.Unset {
+        t.Errorf("status = %v, want unset", span.Status())
+    }
+}
+
+func TestSpanError(t *testing.T) {
+    db, tel := openDB(t)
+    if _, err := db.Exec("INSERT INTO missing VALUES (1)"); err == nil {
+        t.Fatalf("db.Exec() on a missing table succeeded")
+    }
+
+    spans := tel.ended("INSERT")
+    if len(spans) != 1 {
+        t.Fatalf("INSERT spans = %d, want 1", len(spans))
+    }
+    span := spans[0]
+    if span.Status().Code != codes.Error {
+        t.Errorf("status = %v, want error", span.Status())
+    }
+    if n := attrs(span)[otelmysql.ATTR_DB_ERROR_NUMBER].AsInt64(); n == 0 {
+        t.Errorf("%s is not set", otelmysql.ATTR_DB_ERROR_NUMBER)
+    }
+    if len(span.Events()) == 0 || span.Events()[0].Name != "exception" {
+        t.Errorf("events = %v, want the recorded error", span.Events())
+    }
+
+    var rm metricdata.ResourceMetrics
+    if err := tel.reader.Collect(context.Background(), &rm); err != nil {
+        t.Fatalf("reader.Collect() error: %s", err)
+    }
+    found := map[string]bool{}
+    for _, sm := range rm.ScopeMetrics {
+        for _, m := range sm.Metrics {
+            found[m.Name] = true
+            if m.Name != otelmysql.METRIC_ERRORS {
+                continue
+            }
+            sum, ok := m.Data.(metricdata.Sum[int64])
+            if !ok || len(sum.DataPoints) != 1 || sum.DataPoints[0].Value != 1 {
+                t.Errorf("%s = %+v, want 1 error", m.Name, m.Data)
+            }
+        }
+    }
+    for _, name := range []string{otelmysql.METRIC_OPERATION_DURATION, otelmysql.METRIC_ERRORS} {
+        if !found[name] {
+            t.Errorf("metric %s is not recorded", name)
+        }
+    }
+}
+
+func TestSpanParent(t *testing.T) {
+    db, tel := openDB(t)
+    ctx, parent := tel.tracerProvider.Tracer("test").Start(context.Background(), "request")
+    rows, err := db.QueryContext(ctx, "SELECT id FROM users WHERE name = ?", "alice")
+    if err != nil {
+        t.Fatalf("db.QueryContext() error: %s", err)
+    }
+    rows.Close()
+    parent.End()
+
+    var selects []sdktrace.ReadOnlySpan
+    for _, operation := range []string{"SELECT", "PREPARE"} {
+        selects = append(selects, tel.ended(operation)...)
+    }
+    if len(selects) == 0 {
+        t.Fatalf("no SELECT span")
+    }
+    for _, span := range selects {
+        if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+            t.Errorf("parent of %s = %s, want %s", span.Name(), span.Parent().SpanID(), parent.SpanContext().SpanID())
+        }
+        if span.SpanContext().TraceID() != parent.SpanContext().TraceID() {
+            t.Errorf("trace of %s = %s, want %s", span.Name(), span.SpanContext().TraceID(), parent.SpanContext().TraceID())
+        }
+    }
+    if a := attrs(tel.ended("SELECT")[0]); a[otelmysql.ATTR_DB_STATEMENT].AsString() != "SELECT id FROM users WHERE name = ?" {
+        t.Errorf("%s = %q", otelmysql.ATTR_DB_STATEMENT, a[otelmysql.ATTR_DB_STATEMENT].AsString())
+    }
+}
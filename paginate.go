@@ -0,0 +1,471 @@
+//////////////////////////////////////////////////////////////////////
+// paginate.go
+//
+// @usage
+//
+//     1. Import this package.
+//
+//         --------------------------------------------------
+//         import myMySQL "mysql"
+//         --------------------------------------------------
+//
+//     2. Create a paginator with a secret, which signs the cursors.
+//
+//         --------------------------------------------------
+//         paginator, err := myMySQL.NewPaginator([]byte(os.Getenv("CURSOR_SECRET")))
+//         --------------------------------------------------
+//
+//     3. Get a page. scan is called for each row and returns the pointers
+//        to scan the Columns into.
+//
+//         --------------------------------------------------
+//         var result []Country
+//         page, err := paginator.Paginate(ctx, myMySQL.Conn(), &myMySQL.PageQuery{
+//             Table: "countries",
+//             Columns: []string{"country_code", "en"},
+//             Where: "status = ?",
+//             Args: []interface{}{1},
+//             Sort: []myMySQL.SortKey{{Column: "continent", Desc: true}},
+//             Limit: 20,
+//             Cursor: r.URL.Query().Get("cursor"),
+//         }, func() []interface{} {
+//             result = append(result, Country{})
+//             c := &result[len(result) - 1]
+//             return []interface{}{&c.CountryCode, &c.En}
+//         })
+//         if errors.Is(err, myMySQL.ErrInvalidCursor) {
+//             // Respond 400.
+//         }
+//         // page.Next and page.Prev are the cursors of the next and the
+//         // previous pages, empty on the last and the first page.
+//         --------------------------------------------------
+//
+//     The rows after (or before) the sort key values of the cursor are
+//     fetched, so the pages do not shift when rows are inserted, and deep
+//     pages are as fast as the first one with an index on the sort columns.
+//     The primary key is added to the sort as a tie-breaker. The sort
+//     columns must be NOT NULL.
+//     A cursor is only valid for the same table and sort, and a changed
+//     cursor is rejected with ErrInvalidCursor.
+//
+//
+// MIT License
+//
+// Copyright (c) 2019 noknow.info
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+// PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+// HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+// OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTW//ARE.
+//////////////////////////////////////////////////////////////////////
+package mysql
+
+import (
+    "context"
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "strings"
+    "sync"
+    "time"
+)
+
+const (
+    DEFAULT_PAGE_SIZE = 20
+    CURSOR_NEXT = "next"
+    CURSOR_PREV = "prev"
+    CURSOR_TIME_FORMAT = "2006-01-02 15:04:05.999999"
+)
+
+var (
+    ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+type SortKey struct {
+    Column string
+    Desc bool
+}
+
+type PageQuery struct {
+    Table string
+    // Selected columns, scanned into the pointers returned by scan.
+    Columns []string
+    // Condition without WHERE, e.g. "status = ?".
+    Where string
+    Args []interface{}
+    Sort []SortKey
+    // Tie-breakers added to Sort. Defaults to the primary key of Table,
+    // read once per paginator.
+    PrimaryKey []string
+    // Defaults to DEFAULT_PAGE_SIZE.
+    Limit int
+    // Next or Prev of a page. Empty for the first page.
+    Cursor string
+}
+
+type Page struct {
+    // Cursor of the next page. Empty on the last page.
+    Next string
+    // Cursor of the previous page. Empty on the first page.
+    Prev string
+}
+
+type Paginator struct {
+    secret []byte
+    mu sync.Mutex
+    // Primary keys by table.
+    primaryKeys map[string][]string
+}
+
+type pageCursor struct {
+    // Table and sort the cursor was made for.
+    Spec string `json:"s"`
+    Direction string `json:"d"`
+    Key []interface{} `json:"k"`
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Create a paginator which signs the cursors with the secret.
+//////////////////////////////////////////////////////////////////////
+func NewPaginator(secret []byte) (*Paginator, error) {
+    if len(secret) == 0 {
+        return nil, fmt.Errorf("cursor secret is required")
+    }
+    return &Paginator{
+        secret: append([]byte{}, secret...),
+        primaryKeys: map[string][]string{},
+    }, nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get the page of the cursor. scan is called for each row in the sort
+// order.
+//////////////////////////////////////////////////////////////////////
+func (p *Paginator) Paginate(ctx context.Context, q Queryer, pq *PageQuery, scan func() []interface{}) (*Page, error) {
+    if pq.Table == "" || len(pq.Columns) == 0 {
+        return nil, fmt.Errorf("the table and the columns are required")
+    }
+    limit := pq.Limit
+    if limit <= 0 {
+        limit = DEFAULT_PAGE_SIZE
+    }
+    sortKeys, err := p.sortKeys(ctx, q, pq)
+    if err != nil {
+        return nil, err
+    }
+    spec := pageSpec(pq.Table, sortKeys)
+
+    var cursor *pageCursor
+    if pq.Cursor != "" {
+        if cursor, err = p.decode(pq.Cursor); err != nil {
+            return nil, err
+        }
+        if cursor.Spec != spec || len(cursor.Key) != len(sortKeys) {
+            return nil, fmt.Errorf("%w: made for another sort", ErrInvalidCursor)
+        }
+    }
+
+    // The start of the page, and whether it is included.
+    var start []interface{}
+    inclusive := false
+    hasPrev := false
+    switch {
+    case cursor == nil:
+    case cursor.Direction == CURSOR_NEXT:
+        start = cursor.Key
+        hasPrev = true
+    default:
+        // Find the start of the previous page backwards.
+        keys, err := pageKeys(ctx, q, pq, sortKeys, cursor.Key, limit + 1)
+        if err != nil {
+            return nil, err
+        }
+        if len(keys) > limit {
+            start = keys[limit - 1]
+            inclusive = true
+            hasPrev = true
+        }
+    }
+
+    where, args := pageCondition(pq, sortKeys, start, false, inclusive)
+    query := "SELECT " + strings.Join(quoteIdentifiers(sortColumns(sortKeys)), ", ") + ", " +
+            strings.Join(quoteIdentifiers(pq.Columns), ", ") +
+            " FROM " + QuoteIdentifier(pq.Table) + where +
+            " ORDER BY " + pageOrder(sortKeys, false) +
+            fmt.Sprintf(" LIMIT %d", limit + 1)
+    rows, err := q.QueryContext(ctx, query, args...)
+    if err != nil {
+        return nil, fmt.Errorf("QueryContext() error: %w", err)
+    }
+    defer rows.Close()
+
+    var first, last []interface{}
+    n := 0
+    for n < limit && rows.Next() {
+        key := make([]interface{}, len(sortKeys))
+        dest := make([]interface{}, len(sortKeys))
+        for i := range key {
+            dest[i] = &key[i]
+        }
+        if err := rows.Scan(append(dest, scan()...)...); err != nil {
+            return nil, fmt.Errorf("rows.Scan() error: %w", err)
+        }
+        if first == nil {
+            first = key
+        }
+        last = key
+        n++
+    }
+    hasNext := n == limit && rows.Next()
+    if err := rows.Err(); err != nil {
+        return nil, fmt.Errorf("rows.Next() error: %w", err)
+    }
+
+    page := &Page{}
+    if hasNext {
+        if page.Next, err = p.encode(&pageCursor{Spec: spec, Direction: CURSOR_NEXT, Key: last}); err != nil {
+            return nil, err
+        }
+    }
+    if hasPrev && first != nil {
+        if page.Prev, err = p.encode(&pageCursor{Spec: spec, Direction: CURSOR_PREV, Key: first}); err != nil {
+            return nil, err
+        }
+    }
+    return page, nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get the sort with the primary key as tie-breakers.
+//////////////////////////////////////////////////////////////////////
+func (p *Paginator) sortKeys(ctx context.Context, q Queryer, pq *PageQuery) ([]SortKey, error) {
+    primaryKey := pq.PrimaryKey
+    if len(primaryKey) == 0 {
+        var err error
+        if primaryKey, err = p.primaryKey(ctx, q, pq.Table); err != nil {
+            return nil, err
+        }
+    }
+    sortKeys := append([]SortKey{}, pq.Sort...)
+    for _, column := range primaryKey {
+        found := false
+        for _, key := range sortKeys {
+            found = found || key.Column == column
+        }
+        if !found {
+            sortKeys = append(sortKeys, SortKey{Column: column})
+        }
+    }
+    return sortKeys, nil
+}
+
+//////////////////////////////////////////////////////////////////////
+// Get the primary key of the table, read from the schema the first time.
+//////////////////////////////////////////////////////////////////////
+func (p *Paginator) primaryKey(ctx context.Context, q Queryer, table string) ([]string, error) {
+    p.mu.Lock()
+    primaryKey, ok := p.primaryKeys[table]
+    p.mu.Unlock()
+    if ok {
+        return primaryKey, nil
+    }
+    primaryKeys, err := PrimaryKeys(ctx, q)
+    if err != nil {
+        return nil, err
+    }
+    if primaryKey = primaryKeys[table]; len(primaryKey) == 0 {
+        // Not cached, the table may get one.
+        return nil, fmt.Errorf("no primary key in %s", table)
+    }
+    p.mu.Lock()
+    p.primaryKeys[table] = primaryKey
+    p.mu.Unlock()
+    return primaryKey, nil
+}
+
+func pageSpec(table string, sortKeys []SortKey) string {
+    parts := []string{table}
+    for _, key := range sortKeys {
+        if key.Desc {
+            parts = append(parts, key.Column + " DESC")
+        } else {
+            parts = append(parts, key.Column)
+        }
+    }
+    return strings.Join(parts, ",")
+}
+
+func sortColumns(sortKeys []SortKey) []string {
+    columns := make([]string, len(sortKeys))
+    for i, key := range sortKeys {
+        columns[i] = key.Column
+    }
+    return columns
+}
+
+func pageOrder(sortKeys []SortKey, backward bool) string {
+    parts := make([]string, len(sortKeys))
+    for i, key := range sortKeys {
+        if key.Desc != backward {
+            parts[i] = QuoteIdentifier(key.Column) + " DESC"
+        } else {
+            parts[i] = QuoteIdentifier(key.Column) + " ASC"
+        }
+    }
+    return strings.Join(parts, ", ")
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Build the WHERE clause of Where and of the rows after the key in the
+// sort order (before it when backward), e.g.
+// a > ? OR (a = ? AND b < ?) for "a, b DESC".
+//////////////////////////////////////////////////////////////////////
+func pageCondition(pq *PageQuery, sortKeys []SortKey, key []interface{}, backward, inclusive bool) (string, []interface{}) {
+    var conditions []string
+    args := []interface{}{}
+    if pq.Where != "" {
+        conditions = append(conditions, "(" + pq.Where + ")")
+        args = append(args, pq.Args...)
+    }
+    if key != nil {
+        var or []string
+        for i := range sortKeys {
+            var and []string
+            for j := 0; j < i; j++ {
+                and = append(and, QuoteIdentifier(sortKeys[j].Column) + " = ?")
+                args = append(args, key[j])
+            }
+            op := ">"
+            if sortKeys[i].Desc != backward {
+                op = "<"
+            }
+            if inclusive && i == len(sortKeys) - 1 {
+                op += "="
+            }
+            and = append(and, QuoteIdentifier(sortKeys[i].Column) + " " + op + " ?")
+            args = append(args, key[i])
+            or = append(or, "(" + strings.Join(and, " AND ") + ")")
+        }
+        conditions = append(conditions, "(" + strings.Join(or, " OR ") + ")")
+    }
+    if len(conditions) == 0 {
+        return "", args
+    }
+    return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get the sort keys of up to n rows before the key, nearest first.
+//////////////////////////////////////////////////////////////////////
+func pageKeys(ctx context.Context, q Queryer, pq *PageQuery, sortKeys []SortKey, key []interface{}, n int) ([][]interface{}, error) {
+    where, args := pageCondition(pq, sortKeys, key, true, false)
+    query := "SELECT " + strings.Join(quoteIdentifiers(sortColumns(sortKeys)), ", ") +
+            " FROM " + QuoteIdentifier(pq.Table) + where +
+            " ORDER BY " + pageOrder(sortKeys, true) +
+            fmt.Sprintf(" LIMIT %d", n)
+    rows, err := q.QueryContext(ctx, query, args...)
+    if err != nil {
+        return nil, fmt.Errorf("QueryContext() error: %w", err)
+    }
+    defer rows.Close()
+    var keys [][]interface{}
+    for rows.Next() {
+        key := make([]interface{}, len(sortKeys))
+        dest := make([]interface{}, len(sortKeys))
+        for i := range key {
+            dest[i] = &key[i]
+        }
+        if err := rows.Scan(dest...); err != nil {
+            return nil, fmt.Errorf("rows.Scan() error: %w", err)
+        }
+        keys = append(keys, key)
+    }
+    return keys, rows.Err()
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Encode and sign the cursor.
+//////////////////////////////////////////////////////////////////////
+func (p *Paginator) encode(cursor *pageCursor) (string, error) {
+    key := make([]interface{}, len(cursor.Key))
+    for i, v := range cursor.Key {
+        switch v := v.(type) {
+        case nil:
+            return "", fmt.Errorf("NULL in the sort column %d", i + 1)
+        case []byte:
+            key[i] = string(v)
+        case time.Time:
+            key[i] = v.Format(CURSOR_TIME_FORMAT)
+        default:
+            key[i] = v
+        }
+    }
+    payload, err := json.Marshal(&pageCursor{Spec: cursor.Spec, Direction: cursor.Direction, Key: key})
+    if err != nil {
+        return "", fmt.Errorf("json.Marshal() error: %w", err)
+    }
+    return base64.RawURLEncoding.EncodeToString(payload) + "." +
+            base64.RawURLEncoding.EncodeToString(p.sign(payload)), nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Verify and decode the cursor.
+//////////////////////////////////////////////////////////////////////
+func (p *Paginator) decode(s string) (*pageCursor, error) {
+    encodedPayload, encodedMAC, ok := strings.Cut(s, ".")
+    if !ok {
+        return nil, ErrInvalidCursor
+    }
+    payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+    if err != nil {
+        return nil, ErrInvalidCursor
+    }
+    mac, err := base64.RawURLEncoding.DecodeString(encodedMAC)
+    if err != nil || !hmac.Equal(mac, p.sign(payload)) {
+        return nil, ErrInvalidCursor
+    }
+    cursor := &pageCursor{}
+    decoder := json.NewDecoder(strings.NewReader(string(payload)))
+    // Big integers stay exact.
+    decoder.UseNumber()
+    if err := decoder.Decode(cursor); err != nil {
+        return nil, ErrInvalidCursor
+    }
+    if cursor.Direction != CURSOR_NEXT && cursor.Direction != CURSOR_PREV {
+        return nil, ErrInvalidCursor
+    }
+    for i, v := range cursor.Key {
+        if number, ok := v.(json.Number); ok {
+            cursor.Key[i] = number.String()
+        }
+    }
+    return cursor, nil
+}
+
+func (p *Paginator) sign(payload []byte) []byte {
+    mac := hmac.New(sha256.New, p.secret)
+    mac.Write(payload)
+    return mac.Sum(nil)
+}
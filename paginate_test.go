@@ -0,0 +1,163 @@
+package mysql_test
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "reflect"
+    "strings"
+    "sync/atomic"
+    "testing"
+    myMySQL "mysql"
+    "mysql/mysqltest/embedded"
+)
+
+// Open a database with the items 1 to 7, which counts the reads of the
+// primary keys.
+func openItemsDBT(t testing.TB, schemaReads *atomic.Int64) *sql.DB {
+    t.Helper()
+    dsn := embedded.NewDSN(t, &embedded.Options{
+        Setup: []func(db *sql.DB) error{
+            func(db *sql.DB) error {
+                _, err := db.Exec("CREATE TABLE items (id INT PRIMARY KEY, name VARCHAR(10), score INT)")
+                return err
+            },
+            func(db *sql.DB) error {
+                _, err := db.Exec("INSERT INTO items VALUES (1, 'a', 30), (2, 'b', 10), (3, 'c', 30), (4, 'd', 20), (5, 'e', 10), (6, 'f', 30), (7, 'g', 20)")
+                return err
+            },
+        },
+    })
+    db, _, err := myMySQL.OpenDB("mysql", dsn, func(ctx context.Context, call *myMySQL.Call, next myMySQL.Handler) error {
+        if strings.Contains(call.Query, "KEY_COLUMN_USAGE") {
+            schemaReads.Add(1)
+        }
+        return next(ctx, call)
+    })
+    if err != nil {
+        t.Fatalf("OpenDB() error: %s", err)
+    }
+    t.Cleanup(func() {
+        db.Close()
+    })
+    return db
+}
+
+func TestPaginate(t *testing.T) {
+    var schemaReads atomic.Int64
+    db := openItemsDBT(t, &schemaReads)
+    paginator, err := myMySQL.NewPaginator([]byte("secret"))
+    if err != nil {
+        t.Fatalf("NewPaginator() error: %s", err)
+    }
+    tests := []struct {
+        name string
+        sort []myMySQL.SortKey
+        where string
+        args []interface{}
+        pages [][]int
+    }{
+        {"primary key", nil, "", nil, [][]int{{1, 2, 3}, {4, 5, 6}, {7}}},
+        {"descending with ties", []myMySQL.SortKey{{Column: "score", Desc: true}}, "", nil, [][]int{{1, 3, 6}, {4, 7, 2}, {5}}},
+        {"ascending with ties", []myMySQL.SortKey{{Column: "score"}}, "", nil, [][]int{{2, 5, 4}, {7, 1, 3}, {6}}},
+        {"where", []myMySQL.SortKey{{Column: "name", Desc: true}}, "score <> ?", []interface{}{30}, [][]int{{7, 5, 4}, {2}}},
+        {"one page", nil, "id < ?", []interface{}{3}, [][]int{{1, 2}}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            pq := myMySQL.PageQuery{Table: "items", Columns: []string{"id"}, Where: tt.where, Args: tt.args, Sort: tt.sort, Limit: 3}
+            pages := []*myMySQL.Page{}
+            // Forward with Next.
+            for i, want := range tt.pages {
+                ids, page := pageIDsT(t, paginator, db, pq)
+                if !reflect.DeepEqual(ids, want) {
+                    t.Fatalf("page %d = %v, want %v", i + 1, ids, want)
+                }
+                if (page.Prev == "") != (i == 0) || (page.Next == "") != (i == len(tt.pages) - 1) {
+                    t.Fatalf("page %d cursors = %+v", i + 1, page)
+                }
+                pages = append(pages, page)
+                pq.Cursor = page.Next
+            }
+            // Backward with Prev.
+            for i := len(tt.pages) - 2; i >= 0; i-- {
+                pq.Cursor = pages[i + 1].Prev
+                ids, page := pageIDsT(t, paginator, db, pq)
+                if !reflect.DeepEqual(ids, tt.pages[i]) {
+                    t.Fatalf("page %d backward = %v, want %v", i + 1, ids, tt.pages[i])
+                }
+                if (page.Prev == "") != (i == 0) {
+                    t.Fatalf("page %d backward cursors = %+v", i + 1, page)
+                }
+            }
+        })
+    }
+    // The primary key of the table is read once.
+    if got := schemaReads.Load(); got != 1 {
+        t.Errorf("primary key reads = %d, want 1", got)
+    }
+}
+
+func TestPaginateInvalidCursor(t *testing.T) {
+    var schemaReads atomic.Int64
+    db := openItemsDBT(t, &schemaReads)
+    paginator, err := myMySQL.NewPaginator([]byte("secret"))
+    if err != nil {
+        t.Fatalf("NewPaginator() error: %s", err)
+    }
+    other, err := myMySQL.NewPaginator([]byte("other secret"))
+    if err != nil {
+        t.Fatalf("NewPaginator() error: %s", err)
+    }
+    pq := myMySQL.PageQuery{Table: "items", Columns: []string{"id"}, Limit: 3}
+    _, page := pageIDsT(t, paginator, db, pq)
+    _, otherPage := pageIDsT(t, other, db, pq)
+    payload, mac, _ := strings.Cut(page.Next, ".")
+    tampered := []byte(payload)
+    tampered[len(tampered) / 2] ^= 1
+    forged := []byte(mac)
+    forged[0] ^= 1
+
+    tests := []struct {
+        name string
+        cursor string
+        sort []myMySQL.SortKey
+    }{
+        {"not a cursor", "cursor", nil},
+        {"tampered payload", string(tampered) + "." + mac, nil},
+        {"tampered signature", payload + "." + string(forged), nil},
+        {"no signature", payload, nil},
+        {"another secret", otherPage.Next, nil},
+        {"another sort", page.Next, []myMySQL.SortKey{{Column: "score"}}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            pq := pq
+            pq.Cursor = tt.cursor
+            pq.Sort = tt.sort
+            _, err := paginator.Paginate(context.Background(), db, &pq, func() []interface{} {
+                return []interface{}{new(int)}
+            })
+            if !errors.Is(err, myMySQL.ErrInvalidCursor) {
+                t.Errorf("Paginate() error = %v, want %v", err, myMySQL.ErrInvalidCursor)
+            }
+        })
+    }
+}
+
+func pageIDsT(t testing.TB, paginator *myMySQL.Paginator, db *sql.DB, pq myMySQL.PageQuery) ([]int, *myMySQL.Page) {
+    t.Helper()
+    ids := []*int{}
+    page, err := paginator.Paginate(context.Background(), db, &pq, func() []interface{} {
+        ids = append(ids, new(int))
+        return []interface{}{ids[len(ids) - 1]}
+    })
+    if err != nil {
+        t.Fatalf("Paginate() error: %s", err)
+    }
+    result := make([]int, len(ids))
+    for i, id := range ids {
+        result[i] = *id
+    }
+    return result, page
+}
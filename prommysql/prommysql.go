@@ -0,0 +1,197 @@
+//////////////////////////////////////////////////////////////////////
+// prommysql.go
+//
+// @usage
+//
+//     1. Import this package.
+//
+//         --------------------------------------------------
+//         import (
+//             myMySQL "mysql"
+//             "mysql/prommysql"
+//         )
+//         --------------------------------------------------
+//
+//     2. Register the collector and its interceptor before Init().
+//
+//         --------------------------------------------------
+//         collector := prommysql.NewCollector(nil)
+//         prometheus.MustRegister(collector)
+//         myMySQL.Use(collector.Interceptor(myMySQL.DEFAULT_CONNECTION_NAME))
+//         myMySQL.Init(datasourceName)
+//         --------------------------------------------------
+//
+//     The pool stats of every connection registered with myMySQL.Register()
+//     (Init() registers "default") are reported with the "connection" label:
+//
+//         mysql_pool_max_open_connections
+//         mysql_pool_open_connections
+//         mysql_pool_in_use_connections
+//         mysql_pool_idle_connections
+//         mysql_pool_wait_count_total
+//         mysql_pool_wait_duration_seconds_total
+//         mysql_pool_max_idle_closed_total
+//         mysql_pool_max_idle_time_closed_total
+//         mysql_pool_max_lifetime_closed_total
+//
+//     The interceptor reports the calls with the "connection", "operation"
+//     and "table" labels:
+//
+//         mysql_query_duration_seconds
+//         mysql_query_errors_total
+//
+//
+// MIT License
+//
+// Copyright (c) 2019 noknow.info
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+// PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+// HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+// OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTW//ARE.
+//////////////////////////////////////////////////////////////////////
+package prommysql
+
+import (
+    "context"
+    "time"
+    "github.com/prometheus/client_golang/prometheus"
+    myMySQL "mysql"
+)
+
+const (
+    NAMESPACE = "mysql"
+    LABEL_CONNECTION = "connection"
+    LABEL_OPERATION = "operation"
+    LABEL_TABLE = "table"
+)
+
+type Options struct {
+    // Buckets of mysql_query_duration_seconds. Defaults to prometheus.DefBuckets.
+    Buckets []float64
+    // Constant labels of all the metrics.
+    ConstLabels prometheus.Labels
+}
+
+type Collector struct {
+    maxOpen *prometheus.Desc
+    open *prometheus.Desc
+    inUse *prometheus.Desc
+    idle *prometheus.Desc
+    waitCount *prometheus.Desc
+    waitDuration *prometheus.Desc
+    maxIdleClosed *prometheus.Desc
+    maxIdleTimeClosed *prometheus.Desc
+    maxLifetimeClosed *prometheus.Desc
+    duration *prometheus.HistogramVec
+    errors *prometheus.CounterVec
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Create a collector.
+//////////////////////////////////////////////////////////////////////
+func NewCollector(opts *Options) *Collector {
+    o := Options{}
+    if opts != nil {
+        o = *opts
+    }
+    if o.Buckets == nil {
+        o.Buckets = prometheus.DefBuckets
+    }
+    desc := func(name, help string) *prometheus.Desc {
+        return prometheus.NewDesc(prometheus.BuildFQName(NAMESPACE, "pool", name), help, []string{LABEL_CONNECTION}, o.ConstLabels)
+    }
+    queryLabels := []string{LABEL_CONNECTION, LABEL_OPERATION, LABEL_TABLE}
+    return &Collector{
+        maxOpen: desc("max_open_connections", "Maximum number of open connections to the database."),
+        open: desc("open_connections", "The number of established connections both in use and idle."),
+        inUse: desc("in_use_connections", "The number of connections currently in use."),
+        idle: desc("idle_connections", "The number of idle connections."),
+        waitCount: desc("wait_count_total", "The total number of connections waited for."),
+        waitDuration: desc("wait_duration_seconds_total", "The total time blocked waiting for a new connection."),
+        maxIdleClosed: desc("max_idle_closed_total", "The total number of connections closed due to SetMaxIdleConns."),
+        maxIdleTimeClosed: desc("max_idle_time_closed_total", "The total number of connections closed due to SetConnMaxIdleTime."),
+        maxLifetimeClosed: desc("max_lifetime_closed_total", "The total number of connections closed due to SetConnMaxLifetime."),
+        duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+            Namespace: NAMESPACE,
+            Subsystem: "query",
+            Name: "duration_seconds",
+            Help: "Duration of the queries, execs, prepares and transaction calls.",
+            ConstLabels: o.ConstLabels,
+            Buckets: o.Buckets,
+        }, queryLabels),
+        errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+            Namespace: NAMESPACE,
+            Subsystem: "query",
+            Name: "errors_total",
+            Help: "The total number of failed queries, execs, prepares and transaction calls.",
+            ConstLabels: o.ConstLabels,
+        }, queryLabels),
+    }
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Create an interceptor which observes the calls of the connection.
+//////////////////////////////////////////////////////////////////////
+func (c *Collector) Interceptor(connection string) myMySQL.Interceptor {
+    return myMySQL.AfterHook(func(ctx context.Context, call *myMySQL.Call, duration time.Duration, err error) {
+        labels := prometheus.Labels{
+            LABEL_CONNECTION: connection,
+            LABEL_OPERATION: call.Operation(),
+            LABEL_TABLE: call.Table(),
+        }
+        c.duration.With(labels).Observe(duration.Seconds())
+        if err != nil {
+            c.errors.With(labels).Inc()
+        }
+    })
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// prometheus.Collector
+//////////////////////////////////////////////////////////////////////
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+    ch <- c.maxOpen
+    ch <- c.open
+    ch <- c.inUse
+    ch <- c.idle
+    ch <- c.waitCount
+    ch <- c.waitDuration
+    ch <- c.maxIdleClosed
+    ch <- c.maxIdleTimeClosed
+    ch <- c.maxLifetimeClosed
+    c.duration.Describe(ch)
+    c.errors.Describe(ch)
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+    for name, db := range myMySQL.Registered() {
+        stats := db.Stats()
+        ch <- prometheus.MustNewConstMetric(c.maxOpen, prometheus.GaugeValue, float64(stats.MaxOpenConnections), name)
+        ch <- prometheus.MustNewConstMetric(c.open, prometheus.GaugeValue, float64(stats.OpenConnections), name)
+        ch <- prometheus.MustNewConstMetric(c.inUse, prometheus.GaugeValue, float64(stats.InUse), name)
+        ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(stats.Idle), name)
+        ch <- prometheus.MustNewConstMetric(c.waitCount, prometheus.CounterValue, float64(stats.WaitCount), name)
+        ch <- prometheus.MustNewConstMetric(c.waitDuration, prometheus.CounterValue, stats.WaitDuration.Seconds(), name)
+        ch <- prometheus.MustNewConstMetric(c.maxIdleClosed, prometheus.CounterValue, float64(stats.MaxIdleClosed), name)
+        ch <- prometheus.MustNewConstMetric(c.maxIdleTimeClosed, prometheus.CounterValue, float64(stats.MaxIdleTimeClosed), name)
+        ch <- prometheus.MustNewConstMetric(c.maxLifetimeClosed, prometheus.CounterValue, float64(stats.MaxLifetimeClosed), name)
+    }
+    c.duration.Collect(ch)
+    c.errors.Collect(ch)
+}
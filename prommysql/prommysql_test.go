@@ -0,0 +1,107 @@
+package prommysql_test
+
+import (
+    "errors"
+    "strings"
+    "testing"
+    "github.com/prometheus/client_golang/prometheus"
+    "github.com/prometheus/client_golang/prometheus/testutil"
+    myMySQL "mysql"
+    "mysql/mysqltest"
+    "mysql/prommysql"
+)
+
+func TestCollector(t *testing.T) {
+    collector := prommysql.NewCollector(&prommysql.Options{
+        Buckets: []float64{60},
+        ConstLabels: prometheus.Labels{"app": "test"},
+    })
+    registry := prometheus.NewRegistry()
+    if err := registry.Register(collector); err != nil {
+        t.Fatalf("registry.Register() error: %s", err)
+    }
+
+    _, mock := mysqltest.NewT(t)
+    mock.ExpectQuery("SELECT * FROM countries").WillReturnRows(mysqltest.NewRows("country_code").AddRow("JP"))
+    mock.ExpectExec("UPDATE countries SET status = 1").WillReturnResult(0, 1)
+    mock.ExpectExec("INSERT INTO countries (country_code) VALUES ('JP')").WillReturnError(errors.New("duplicate entry"))
+    db, _, err := myMySQL.OpenDB(mysqltest.DRIVER_NAME, mock.DSN(), collector.Interceptor("test"))
+    if err != nil {
+        t.Fatalf("mysql.OpenDB() error: %s", err)
+    }
+    defer db.Close()
+    db.SetMaxOpenConns(2)
+    myMySQL.Register("test", db)
+    defer myMySQL.Unregister("test")
+
+    rows, err := db.Query("SELECT * FROM countries")
+    if err != nil {
+        t.Fatalf("db.Query() error: %s", err)
+    }
+    rows.Close()
+    if _, err := db.Exec("UPDATE countries SET status = 1"); err != nil {
+        t.Fatalf("db.Exec() error: %s", err)
+    }
+    if _, err := db.Exec("INSERT INTO countries (country_code) VALUES ('JP')"); err == nil {
+        t.Fatal("db.Exec() error = nil, want the duplicate entry")
+    }
+
+    expected := `
+# HELP mysql_query_errors_total The total number of failed queries, execs, prepares and transaction calls.
+# TYPE mysql_query_errors_total counter
+mysql_query_errors_total{app="test",connection="test",operation="INSERT",table="countries"} 1
+# HELP mysql_pool_max_open_connections Maximum number of open connections to the database.
+# TYPE mysql_pool_max_open_connections gauge
+mysql_pool_max_open_connections{app="test",connection="test"} 2
+# HELP mysql_pool_open_connections The number of established connections both in use and idle.
+# TYPE mysql_pool_open_connections gauge
+mysql_pool_open_connections{app="test",connection="test"} 1
+# HELP mysql_pool_in_use_connections The number of connections currently in use.
+# TYPE mysql_pool_in_use_connections gauge
+mysql_pool_in_use_connections{app="test",connection="test"} 0
+# HELP mysql_pool_idle_connections The number of idle connections.
+# TYPE mysql_pool_idle_connections gauge
+mysql_pool_idle_connections{app="test",connection="test"} 1
+`
+    if err := testutil.CollectAndCompare(collector, strings.NewReader(expected),
+            "mysql_query_errors_total",
+            "mysql_pool_max_open_connections",
+            "mysql_pool_open_connections",
+            "mysql_pool_in_use_connections",
+            "mysql_pool_idle_connections"); err != nil {
+        t.Error(err)
+    }
+
+    // The durations vary, so only the sample counts are compared.
+    families, err := registry.Gather()
+    if err != nil {
+        t.Fatalf("registry.Gather() error: %s", err)
+    }
+    counts := make(map[string]uint64)
+    for _, family := range families {
+        if family.GetName() != "mysql_query_duration_seconds" {
+            continue
+        }
+        for _, m := range family.GetMetric() {
+            var labels []string
+            for _, l := range m.GetLabel() {
+                labels = append(labels, l.GetName() + "=" + l.GetValue())
+            }
+            counts[strings.Join(labels, ",")] = m.GetHistogram().GetSampleCount()
+        }
+    }
+    want := map[string]uint64{
+        "app=test,connection=test,operation=CONNECT,table=": 1,
+        "app=test,connection=test,operation=SELECT,table=countries": 1,
+        "app=test,connection=test,operation=UPDATE,table=countries": 1,
+        "app=test,connection=test,operation=INSERT,table=countries": 1,
+    }
+    for labels, count := range want {
+        if counts[labels] != count {
+            t.Errorf("mysql_query_duration_seconds{%s} count = %d, want %d", labels, counts[labels], count)
+        }
+    }
+    if len(counts) != len(want) {
+        t.Errorf("mysql_query_duration_seconds = %v, want %v", counts, want)
+    }
+}
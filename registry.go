@@ -0,0 +1,114 @@
+//////////////////////////////////////////////////////////////////////
+// registry.go
+//
+// @usage
+//
+//     1. Import this package.
+//
+//         --------------------------------------------------
+//         import myMySQL "mysql"
+//         --------------------------------------------------
+//
+//     2. Register a named connection.
+//        Init() registers Conn() as DEFAULT_CONNECTION_NAME.
+//
+//         --------------------------------------------------
+//         replica, err := sql.Open("mysql", replicaDatasourceName)
+//         if err != nil {
+//             // Error handling.
+//         }
+//         myMySQL.Register("replica", replica)
+//         --------------------------------------------------
+//
+//     3. Get the connections.
+//
+//         --------------------------------------------------
+//         db := myMySQL.Lookup("replica")
+//         for name, db := range myMySQL.Registered() {
+//             stats := db.Stats()
+//             // ...
+//         }
+//         --------------------------------------------------
+//
+//
+// MIT License
+//
+// Copyright (c) 2019 noknow.info
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+// PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+// HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+// OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTW//ARE.
+//////////////////////////////////////////////////////////////////////
+package mysql
+
+import (
+    "database/sql"
+    "sync"
+)
+
+const (
+    DEFAULT_CONNECTION_NAME = "default"
+)
+
+var (
+    myRegistry = map[string]*sql.DB{}
+    myRegistryMu sync.RWMutex
+)
+
+
+//////////////////////////////////////////////////////////////////////
+// Register a connection with the name. It replaces the connection
+// registered with the same name.
+//////////////////////////////////////////////////////////////////////
+func Register(name string, db *sql.DB) {
+    myRegistryMu.Lock()
+    myRegistry[name] = db
+    myRegistryMu.Unlock()
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Unregister the connection. It is not closed.
+//////////////////////////////////////////////////////////////////////
+func Unregister(name string) {
+    myRegistryMu.Lock()
+    delete(myRegistry, name)
+    myRegistryMu.Unlock()
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get the connection registered with the name, or nil.
+//////////////////////////////////////////////////////////////////////
+func Lookup(name string) *sql.DB {
+    myRegistryMu.RLock()
+    defer myRegistryMu.RUnlock()
+    return myRegistry[name]
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get a copy of the registered connections by name.
+//////////////////////////////////////////////////////////////////////
+func Registered() map[string]*sql.DB {
+    myRegistryMu.RLock()
+    defer myRegistryMu.RUnlock()
+    result := make(map[string]*sql.DB, len(myRegistry))
+    for name, db := range myRegistry {
+        result[name] = db
+    }
+    return result
+}
@@ -0,0 +1,322 @@
+//////////////////////////////////////////////////////////////////////
+// retry.go
+//
+// @usage
+//
+//     1. Import this package.
+//
+//         --------------------------------------------------
+//         import myMySQL "mysql"
+//         --------------------------------------------------
+//
+//     2. Mark the context of an idempotent read and run it with a policy.
+//
+//         --------------------------------------------------
+//         ctx = myMySQL.Idempotent(ctx)
+//         rows, err := myMySQL.DefaultRetryPolicy.QueryContext(ctx, myMySQL.Conn(), "SELECT * FROM countries WHERE continent = ?", 2)
+//         --------------------------------------------------
+//
+//     3. Or retry a function, e.g. QueryRow and Scan.
+//
+//         --------------------------------------------------
+//         policy := &myMySQL.RetryPolicy{MaxAttempts: 5, InitialBackoff: 100 * time.Millisecond}
+//         err := policy.Do(myMySQL.Idempotent(ctx), func(ctx context.Context) error {
+//             return myMySQL.Conn().QueryRowContext(ctx, query, args...).Scan(&name)
+//         })
+//         --------------------------------------------------
+//
+//     Only the transient errors (IsTransientError) are retried, with an
+//     exponential backoff which stops at the deadline of the context.
+//     Without Idempotent() nothing is retried. QueryContext() never retries
+//     a statement which is not a read, and the errors while iterating the
+//     rows are not retried.
+//
+//
+// MIT License
+//
+// Copyright (c) 2019 noknow.info
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+// PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+// HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+// OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTW//ARE.
+//////////////////////////////////////////////////////////////////////
+package mysql
+
+import (
+    "context"
+    "database/sql"
+    "database/sql/driver"
+    "errors"
+    "math/rand"
+    "strings"
+    "syscall"
+    "time"
+    gomysql "github.com/go-sql-driver/mysql"
+)
+
+const (
+    DEFAULT_MAX_ATTEMPTS = 3
+    DEFAULT_INITIAL_BACKOFF = 50 * time.Millisecond
+    DEFAULT_MAX_BACKOFF = time.Second
+)
+
+var (
+    DefaultRetryPolicy = &RetryPolicy{}
+)
+
+type RetryPolicy struct {
+    // Including the first attempt. Defaults to DEFAULT_MAX_ATTEMPTS.
+    MaxAttempts int
+    // Defaults to DEFAULT_INITIAL_BACKOFF. It doubles after each attempt.
+    InitialBackoff time.Duration
+    // Defaults to DEFAULT_MAX_BACKOFF.
+    MaxBackoff time.Duration
+    // Defaults to IsTransientError.
+    IsRetryable func(err error) bool
+}
+
+type idempotentKey struct{}
+
+
+//////////////////////////////////////////////////////////////////////
+// Mark the statements run with the context as idempotent.
+//////////////////////////////////////////////////////////////////////
+func Idempotent(ctx context.Context) context.Context {
+    return context.WithValue(ctx, idempotentKey{}, true)
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Check whether the context was marked by Idempotent().
+//////////////////////////////////////////////////////////////////////
+func IsIdempotent(ctx context.Context) bool {
+    idempotent, _ := ctx.Value(idempotentKey{}).(bool)
+    return idempotent
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Run the read query, and retry it on a transient error when the
+// context is idempotent.
+//////////////////////////////////////////////////////////////////////
+func (p *RetryPolicy) QueryContext(ctx context.Context, q Queryer, query string, args ...interface{}) (*sql.Rows, error) {
+    if !isReadQuery(query) {
+        return q.QueryContext(ctx, query, args...)
+    }
+    var rows *sql.Rows
+    err := p.Do(ctx, func(ctx context.Context) error {
+        var err error
+        rows, err = q.QueryContext(ctx, query, args...)
+        return err
+    })
+    return rows, err
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Run fn, and retry it on a transient error when the context is
+// idempotent. The last error is returned.
+//////////////////////////////////////////////////////////////////////
+func (p *RetryPolicy) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+    maxAttempts := p.MaxAttempts
+    if maxAttempts <= 0 {
+        maxAttempts = DEFAULT_MAX_ATTEMPTS
+    }
+    if !IsIdempotent(ctx) {
+        maxAttempts = 1
+    }
+    isRetryable := p.IsRetryable
+    if isRetryable == nil {
+        isRetryable = IsTransientError
+    }
+    backoff := p.InitialBackoff
+    if backoff <= 0 {
+        backoff = DEFAULT_INITIAL_BACKOFF
+    }
+    maxBackoff := p.MaxBackoff
+    if maxBackoff <= 0 {
+        maxBackoff = DEFAULT_MAX_BACKOFF
+    }
+
+    var err error
+    for attempt := 1; ; attempt++ {
+        if err = fn(ctx); err == nil || attempt >= maxAttempts || !isRetryable(err) {
+            return err
+        }
+        // Half of the backoff is random so that the clients do not retry together.
+        wait := backoff / 2 + time.Duration(rand.Int63n(int64(backoff / 2) + 1))
+        if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+            return err
+        }
+        timer := time.NewTimer(wait)
+        select {
+        case <-ctx.Done():
+            timer.Stop()
+            return err
+        case <-timer.C:
+        }
+        if backoff *= 2; backoff > maxBackoff {
+            backoff = maxBackoff
+        }
+    }
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Check whether the error is worth retrying on another connection:
+// a broken connection, server has gone away / lost connection
+// (2006 / 2013), or a server which became read-only on a failover.
+//////////////////////////////////////////////////////////////////////
+func IsTransientError(err error) bool {
+    if err == nil || errors.Is(err, ErrCircuitOpen) {
+        return false
+    }
+    if errors.Is(err, driver.ErrBadConn) || errors.Is(err, gomysql.ErrInvalidConn) ||
+            errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+            errors.Is(err, syscall.EPIPE) {
+        return true
+    }
+    var mysqlErr *gomysql.MySQLError
+    if errors.As(err, &mysqlErr) {
+        switch mysqlErr.Number {
+        // CR_SERVER_GONE_ERROR, CR_SERVER_LOST, ER_SERVER_SHUTDOWN,
+        // ER_OPTION_PREVENTS_STATEMENT (--read-only), ER_READ_ONLY_MODE, ER_SERVER_OFFLINE_MODE
+        case 2006, 2013, 1053, 1290, 1836, 3032:
+            return true
+        }
+    }
+    return false
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Check whether the query only reads, so that it can run again. The
+// statement after WITH (common table expressions) and EXPLAIN ANALYZE
+// is checked, and locking reads (FOR UPDATE, FOR SHARE, LOCK IN SHARE
+// MODE) and SELECT ... INTO OUTFILE are not reads.
+//////////////////////////////////////////////////////////////////////
+func isReadQuery(query string) bool {
+    words := queryWords(query)
+    for i := range words {
+        switch phrase(words, i, 2) {
+        case "FOR UPDATE", "FOR SHARE", "INTO OUTFILE", "INTO DUMPFILE":
+            return false
+        }
+        if phrase(words, i, 4) == "LOCK IN SHARE MODE" {
+            return false
+        }
+    }
+    verb := ""
+    for i := 0; i < len(words); i++ {
+        if words[i].depth > 0 {
+            continue
+        }
+        switch words[i].text {
+        case "WITH", "RECURSIVE":
+            // The names of the common table expressions follow, and their
+            // queries are in parentheses.
+            for i + 1 < len(words) && (words[i + 1].depth > 0 || !isStatementVerb(words[i + 1].text)) {
+                i++
+            }
+            continue
+        case "EXPLAIN", "DESCRIBE", "DESC":
+            if i + 1 < len(words) && words[i + 1].text == "ANALYZE" {
+                // Runs the statement.
+                i++
+                continue
+            }
+        }
+        verb = words[i].text
+        break
+    }
+    switch verb {
+    case "SELECT", "SHOW", "DESCRIBE", "DESC", "EXPLAIN", "TABLE", "VALUES":
+        return true
+    }
+    return false
+}
+
+func isStatementVerb(word string) bool {
+    switch word {
+    case "SELECT", "TABLE", "VALUES", "INSERT", "REPLACE", "UPDATE", "DELETE":
+        return true
+    }
+    return false
+}
+
+type queryWord struct {
+    // Upper case. Quoted identifiers keep their backticks.
+    text string
+    // Depth of the parentheses.
+    depth int
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Split the query into words without the literals and the comments.
+//////////////////////////////////////////////////////////////////////
+func queryWords(query string) []queryWord {
+    query = SanitizeQuery(query)
+    var words []queryWord
+    depth := 0
+    for i := 0; i < len(query); i++ {
+        c := query[i]
+        switch {
+        case c == '(':
+            depth++
+        case c == ')':
+            depth--
+        case c == '/' && i + 1 < len(query) && query[i + 1] == '*':
+            end := strings.Index(query[i + 2:], "*/")
+            if end < 0 {
+                return words
+            }
+            i += end + 3
+        case c == '#' || c == '-' && strings.HasPrefix(query[i:], "-- "):
+            end := strings.IndexByte(query[i:], '\n')
+            if end < 0 {
+                return words
+            }
+            i += end
+        case c == '`':
+            end := strings.IndexByte(query[i + 1:], '`')
+            if end < 0 {
+                return words
+            }
+            words = append(words, queryWord{text: query[i:i + end + 2], depth: depth})
+            i += end + 1
+        case isIdentifierByte(c):
+            start := i
+            for i + 1 < len(query) && isIdentifierByte(query[i + 1]) {
+                i++
+            }
+            words = append(words, queryWord{text: strings.ToUpper(query[start:i + 1]), depth: depth})
+        }
+    }
+    return words
+}
+
+// The n words from i joined with a space, or "".
+func phrase(words []queryWord, i, n int) string {
+    if i + n > len(words) {
+        return ""
+    }
+    texts := make([]string, n)
+    for j := range texts {
+        texts[j] = words[i + j].text
+    }
+    return strings.Join(texts, " ")
+}
@@ -0,0 +1,98 @@
+package mysql
+
+import (
+    "context"
+    "database/sql/driver"
+    "errors"
+    "testing"
+    "time"
+    gomysql "github.com/go-sql-driver/mysql"
+)
+
+func TestIsReadQuery(t *testing.T) {
+    tests := []struct {
+        query string
+        want bool
+    }{
+        {"SELECT * FROM countries", true},
+        {"/* app */ select 1", true},
+        {"SHOW TABLES", true},
+        {"EXPLAIN SELECT * FROM t", true},
+        {"TABLE countries", true},
+        {"WITH a AS (SELECT 1) SELECT * FROM a", true},
+        {"WITH RECURSIVE a (n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM a WHERE n < 5), b AS (SELECT 2) SELECT * FROM a, b", true},
+        {"SELECT * FROM t WHERE note = 'for update'", true},
+        {"SELECT `for`, `update` FROM t", true},
+        {"INSERT INTO t VALUES (1)", false},
+        {"UPDATE t SET a = 1", false},
+        {"WITH a AS (SELECT id FROM t) DELETE FROM t WHERE id IN (SELECT id FROM a)", false},
+        {"WITH a AS (SELECT 1 AS id) UPDATE t, a SET t.x = 1 WHERE t.id = a.id", false},
+        {"SELECT * FROM t WHERE id = 1 FOR UPDATE", false},
+        {"SELECT * FROM t WHERE id = 1 FOR SHARE", false},
+        {"SELECT * FROM t WHERE id = 1 LOCK IN SHARE MODE", false},
+        {"SELECT * FROM (SELECT * FROM t FOR UPDATE) x", false},
+        {"SELECT * FROM t INTO OUTFILE '/tmp/t.csv'", false},
+        {"EXPLAIN ANALYZE SELECT * FROM t", true},
+        {"EXPLAIN ANALYZE DELETE FROM t", false},
+        {"CALL proc()", false},
+        {"", false},
+    }
+    for _, tt := range tests {
+        if got := isReadQuery(tt.query); got != tt.want {
+            t.Errorf("isReadQuery(%q) = %v, want %v", tt.query, got, tt.want)
+        }
+    }
+}
+
+func TestRetryPolicyDo(t *testing.T) {
+    policy := &RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+    duplicate := &gomysql.MySQLError{Number: 1062}
+    tests := []struct {
+        name string
+        ctx context.Context
+        errs []error
+        attempts int
+        err error
+    }{
+        {"success", Idempotent(context.Background()), []error{nil}, 1, nil},
+        {"transient then success", Idempotent(context.Background()), []error{driver.ErrBadConn, nil}, 2, nil},
+        {"max attempts", Idempotent(context.Background()), []error{driver.ErrBadConn, driver.ErrBadConn, driver.ErrBadConn, nil}, 3, driver.ErrBadConn},
+        {"not idempotent", context.Background(), []error{driver.ErrBadConn, nil}, 1, driver.ErrBadConn},
+        {"not transient", Idempotent(context.Background()), []error{duplicate, nil}, 1, duplicate},
+        {"circuit open", Idempotent(context.Background()), []error{&CircuitOpenError{Name: "test"}, nil}, 1, ErrCircuitOpen},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            attempts := 0
+            err := policy.Do(tt.ctx, func(ctx context.Context) error {
+                attempts++
+                return tt.errs[attempts - 1]
+            })
+            if attempts != tt.attempts {
+                t.Errorf("attempts = %d, want %d", attempts, tt.attempts)
+            }
+            if !errors.Is(err, tt.err) {
+                t.Errorf("Do() = %v, want %v", err, tt.err)
+            }
+        })
+    }
+}
+
+func TestRetryPolicyDeadline(t *testing.T) {
+    policy := &RetryPolicy{MaxAttempts: 10, InitialBackoff: time.Second}
+    ctx, cancel := context.WithTimeout(Idempotent(context.Background()), 100 * time.Millisecond)
+    defer cancel()
+    attempts := 0
+    start := time.Now()
+    err := policy.Do(ctx, func(ctx context.Context) error {
+        attempts++
+        return driver.ErrBadConn
+    })
+    if err != driver.ErrBadConn || attempts != 1 {
+        t.Errorf("Do() = %v after %d attempts, want %v after 1", err, attempts, driver.ErrBadConn)
+    }
+    // The backoff does not fit in the deadline.
+    if elapsed := time.Since(start); elapsed > 50 * time.Millisecond {
+        t.Errorf("Do() waited %s", elapsed)
+    }
+}
@@ -0,0 +1,198 @@
+//////////////////////////////////////////////////////////////////////
+// schema.go
+//
+// @usage
+//
+//     1. Import this package.
+//
+//         --------------------------------------------------
+//         import myMySQL "mysql"
+//         --------------------------------------------------
+//
+//     2. Inspect the keys of the current database.
+//
+//         --------------------------------------------------
+//         primaryKeys, err := myMySQL.PrimaryKeys(ctx, db)
+//         foreignKeys, err := myMySQL.ForeignKeys(ctx, db)
+//         --------------------------------------------------
+//
+//
+// MIT License
+//
+// Copyright (c) 2019 noknow.info
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+// PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+// HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+// OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTW//ARE.
+//////////////////////////////////////////////////////////////////////
+package mysql
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+    "math"
+    "strconv"
+    "strings"
+)
+
+// Implemented by *sql.DB, *sql.Conn and *sql.Tx.
+type Queryer interface {
+    QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+type ColumnType struct {
+    // Lower case, e.g. "varchar".
+    DataType string
+    Unsigned bool
+    // Maximum number of characters (bytes for the binary types). 0 for
+    // the other types.
+    Length int
+    // Digits of the numeric types.
+    Precision int
+    Scale int
+}
+
+type ForeignKey struct {
+    Name string
+    Table string
+    Columns []string
+    ReferencedTable string
+    ReferencedColumns []string
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get the primary key columns of all tables in the current database.
+//////////////////////////////////////////////////////////////////////
+func PrimaryKeys(ctx context.Context, q Queryer) (map[string][]string, error) {
+    rows, err := q.QueryContext(ctx, "SELECT TABLE_NAME, COLUMN_NAME" +
+            " FROM information_schema.KEY_COLUMN_USAGE" +
+            " WHERE TABLE_SCHEMA = DATABASE() AND CONSTRAINT_NAME = 'PRIMARY'" +
+            " ORDER BY TABLE_NAME, ORDINAL_POSITION")
+    if err != nil {
+        return nil, fmt.Errorf("QueryContext() error: %w", err)
+    }
+    defer rows.Close()
+    result := make(map[string][]string)
+    for rows.Next() {
+        var table, column string
+        if err := rows.Scan(&table, &column); err != nil {
+            return nil, fmt.Errorf("rows.Scan() error: %w", err)
+        }
+        result[table] = append(result[table], column)
+    }
+    return result, rows.Err()
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get all foreign keys in the current database.
+//////////////////////////////////////////////////////////////////////
+func ForeignKeys(ctx context.Context, q Queryer) ([]ForeignKey, error) {
+    rows, err := q.QueryContext(ctx, "SELECT CONSTRAINT_NAME, TABLE_NAME, COLUMN_NAME, REFERENCED_TABLE_NAME, REFERENCED_COLUMN_NAME" +
+            " FROM information_schema.KEY_COLUMN_USAGE" +
+            " WHERE TABLE_SCHEMA = DATABASE() AND REFERENCED_TABLE_NAME IS NOT NULL" +
+            " AND REFERENCED_TABLE_SCHEMA = DATABASE()" +
+            " ORDER BY TABLE_NAME, CONSTRAINT_NAME, ORDINAL_POSITION")
+    if err != nil {
+        return nil, fmt.Errorf("QueryContext() error: %w", err)
+    }
+    defer rows.Close()
+    var result []ForeignKey
+    for rows.Next() {
+        var name, table, column, referencedTable, referencedColumn string
+        if err := rows.Scan(&name, &table, &column, &referencedTable, &referencedColumn); err != nil {
+            return nil, fmt.Errorf("rows.Scan() error: %w", err)
+        }
+        last := len(result) - 1
+        if last < 0 || result[last].Name != name || result[last].Table != table {
+            result = append(result, ForeignKey{
+                Name: name,
+                Table: table,
+                ReferencedTable: referencedTable,
+            })
+            last++
+        }
+        result[last].Columns = append(result[last].Columns, column)
+        result[last].ReferencedColumns = append(result[last].ReferencedColumns, referencedColumn)
+    }
+    return result, rows.Err()
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get the types of the columns of all tables in the current database.
+//////////////////////////////////////////////////////////////////////
+func ColumnTypes(ctx context.Context, q Queryer) (map[string]map[string]ColumnType, error) {
+    rows, err := q.QueryContext(ctx, "SELECT TABLE_NAME, COLUMN_NAME, DATA_TYPE, COLUMN_TYPE," +
+            " CHARACTER_MAXIMUM_LENGTH, NUMERIC_PRECISION, NUMERIC_SCALE" +
+            " FROM information_schema.COLUMNS" +
+            " WHERE TABLE_SCHEMA = DATABASE()")
+    if err != nil {
+        return nil, fmt.Errorf("QueryContext() error: %w", err)
+    }
+    defer rows.Close()
+    result := make(map[string]map[string]ColumnType)
+    for rows.Next() {
+        var table, column, dataType, columnType string
+        var length, precision, scale sql.NullInt64
+        if err := rows.Scan(&table, &column, &dataType, &columnType, &length, &precision, &scale); err != nil {
+            return nil, fmt.Errorf("rows.Scan() error: %w", err)
+        }
+        if result[table] == nil {
+            result[table] = make(map[string]ColumnType)
+        }
+        result[table][column] = ColumnType{
+            DataType: strings.ToLower(dataType),
+            Unsigned: strings.Contains(strings.ToLower(columnType), "unsigned"),
+            Length: int(length.Int64),
+            Precision: int(precision.Int64),
+            Scale: int(scale.Int64),
+        }
+    }
+    return result, rows.Err()
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get the number of digits which every positive integer of the type
+// can have, or 0 when the type is not bounded that way.
+//////////////////////////////////////////////////////////////////////
+func (t ColumnType) IntegerDigits() int {
+    var max uint64
+    switch t.DataType {
+    case "tinyint":
+        max = math.MaxInt8
+    case "smallint":
+        max = math.MaxInt16
+    case "mediumint":
+        max = 1 << 23 - 1
+    case "int", "integer":
+        max = math.MaxInt32
+    case "bigint":
+        max = math.MaxInt64
+    case "decimal", "numeric":
+        return t.Precision - t.Scale
+    default:
+        return 0
+    }
+    if t.Unsigned {
+        max = max * 2 + 1
+    }
+    // The maximum is not all nines, so one digit less always fits.
+    return len(strconv.FormatUint(max, 10)) - 1
+}
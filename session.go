@@ -0,0 +1,160 @@
+//////////////////////////////////////////////////////////////////////
+// session.go
+//
+// @usage
+//
+//     1. Import this package.
+//
+//         --------------------------------------------------
+//         import myMySQL "mysql"
+//         --------------------------------------------------
+//
+//     2. Register the session initialization before Init().
+//
+//         --------------------------------------------------
+//         myMySQL.Use(myMySQL.SessionInit(&myMySQL.SessionOptions{
+//             Statements: []string{
+//                 "SET time_zone = '+00:00'",
+//                 "SET SESSION sql_mode = 'STRICT_ALL_TABLES,NO_ZERO_DATE'",
+//                 "SET SESSION transaction_isolation = 'READ-COMMITTED'",
+//                 "SET NAMES utf8mb4 COLLATE utf8mb4_0900_ai_ci",
+//             },
+//         }))
+//         myMySQL.Init(datasourceName)
+//         --------------------------------------------------
+//
+//     3. Or initialize the session in Go.
+//
+//         --------------------------------------------------
+//         myMySQL.Use(myMySQL.SessionInit(&myMySQL.SessionOptions{
+//             Func: func(ctx context.Context, session *myMySQL.Session) error {
+//                 return session.Exec(ctx, "SET @@session.time_zone = ?", zone)
+//             },
+//         }))
+//         --------------------------------------------------
+//
+//     The statements run on each new connection, before it is used.
+//     When one of them fails, the connection is closed and the error is
+//     returned to the caller instead of a connection in an unknown state.
+//
+//
+// MIT License
+//
+// Copyright (c) 2019 noknow.info
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+// PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+// HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+// OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTW//ARE.
+//////////////////////////////////////////////////////////////////////
+package mysql
+
+import (
+    "context"
+    "database/sql/driver"
+    "fmt"
+)
+
+type SessionOptions struct {
+    // Run in order on each new connection.
+    Statements []string
+    // Called after the statements.
+    Func func(ctx context.Context, session *Session) error
+}
+
+// A new connection being initialized.
+type Session struct {
+    conn driver.Conn
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get an interceptor which initializes each new connection.
+//////////////////////////////////////////////////////////////////////
+func SessionInit(opts *SessionOptions) Interceptor {
+    o := SessionOptions{}
+    if opts != nil {
+        o = *opts
+        o.Statements = append([]string{}, opts.Statements...)
+    }
+    return func(ctx context.Context, call *Call, next Handler) error {
+        if err := next(ctx, call); err != nil || call.Op != OP_CONNECT {
+            return err
+        }
+        session := &Session{conn: call.Conn}
+        err := o.run(ctx, session)
+        if err != nil {
+            call.Conn.Close()
+            call.Conn = nil
+        }
+        return err
+    }
+}
+
+func (o *SessionOptions) run(ctx context.Context, session *Session) error {
+    for _, query := range o.Statements {
+        if err := session.Exec(ctx, query); err != nil {
+            return fmt.Errorf("session init %q error: %w", query, err)
+        }
+    }
+    if o.Func != nil {
+        if err := o.Func(ctx, session); err != nil {
+            return fmt.Errorf("session init error: %w", err)
+        }
+    }
+    return nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Run a statement on the connection.
+//////////////////////////////////////////////////////////////////////
+func (s *Session) Exec(ctx context.Context, query string, args ...interface{}) error {
+    named := make([]driver.NamedValue, len(args))
+    for i, arg := range args {
+        value, err := driver.DefaultParameterConverter.ConvertValue(arg)
+        if err != nil {
+            return fmt.Errorf("argument %d error: %w", i + 1, err)
+        }
+        named[i] = driver.NamedValue{Ordinal: i + 1, Value: value}
+    }
+    if e, ok := s.conn.(driver.ExecerContext); ok {
+        _, err := e.ExecContext(ctx, query, named)
+        if err != driver.ErrSkip {
+            return err
+        }
+    }
+    var stmt driver.Stmt
+    var err error
+    if p, ok := s.conn.(driver.ConnPrepareContext); ok {
+        stmt, err = p.PrepareContext(ctx, query)
+    } else {
+        stmt, err = s.conn.Prepare(query)
+    }
+    if err != nil {
+        return err
+    }
+    defer stmt.Close()
+    _, err = stmtExec(ctx, stmt, named)
+    return err
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get the driver connection, e.g. for queries.
+//////////////////////////////////////////////////////////////////////
+func (s *Session) Conn() driver.Conn {
+    return s.conn
+}
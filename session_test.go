@@ -0,0 +1,73 @@
+package mysql_test
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "testing"
+    myMySQL "mysql"
+    "mysql/mysqltest"
+)
+
+func openSessionDBT(t testing.TB, mock *mysqltest.Mock) *sql.DB {
+    t.Helper()
+    db, _, err := myMySQL.OpenDB(mysqltest.DRIVER_NAME, mock.DSN(), myMySQL.SessionInit(&myMySQL.SessionOptions{
+        Statements: []string{
+            "SET time_zone = '+00:00'",
+            "SET NAMES utf8mb4",
+        },
+        Func: func(ctx context.Context, session *myMySQL.Session) error {
+            return session.Exec(ctx, "SET @app = ?", "test")
+        },
+    }))
+    if err != nil {
+        t.Fatalf("mysql.OpenDB() error: %s", err)
+    }
+    t.Cleanup(func() {
+        db.Close()
+    })
+    return db
+}
+
+func TestSessionInit(t *testing.T) {
+    _, mock := mysqltest.NewT(t)
+    for i := 0; i < 2; i++ {
+        mock.ExpectExec("SET time_zone = '+00:00'")
+        mock.ExpectExec("SET NAMES utf8mb4")
+        mock.ExpectExec("SET @app = ?").WithArgs("test")
+        mock.ExpectExec("DELETE FROM sessions")
+    }
+    db := openSessionDBT(t, mock)
+    // Every call connects.
+    db.SetMaxIdleConns(0)
+    for i := 0; i < 2; i++ {
+        if _, err := db.Exec("DELETE FROM sessions"); err != nil {
+            t.Fatalf("db.Exec() error: %s", err)
+        }
+    }
+}
+
+func TestSessionInitFailure(t *testing.T) {
+    errCollation := errors.New("unknown collation")
+    _, mock := mysqltest.NewT(t)
+    mock.ExpectExec("SET time_zone = '+00:00'")
+    mock.ExpectExec("SET NAMES utf8mb4").WillReturnError(errCollation)
+    db := openSessionDBT(t, mock)
+
+    // The statement is not expected: it must not run on the rejected connection.
+    _, err := db.Exec("DELETE FROM sessions")
+    if !errors.Is(err, errCollation) {
+        t.Fatalf("db.Exec() error = %v, want %v", err, errCollation)
+    }
+    if open := db.Stats().OpenConnections; open != 0 {
+        t.Errorf("OpenConnections = %d after the failed init, want 0", open)
+    }
+
+    mock.ExpectExec("SET time_zone = '+00:00'")
+    mock.ExpectExec("SET NAMES utf8mb4")
+    mock.ExpectExec("SET @app = ?").WithArgs("test")
+    mock.ExpectExec("DELETE FROM sessions")
+    if _, err := db.Exec("DELETE FROM sessions"); err != nil {
+        t.Errorf("db.Exec() on a new connection error: %s", err)
+    }
+}
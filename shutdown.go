@@ -0,0 +1,307 @@
+//////////////////////////////////////////////////////////////////////
+// shutdown.go
+//
+// @usage
+//
+//     1. Import this package.
+//
+//         --------------------------------------------------
+//         import myMySQL "mysql"
+//         --------------------------------------------------
+//
+//     2. Shut down the connection of Init() and the other registered
+//        connections instead of Close(), e.g. on SIGTERM after the HTTP
+//        server stopped.
+//
+//         --------------------------------------------------
+//         ctx, cancel := context.WithTimeout(context.Background(), 20 * time.Second)
+//         defer cancel()
+//         report, err := myMySQL.Shutdown(ctx)
+//         for _, aborted := range report.Aborted {
+//             log.Printf("[WARN] aborted on shutdown: %s\n", aborted)
+//         }
+//         --------------------------------------------------
+//
+//     New queries and transactions fail with "sql: database is closed"
+//     at once. The running ones, the open transactions and the open rows
+//     may go on until the deadline of the context. Then the running
+//     queries are killed by KILL QUERY on another connection, and the
+//     connections still in use are reported with an error. A query is
+//     running until its rows are closed, so a slow reader is killed too.
+//
+//     All the registered connections are closed and waited for, but only
+//     the connections of Init() are tracked, so the queries of the others
+//     are neither killed nor reported in ShutdownReport.Aborted.
+//
+//
+// MIT License
+//
+// Copyright (c) 2019 noknow.info
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+// PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+// HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+// OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTW//ARE.
+//////////////////////////////////////////////////////////////////////
+package mysql
+
+import (
+    "context"
+    "database/sql"
+    "database/sql/driver"
+    "fmt"
+    "log"
+    "sort"
+    "sync"
+    "time"
+)
+
+const (
+    SHUTDOWN_POLL_INTERVAL = 10 * time.Millisecond
+    KILL_TIMEOUT = 5 * time.Second
+)
+
+type ShutdownReport struct {
+    // Connections still in use at the deadline.
+    Aborted []AbortedConn
+    // Time spent waiting for the connections.
+    Duration time.Duration
+}
+
+type AbortedConn struct {
+    // Server side connection id. 0 when unknown.
+    ConnectionID int64
+    // Running query, or the query of the open rows. Empty when the
+    // connection was idle in a transaction.
+    Query string
+    // How long the query had been running, including reading the rows.
+    Running time.Duration
+    InTransaction bool
+    // true when KILL QUERY succeeded.
+    Killed bool
+    // Error of KILL QUERY.
+    Err error
+}
+
+// Connections of a connector, for Shutdown().
+type connTracker struct {
+    mu sync.Mutex
+    conns map[*interceptedConn]struct{}
+}
+
+type connState struct {
+    id int64
+    mu sync.Mutex
+    query string
+    since time.Time
+    inTx bool
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Close the connection of Init() and the registered connections, and
+// wait until the connections in use are released or the context is
+// done. Then kill the running queries and report what was aborted.
+//////////////////////////////////////////////////////////////////////
+func Shutdown(ctx context.Context) (*ShutdownReport, error) {
+    report := &ShutdownReport{}
+    myInterceptorsMu.Lock()
+    connector := myConnector
+    myInterceptorsMu.Unlock()
+    dbs := []*sql.DB{}
+    if myDb != nil {
+        dbs = append(dbs, myDb)
+    }
+    for name, db := range Registered() {
+        Unregister(name)
+        if db != myDb {
+            dbs = append(dbs, db)
+        }
+    }
+    start := time.Now()
+    // The idle connections are closed, and the others when they are released.
+    for _, db := range dbs {
+        db.Close()
+    }
+
+    ticker := time.NewTicker(SHUTDOWN_POLL_INTERVAL)
+    defer ticker.Stop()
+    for inUse(dbs) > 0 {
+        select {
+        case <-ctx.Done():
+            report.Duration = time.Since(start)
+            n := inUse(dbs)
+            if myDb != nil && connector != nil {
+                report.Aborted = connector.tracker.abort(ctx, connector.base)
+            }
+            return report, fmt.Errorf("%d connections in use: %w", n, ctx.Err())
+        case <-ticker.C:
+        }
+    }
+    report.Duration = time.Since(start)
+    return report, nil
+}
+
+func inUse(dbs []*sql.DB) int {
+    n := 0
+    for _, db := range dbs {
+        n += db.Stats().InUse
+    }
+    return n
+}
+
+
+func newConnTracker() *connTracker {
+    return &connTracker{conns: map[*interceptedConn]struct{}{}}
+}
+
+func (t *connTracker) add(ctx context.Context, ic *interceptedConn) {
+    id, err := connectionID(ctx, ic.conn)
+    if err != nil {
+        log.Printf("[WARN] SELECT CONNECTION_ID() error: %s\n", err)
+    }
+    ic.state = &connState{id: id}
+    t.mu.Lock()
+    t.conns[ic] = struct{}{}
+    t.mu.Unlock()
+}
+
+func (t *connTracker) remove(ic *interceptedConn) {
+    t.mu.Lock()
+    delete(t.conns, ic)
+    t.mu.Unlock()
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Kill the running queries of the remaining connections, and report
+// the connections.
+//////////////////////////////////////////////////////////////////////
+func (t *connTracker) abort(ctx context.Context, base driver.Connector) []AbortedConn {
+    t.mu.Lock()
+    conns := make([]*interceptedConn, 0, len(t.conns))
+    for ic := range t.conns {
+        conns = append(conns, ic)
+    }
+    t.mu.Unlock()
+
+    ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), KILL_TIMEOUT)
+    defer cancel()
+    aborted := []AbortedConn{}
+    for _, ic := range conns {
+        ic.state.mu.Lock()
+        a := AbortedConn{
+            ConnectionID: ic.state.id,
+            Query: ic.state.query,
+            InTransaction: ic.state.inTx,
+        }
+        if a.Query != "" {
+            a.Running = time.Since(ic.state.since)
+        }
+        ic.state.mu.Unlock()
+        if a.Query != "" {
+            // The other connections of a failover connector are on another host.
+            connector := base
+            if h, ok := ic.conn.(interface{ hostConnector() driver.Connector }); ok {
+                connector = h.hostConnector()
+            }
+            a.Err = killQuery(ctx, connector, a.ConnectionID)
+            a.Killed = a.Err == nil
+        }
+        aborted = append(aborted, a)
+    }
+    sort.Slice(aborted, func(i, j int) bool {
+        return aborted[i].ConnectionID < aborted[j].ConnectionID
+    })
+    return aborted
+}
+
+func killQuery(ctx context.Context, connector driver.Connector, id int64) error {
+    if id == 0 {
+        return fmt.Errorf("unknown connection id")
+    }
+    conn, err := connector.Connect(ctx)
+    if err != nil {
+        return err
+    }
+    defer conn.Close()
+    return (&Session{conn: conn}).Exec(ctx, fmt.Sprintf("KILL QUERY %d", id))
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Record the call as running.
+//////////////////////////////////////////////////////////////////////
+func (s *connState) start(call *Call) {
+    s.mu.Lock()
+    s.query = call.Query
+    if s.query == "" {
+        s.query = call.Operation()
+    }
+    s.since = time.Now()
+    s.mu.Unlock()
+}
+
+func (s *connState) end(call *Call, err error) {
+    s.mu.Lock()
+    s.query = ""
+    switch call.Op {
+    case OP_BEGIN:
+        s.inTx = err == nil
+    case OP_COMMIT, OP_ROLLBACK:
+        s.inTx = false
+    }
+    s.mu.Unlock()
+}
+
+func connectionID(ctx context.Context, conn driver.Conn) (int64, error) {
+    q, ok := conn.(driver.QueryerContext)
+    if !ok {
+        return 0, fmt.Errorf("the connection does not support QueryContext")
+    }
+    rows, err := q.QueryContext(ctx, "SELECT CONNECTION_ID()", nil)
+    if err != nil {
+        return 0, err
+    }
+    defer rows.Close()
+    dest := make([]driver.Value, 1)
+    if err := rows.Next(dest); err != nil {
+        return 0, err
+    }
+    switch v := dest[0].(type) {
+    case int64:
+        return v, nil
+    case uint64:
+        return int64(v), nil
+    case []byte:
+        var id int64
+        _, err := fmt.Sscan(string(v), &id)
+        return id, err
+    }
+    return 0, fmt.Errorf("unexpected CONNECTION_ID(): %v", dest[0])
+}
+
+
+func (a AbortedConn) String() string {
+    switch {
+    case a.Query == "":
+        return fmt.Sprintf("connection %d: idle, in transaction: %t", a.ConnectionID, a.InTransaction)
+    case a.Err != nil:
+        return fmt.Sprintf("connection %d: %s (%s), in transaction: %t, KILL QUERY error: %s",
+            a.ConnectionID, a.Query, a.Running.Round(time.Millisecond), a.InTransaction, a.Err)
+    }
+    return fmt.Sprintf("connection %d: %s (%s), in transaction: %t, killed",
+        a.ConnectionID, a.Query, a.Running.Round(time.Millisecond), a.InTransaction)
+}
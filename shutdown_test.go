@@ -0,0 +1,66 @@
+package mysql
+
+import (
+    "context"
+    "errors"
+    "strings"
+    "testing"
+    "time"
+    "mysql/mysqltest/embedded"
+)
+
+func TestShutdownKillsOpenRows(t *testing.T) {
+    Init(embedded.NewDSN(t, nil))
+    rows, err := Conn().Query("SELECT 1 UNION ALL SELECT 2")
+    if err != nil {
+        t.Fatalf("Query() error: %s", err)
+    }
+    defer rows.Close()
+    if !rows.Next() {
+        t.Fatalf("Next() error: %v", rows.Err())
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 100 * time.Millisecond)
+    defer cancel()
+    report, err := Shutdown(ctx)
+    if !errors.Is(err, context.DeadlineExceeded) {
+        t.Fatalf("Shutdown() error = %v, want %v", err, context.DeadlineExceeded)
+    }
+    if len(report.Aborted) != 1 {
+        t.Fatalf("Aborted = %v, want 1 connection", report.Aborted)
+    }
+    if a := report.Aborted[0]; !strings.HasPrefix(a.Query, "SELECT 1") || !a.Killed {
+        t.Errorf("Aborted[0] = %s, want the killed SELECT", a)
+    }
+}
+
+func TestShutdownDrainsRegistered(t *testing.T) {
+    Init(embedded.NewDSN(t, nil))
+    other := embedded.NewDB(t, nil)
+    Register("other", other)
+    conn, err := other.Conn(context.Background())
+    if err != nil {
+        t.Fatalf("Conn() error: %s", err)
+    }
+    go func() {
+        time.Sleep(50 * time.Millisecond)
+        conn.Close()
+    }()
+
+    ctx, cancel := context.WithTimeout(context.Background(), 5 * time.Second)
+    defer cancel()
+    report, err := Shutdown(ctx)
+    if err != nil {
+        t.Fatalf("Shutdown() error: %s", err)
+    }
+    // Waited for the connection of the other database.
+    if report.Duration < 50 * time.Millisecond {
+        t.Errorf("Duration = %s, want at least 50ms", report.Duration)
+    }
+    if len(Registered()) != 0 {
+        t.Errorf("Registered() = %v, want none", Registered())
+    }
+    if err := other.Ping(); err == nil {
+        t.Error("the other database is not closed")
+    }
+}
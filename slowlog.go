@@ -0,0 +1,427 @@
+//////////////////////////////////////////////////////////////////////
+// slowlog.go
+//
+// @usage
+//
+//     1. Import this package.
+//
+//         --------------------------------------------------
+//         import myMySQL "mysql"
+//         --------------------------------------------------
+//
+//     2. Register the slow query log before Init().
+//
+//         --------------------------------------------------
+//         myMySQL.Use(myMySQL.SlowQueryLog(&myMySQL.SlowLogOptions{
+//             Threshold: 500 * time.Millisecond,
+//             Explain: replica,
+//         }))
+//         myMySQL.Init(datasourceName)
+//         --------------------------------------------------
+//
+//     A query or an exec slower than the threshold is logged with the
+//     types of the args only, e.g.
+//
+//         [WARN] slow query (1.2s): SELECT * FROM countries ORDER BY en LIMIT ? args=[int64] plan=full scan: countries; rows examined: 249; index: none
+//
+//     When SlowLogOptions.Explain is set, EXPLAIN FORMAT=JSON of the same
+//     statement runs on it in the background and the plan summary is added.
+//     At most ExplainConcurrency EXPLAINs run at a time and the others are
+//     dropped, and a statement (with the literals replaced) is explained
+//     at most once per ExplainInterval. Use NewSlowLog() to get the counts.
+//     Set SlowLogOptions.Handler to send the records somewhere else.
+//
+//
+// MIT License
+//
+// Copyright (c) 2019 noknow.info
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+// PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+// HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+// OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTW//ARE.
+//////////////////////////////////////////////////////////////////////
+package mysql
+
+import (
+    "context"
+    "database/sql"
+    "database/sql/driver"
+    "errors"
+    "fmt"
+    "log"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+const (
+    DEFAULT_SLOW_QUERY_THRESHOLD = time.Second
+    DEFAULT_EXPLAIN_TIMEOUT = 5 * time.Second
+    DEFAULT_EXPLAIN_CONCURRENCY = 2
+    DEFAULT_EXPLAIN_INTERVAL = time.Minute
+    // Statements remembered for ExplainInterval.
+    MAX_EXPLAINED_STATEMENTS = 1024
+)
+
+var (
+    ErrExplainDropped = errors.New("explain dropped: too many running")
+)
+
+type SlowLogOptions struct {
+    // Defaults to DEFAULT_SLOW_QUERY_THRESHOLD.
+    Threshold time.Duration
+    // Run EXPLAIN FORMAT=JSON on this database, e.g. a replica. nil disables it.
+    Explain *sql.DB
+    // Defaults to DEFAULT_EXPLAIN_TIMEOUT.
+    ExplainTimeout time.Duration
+    // Maximum number of running EXPLAINs. Defaults to DEFAULT_EXPLAIN_CONCURRENCY.
+    ExplainConcurrency int
+    // Minimum interval of EXPLAINs of a statement. Defaults to DEFAULT_EXPLAIN_INTERVAL.
+    ExplainInterval time.Duration
+    // Called for each slow query instead of logging it.
+    Handler func(q *SlowQuery)
+}
+
+type SlowQuery struct {
+    Query string
+    // Types of the args. The values are not kept.
+    Args []string
+    Duration time.Duration
+    Err error
+    // Set when the plan was captured.
+    Plan *PlanSummary
+    // Set when EXPLAIN failed.
+    ExplainErr error
+}
+
+type SlowLog struct {
+    o SlowLogOptions
+    interceptor Interceptor
+    running chan struct{}
+    mu sync.Mutex
+    // Sanitized statement -> time of the last EXPLAIN.
+    explained map[string]time.Time
+    slow atomic.Int64
+    explains atomic.Int64
+    dropped atomic.Int64
+    limited atomic.Int64
+}
+
+type SlowLogStats struct {
+    SlowQueries int64
+    Explains int64
+    // EXPLAINs dropped because ExplainConcurrency were running.
+    Dropped int64
+    // EXPLAINs skipped because the statement was explained in ExplainInterval.
+    RateLimited int64
+}
+
+type PlanSummary struct {
+    FullTableScans []string
+    RowsExamined int64
+    Indexes []string
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Create an interceptor which logs the slow queries.
+//////////////////////////////////////////////////////////////////////
+func SlowQueryLog(opts *SlowLogOptions) Interceptor {
+    return NewSlowLog(opts).Interceptor()
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Create a slow query log. Register its Interceptor() with Use().
+//////////////////////////////////////////////////////////////////////
+func NewSlowLog(opts *SlowLogOptions) *SlowLog {
+    s := &SlowLog{
+        explained: make(map[string]time.Time),
+    }
+    if opts != nil {
+        s.o = *opts
+    }
+    if s.o.Threshold <= 0 {
+        s.o.Threshold = DEFAULT_SLOW_QUERY_THRESHOLD
+    }
+    if s.o.ExplainTimeout <= 0 {
+        s.o.ExplainTimeout = DEFAULT_EXPLAIN_TIMEOUT
+    }
+    if s.o.ExplainConcurrency <= 0 {
+        s.o.ExplainConcurrency = DEFAULT_EXPLAIN_CONCURRENCY
+    }
+    if s.o.ExplainInterval <= 0 {
+        s.o.ExplainInterval = DEFAULT_EXPLAIN_INTERVAL
+    }
+    if s.o.Handler == nil {
+        s.o.Handler = func(q *SlowQuery) {
+            log.Printf("[WARN] %s\n", q)
+        }
+    }
+    s.running = make(chan struct{}, s.o.ExplainConcurrency)
+    s.interceptor = AfterHook(s.hook)
+    return s
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get the interceptor.
+//////////////////////////////////////////////////////////////////////
+func (s *SlowLog) Interceptor() Interceptor {
+    return s.interceptor
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get the counts since NewSlowLog().
+//////////////////////////////////////////////////////////////////////
+func (s *SlowLog) Stats() SlowLogStats {
+    return SlowLogStats{
+        SlowQueries: s.slow.Load(),
+        Explains: s.explains.Load(),
+        Dropped: s.dropped.Load(),
+        RateLimited: s.limited.Load(),
+    }
+}
+
+func (s *SlowLog) hook(ctx context.Context, call *Call, duration time.Duration, err error) {
+    if duration < s.o.Threshold || (call.Op != OP_QUERY && call.Op != OP_EXEC) {
+        return
+    }
+    s.slow.Add(1)
+    q := &SlowQuery{
+        Query: call.Query,
+        Args: redactArgs(call.Args),
+        Duration: duration,
+        Err: err,
+    }
+    if s.o.Explain == nil || !explainable(call) || !s.allowExplain(call.Query) {
+        s.o.Handler(q)
+        return
+    }
+    select {
+    case s.running <- struct{}{}:
+    default:
+        s.dropped.Add(1)
+        q.ExplainErr = ErrExplainDropped
+        s.o.Handler(q)
+        return
+    }
+    s.explains.Add(1)
+    args := make([]interface{}, len(call.Args))
+    for i, nv := range call.Args {
+        if nv.Name != "" {
+            args[i] = sql.Named(nv.Name, nv.Value)
+        } else {
+            args[i] = nv.Value
+        }
+    }
+    go func() {
+        defer func() {
+            <-s.running
+        }()
+        ctx, cancel := context.WithTimeout(context.Background(), s.o.ExplainTimeout)
+        defer cancel()
+        q.Plan, q.ExplainErr = explainSummary(ctx, s.o.Explain, call.Query, args...)
+        s.o.Handler(q)
+    }()
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Check and record the last EXPLAIN of the statement.
+//////////////////////////////////////////////////////////////////////
+func (s *SlowLog) allowExplain(query string) bool {
+    key := SanitizeQuery(query)
+    now := time.Now()
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if last, ok := s.explained[key]; ok && now.Sub(last) < s.o.ExplainInterval {
+        s.limited.Add(1)
+        return false
+    }
+    if len(s.explained) >= MAX_EXPLAINED_STATEMENTS {
+        for k, last := range s.explained {
+            if now.Sub(last) >= s.o.ExplainInterval {
+                delete(s.explained, k)
+            }
+        }
+        if len(s.explained) >= MAX_EXPLAINED_STATEMENTS {
+            s.limited.Add(1)
+            return false
+        }
+    }
+    s.explained[key] = now
+    return true
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Format the slow query for the log.
+//////////////////////////////////////////////////////////////////////
+func (q *SlowQuery) String() string {
+    var b strings.Builder
+    fmt.Fprintf(&b, "slow query (%s): %s args=[%s]", q.Duration, q.Query, strings.Join(q.Args, ", "))
+    if q.Err != nil {
+        fmt.Fprintf(&b, " error=%s", q.Err)
+    }
+    if q.Plan != nil {
+        fmt.Fprintf(&b, " plan=%s", q.Plan)
+    }
+    if q.ExplainErr != nil {
+        fmt.Fprintf(&b, " explain error=%s", q.ExplainErr)
+    }
+    return b.String()
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Format the plan summary.
+//////////////////////////////////////////////////////////////////////
+func (p *PlanSummary) String() string {
+    scans := "none"
+    if len(p.FullTableScans) > 0 {
+        scans = strings.Join(p.FullTableScans, ", ")
+    }
+    indexes := "none"
+    if len(p.Indexes) > 0 {
+        indexes = strings.Join(p.Indexes, ", ")
+    }
+    return fmt.Sprintf("full scan: %s; rows examined: %d; index: %s", scans, p.RowsExamined, indexes)
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Run EXPLAIN FORMAT=JSON and summarize the plan.
+//////////////////////////////////////////////////////////////////////
+func explainSummary(ctx context.Context, db *sql.DB, query string, args ...interface{}) (*PlanSummary, error) {
+    plan, err := Explain(ctx, db, query, args...)
+    if err != nil {
+        return nil, err
+    }
+    return plan.Summary(), nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Summarize the output of EXPLAIN FORMAT=JSON.
+//////////////////////////////////////////////////////////////////////
+func SummarizePlan(data []byte) (*PlanSummary, error) {
+    plan, err := ParsePlan(data)
+    if err != nil {
+        return nil, err
+    }
+    return plan.Summary(), nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Summarize the plan: full table scans, rows examined and indexes.
+//////////////////////////////////////////////////////////////////////
+func (p *Plan) Summary() *PlanSummary {
+    summary := &PlanSummary{}
+    for _, t := range p.Tables() {
+        if t.AccessType == "ALL" {
+            summary.FullTableScans = append(summary.FullTableScans, t.TableName)
+        }
+        summary.RowsExamined += t.RowsExaminedPerScan
+        if t.Key != "" {
+            summary.Indexes = append(summary.Indexes, t.TableName + "." + t.Key)
+        }
+    }
+    return summary
+}
+
+
+func explainable(call *Call) bool {
+    switch call.Operation() {
+    case "SELECT", "INSERT", "UPDATE", "DELETE", "REPLACE", "TABLE", "WITH":
+        return true
+    }
+    return false
+}
+
+//////////////////////////////////////////////////////////////////////
+// Replace the string and number literals in the query with "?", and
+// collapse the white spaces.
+//////////////////////////////////////////////////////////////////////
+func SanitizeQuery(query string) string {
+    var b strings.Builder
+    b.Grow(len(query))
+    space := false
+    for i := 0; i < len(query); i++ {
+        c := query[i]
+        if c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+            space = b.Len() > 0
+            continue
+        }
+        if space {
+            b.WriteByte(' ')
+            space = false
+        }
+        switch {
+        case c == '\'' || c == '"':
+            // Skip to the closing quote. Doubled quotes and backslash escapes stay inside.
+            i++
+            for ; i < len(query); i++ {
+                if query[i] == '\\' {
+                    i++
+                } else if query[i] == c {
+                    if i + 1 < len(query) && query[i + 1] == c {
+                        i++
+                    } else {
+                        break
+                    }
+                }
+            }
+            b.WriteByte('?')
+        case c == '`':
+            // Quoted identifier.
+            end := strings.IndexByte(query[i + 1:], '`')
+            if end < 0 {
+                b.WriteString(query[i:])
+                return b.String()
+            }
+            b.WriteString(query[i:i + end + 2])
+            i += end + 1
+        case c >= '0' && c <= '9' && (i == 0 || !isIdentifierByte(query[i - 1])):
+            for i + 1 < len(query) && (isIdentifierByte(query[i + 1]) || query[i + 1] == '.') {
+                i++
+            }
+            b.WriteByte('?')
+        default:
+            b.WriteByte(c)
+        }
+    }
+    return b.String()
+}
+
+func isIdentifierByte(c byte) bool {
+    return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+func redactArgs(args []driver.NamedValue) []string {
+    types := make([]string, len(args))
+    for i, nv := range args {
+        if nv.Value == nil {
+            types[i] = "NULL"
+        } else {
+            types[i] = fmt.Sprintf("%T", nv.Value)
+        }
+    }
+    return types
+}
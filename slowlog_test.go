@@ -0,0 +1,76 @@
+package mysql
+
+import (
+    "context"
+    "testing"
+    "time"
+    "mysql/mysqltest/embedded"
+)
+
+func TestSlowLogBoundsExplains(t *testing.T) {
+    explainDB := embedded.NewDB(t, nil)
+    explainDB.SetMaxOpenConns(1)
+    // Hold the only connection, so that the EXPLAINs wait.
+    conn, err := explainDB.Conn(context.Background())
+    if err != nil {
+        t.Fatalf("db.Conn() error: %s", err)
+    }
+
+    handled := make(chan *SlowQuery, 10)
+    s := NewSlowLog(&SlowLogOptions{
+        Explain: explainDB,
+        ExplainConcurrency: 1,
+        Handler: func(q *SlowQuery) {
+            handled <- q
+        },
+    })
+    slow := func(query string) {
+        s.hook(context.Background(), &Call{Op: OP_QUERY, Query: query}, 2 * time.Second, nil)
+    }
+
+    slow("SELECT * FROM a WHERE id = 1")
+    // Explained in the interval with other literals.
+    slow("SELECT *  FROM a WHERE id = 2")
+    if q := <-handled; q.Plan != nil || q.ExplainErr != nil {
+        t.Errorf("rate limited query = %s, want no plan", q)
+    }
+    // No worker is free.
+    slow("SELECT * FROM b")
+    if q := <-handled; q.ExplainErr != ErrExplainDropped {
+        t.Errorf("explain error = %v, want %v", q.ExplainErr, ErrExplainDropped)
+    }
+    // Not slow.
+    s.hook(context.Background(), &Call{Op: OP_QUERY, Query: "SELECT 1"}, time.Millisecond, nil)
+
+    conn.Close()
+    select {
+    case q := <-handled:
+        if q.Query != "SELECT * FROM a WHERE id = 1" {
+            t.Errorf("explained query = %q", q.Query)
+        }
+    case <-time.After(DEFAULT_EXPLAIN_TIMEOUT):
+        t.Fatalf("the explained query was not handled")
+    }
+
+    want := SlowLogStats{SlowQueries: 3, Explains: 1, Dropped: 1, RateLimited: 1}
+    if stats := s.Stats(); stats != want {
+        t.Errorf("Stats() = %+v, want %+v", stats, want)
+    }
+}
+
+func TestSanitizeQuery(t *testing.T) {
+    tests := []struct {
+        query string
+        want string
+    }{
+        {"SELECT * FROM t WHERE id = 1", "SELECT * FROM t WHERE id = ?"},
+        {"SELECT  *\n FROM t WHERE name = 'it''s' AND x = \"a\\\"b\"", "SELECT * FROM t WHERE name = ? AND x = ?"},
+        {"SELECT col1, `2col` FROM t2 LIMIT 10, 20", "SELECT col1, `2col` FROM t2 LIMIT ?, ?"},
+        {"SELECT 1.5e3", "SELECT ?"},
+    }
+    for _, tt := range tests {
+        if got := SanitizeQuery(tt.query); got != tt.want {
+            t.Errorf("SanitizeQuery(%q) = %q, want %q", tt.query, got, tt.want)
+        }
+    }
+}
@@ -0,0 +1,334 @@
+//////////////////////////////////////////////////////////////////////
+// stmtcache.go
+//
+// @usage
+//
+//     1. Import this package.
+//
+//         --------------------------------------------------
+//         import myMySQL "mysql"
+//         --------------------------------------------------
+//
+//     2. Create a cache for a connection and run the queries through it.
+//
+//         --------------------------------------------------
+//         stmts := myMySQL.NewStmtCache(myMySQL.Conn(), &myMySQL.StmtCacheOptions{Size: 200})
+//         defer stmts.Close()
+//         rows, err := stmts.QueryContext(ctx, "SELECT * FROM countries WHERE continent = ?", 2)
+//         --------------------------------------------------
+//
+//     3. Check the hit rate.
+//
+//         --------------------------------------------------
+//         stats := stmts.Stats()
+//         log.Printf("[INFO] statement cache: %d/%d, hit rate %.2f\n", stats.Len, stats.Size, stats.HitRate())
+//         --------------------------------------------------
+//
+//     The statements are keyed by the query text, so write the values as
+//     placeholders. The least recently used statement is closed when the
+//     cache is full.
+//     The server prepares each statement on each connection which runs it,
+//     so keep Size * MaxOpenConns under max_prepared_stmt_count. When the
+//     server rejects a statement for that limit, the cache drops the older
+//     half of the statements, shrinks to it, and runs the query unprepared.
+//
+//
+// MIT License
+//
+// Copyright (c) 2019 noknow.info
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+// PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+// HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+// OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTW//ARE.
+//////////////////////////////////////////////////////////////////////
+package mysql
+
+import (
+    "container/list"
+    "context"
+    "database/sql"
+    "errors"
+    "log"
+    "sync"
+    gomysql "github.com/go-sql-driver/mysql"
+)
+
+const (
+    DEFAULT_STMT_CACHE_SIZE = 100
+    // Error number when the server reached max_prepared_stmt_count.
+    ER_MAX_PREPARED_STMT_COUNT_REACHED = 1461
+)
+
+type StmtCacheOptions struct {
+    // Maximum number of statements. Defaults to DEFAULT_STMT_CACHE_SIZE.
+    Size int
+}
+
+type StmtCacheStats struct {
+    Hits int64
+    Misses int64
+    Evictions int64
+    // Queries run unprepared because of max_prepared_stmt_count.
+    Fallbacks int64
+    // Number of the statements.
+    Len int
+    // Maximum number of the statements.
+    Size int
+}
+
+type StmtCache struct {
+    db *sql.DB
+    mu sync.Mutex
+    size int
+    // Most recently used first.
+    lru *list.List
+    entries map[string]*list.Element
+    stats StmtCacheStats
+}
+
+type stmtCacheEntry struct {
+    query string
+    stmt *sql.Stmt
+    // Callers using the statement. It is closed when it was evicted and
+    // the last one released it.
+    users int
+    evicted bool
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Create a cache of the prepared statements of the connection.
+//////////////////////////////////////////////////////////////////////
+func NewStmtCache(db *sql.DB, opts *StmtCacheOptions) *StmtCache {
+    size := DEFAULT_STMT_CACHE_SIZE
+    if opts != nil && opts.Size > 0 {
+        size = opts.Size
+    }
+    return &StmtCache{
+        db: db,
+        size: size,
+        lru: list.New(),
+        entries: map[string]*list.Element{},
+    }
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Run the query with the cached statement.
+//////////////////////////////////////////////////////////////////////
+func (c *StmtCache) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+    entry, err := c.acquire(ctx, query)
+    if isStmtCountError(err) {
+        return c.db.QueryContext(ctx, query, args...)
+    }
+    if err != nil {
+        return nil, err
+    }
+    // The statement stays open for the rows even when it is closed.
+    rows, err := entry.stmt.QueryContext(ctx, args...)
+    c.release(entry)
+    if isStmtCountError(err) {
+        c.shrink()
+        return c.db.QueryContext(ctx, query, args...)
+    }
+    return rows, err
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Run the query with the cached statement and get a row.
+//////////////////////////////////////////////////////////////////////
+func (c *StmtCache) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+    entry, err := c.acquire(ctx, query)
+    if err != nil {
+        // The error is reported by the row.
+        return c.db.QueryRowContext(ctx, query, args...)
+    }
+    row := entry.stmt.QueryRowContext(ctx, args...)
+    c.release(entry)
+    if isStmtCountError(row.Err()) {
+        c.shrink()
+        return c.db.QueryRowContext(ctx, query, args...)
+    }
+    return row
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Run the statement with the cached statement.
+//////////////////////////////////////////////////////////////////////
+func (c *StmtCache) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+    entry, err := c.acquire(ctx, query)
+    if isStmtCountError(err) {
+        return c.db.ExecContext(ctx, query, args...)
+    }
+    if err != nil {
+        return nil, err
+    }
+    result, err := entry.stmt.ExecContext(ctx, args...)
+    c.release(entry)
+    if isStmtCountError(err) {
+        c.shrink()
+        return c.db.ExecContext(ctx, query, args...)
+    }
+    return result, err
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get the cached statement of the query, or prepare it.
+//////////////////////////////////////////////////////////////////////
+func (c *StmtCache) acquire(ctx context.Context, query string) (*stmtCacheEntry, error) {
+    c.mu.Lock()
+    if element, ok := c.entries[query]; ok {
+        c.stats.Hits++
+        c.lru.MoveToFront(element)
+        entry := element.Value.(*stmtCacheEntry)
+        entry.users++
+        c.mu.Unlock()
+        return entry, nil
+    }
+    c.stats.Misses++
+    c.mu.Unlock()
+
+    // Prepared without the lock, so another caller may prepare it too.
+    stmt, err := c.db.PrepareContext(ctx, query)
+    if err != nil {
+        if isStmtCountError(err) {
+            c.shrink()
+        }
+        return nil, err
+    }
+
+    c.mu.Lock()
+    if element, ok := c.entries[query]; ok {
+        c.lru.MoveToFront(element)
+        entry := element.Value.(*stmtCacheEntry)
+        entry.users++
+        c.mu.Unlock()
+        stmt.Close()
+        return entry, nil
+    }
+    entry := &stmtCacheEntry{query: query, stmt: stmt, users: 1}
+    c.entries[query] = c.lru.PushFront(entry)
+    closing := c.evict(c.size)
+    c.mu.Unlock()
+    closeStmts(closing)
+    return entry, nil
+}
+
+func (c *StmtCache) release(entry *stmtCacheEntry) {
+    c.mu.Lock()
+    entry.users--
+    closing := entry.evicted && entry.users == 0
+    c.mu.Unlock()
+    if closing {
+        entry.stmt.Close()
+    }
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Remove the least recently used statements until n are left, and get
+// the ones to close after unlocking.
+//////////////////////////////////////////////////////////////////////
+func (c *StmtCache) evict(n int) []*sql.Stmt {
+    var closing []*sql.Stmt
+    for c.lru.Len() > n {
+        entry := c.lru.Remove(c.lru.Back()).(*stmtCacheEntry)
+        delete(c.entries, entry.query)
+        entry.evicted = true
+        c.stats.Evictions++
+        if entry.users == 0 {
+            closing = append(closing, entry.stmt)
+        }
+    }
+    return closing
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Drop the older half of the statements and shrink to the rest, after
+// the server reached max_prepared_stmt_count.
+//////////////////////////////////////////////////////////////////////
+func (c *StmtCache) shrink() {
+    c.mu.Lock()
+    c.stats.Fallbacks++
+    size := c.lru.Len() / 2
+    if size < 1 {
+        size = 1
+    }
+    if size < c.size {
+        log.Printf("[WARN] max_prepared_stmt_count reached, statement cache size %d -> %d\n", c.size, size)
+        c.size = size
+    }
+    closing := c.evict(size)
+    c.mu.Unlock()
+    closeStmts(closing)
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get the statistics.
+//////////////////////////////////////////////////////////////////////
+func (c *StmtCache) Stats() StmtCacheStats {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    stats := c.stats
+    stats.Len = c.lru.Len()
+    stats.Size = c.size
+    return stats
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get the rate of the queries which found their statement.
+//////////////////////////////////////////////////////////////////////
+func (s StmtCacheStats) HitRate() float64 {
+    if s.Hits + s.Misses == 0 {
+        return 0
+    }
+    return float64(s.Hits) / float64(s.Hits + s.Misses)
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Close all the statements. The cache stays usable.
+//////////////////////////////////////////////////////////////////////
+func (c *StmtCache) Close() error {
+    c.mu.Lock()
+    // Not counted as evictions.
+    evictions := c.stats.Evictions
+    closing := c.evict(0)
+    c.stats.Evictions = evictions
+    c.mu.Unlock()
+    return closeStmts(closing)
+}
+
+func closeStmts(stmts []*sql.Stmt) error {
+    var errs []error
+    for _, stmt := range stmts {
+        if err := stmt.Close(); err != nil {
+            errs = append(errs, err)
+        }
+    }
+    return errors.Join(errs...)
+}
+
+func isStmtCountError(err error) bool {
+    var mysqlErr *gomysql.MySQLError
+    return errors.As(err, &mysqlErr) && mysqlErr.Number == ER_MAX_PREPARED_STMT_COUNT_REACHED
+}
@@ -0,0 +1,105 @@
+package mysql_test
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+    "sync/atomic"
+    "testing"
+    gomysql "github.com/go-sql-driver/mysql"
+    myMySQL "mysql"
+    "mysql/mysqltest/embedded"
+)
+
+// Open a database which counts the prepares, and fails them with
+// max_prepared_stmt_count once full is set.
+func openPrepareCountingDBT(t testing.TB, prepares *atomic.Int64, full *atomic.Bool) *sql.DB {
+    t.Helper()
+    db, _, err := myMySQL.OpenDB("mysql", embedded.NewDSN(t, nil), func(ctx context.Context, call *myMySQL.Call, next myMySQL.Handler) error {
+        if call.Op == myMySQL.OP_PREPARE {
+            if full.Load() {
+                return &gomysql.MySQLError{Number: myMySQL.ER_MAX_PREPARED_STMT_COUNT_REACHED, Message: "Can't create more than max_prepared_stmt_count statements"}
+            }
+            prepares.Add(1)
+        }
+        return next(ctx, call)
+    })
+    if err != nil {
+        t.Fatalf("OpenDB() error: %s", err)
+    }
+    t.Cleanup(func() {
+        db.Close()
+    })
+    return db
+}
+
+func queryNumberT(t testing.TB, cache *myMySQL.StmtCache, n int) {
+    t.Helper()
+    var got int
+    if err := cache.QueryRowContext(context.Background(), fmt.Sprintf("SELECT %d", n)).Scan(&got); err != nil {
+        t.Fatalf("QueryRowContext() error: %s", err)
+    }
+    if got != n {
+        t.Fatalf("SELECT %d = %d", n, got)
+    }
+}
+
+func TestStmtCacheEviction(t *testing.T) {
+    var prepares atomic.Int64
+    var full atomic.Bool
+    cache := myMySQL.NewStmtCache(openPrepareCountingDBT(t, &prepares, &full), &myMySQL.StmtCacheOptions{Size: 2})
+
+    // 1 is used more recently than 2, so 2 is evicted for 3, then 1 for 2.
+    for _, n := range []int{1, 2, 1, 3, 2} {
+        queryNumberT(t, cache, n)
+    }
+    stats := cache.Stats()
+    want := myMySQL.StmtCacheStats{Hits: 1, Misses: 4, Evictions: 2, Len: 2, Size: 2}
+    if stats != want {
+        t.Errorf("Stats() = %+v, want %+v", stats, want)
+    }
+    if got := stats.HitRate(); got != 0.2 {
+        t.Errorf("HitRate() = %v, want 0.2", got)
+    }
+    if got := prepares.Load(); got != 4 {
+        t.Errorf("prepares = %d, want 4", got)
+    }
+
+    // Close() keeps the cache usable and does not count evictions.
+    if err := cache.Close(); err != nil {
+        t.Fatalf("Close() error: %s", err)
+    }
+    queryNumberT(t, cache, 2)
+    stats = cache.Stats()
+    if stats.Len != 1 || stats.Evictions != 2 || stats.Misses != 5 {
+        t.Errorf("Stats() after Close() = %+v, want Len 1, Evictions 2, Misses 5", stats)
+    }
+}
+
+func TestStmtCacheShrink(t *testing.T) {
+    var prepares atomic.Int64
+    var full atomic.Bool
+    cache := myMySQL.NewStmtCache(openPrepareCountingDBT(t, &prepares, &full), &myMySQL.StmtCacheOptions{Size: 10})
+    for n := 1; n <= 4; n++ {
+        queryNumberT(t, cache, n)
+    }
+
+    // The query is run unprepared, and the cache drops its older half.
+    full.Store(true)
+    queryNumberT(t, cache, 5)
+    stats := cache.Stats()
+    if stats.Fallbacks != 1 || stats.Size != 2 || stats.Len != 2 || stats.Evictions != 2 {
+        t.Errorf("Stats() = %+v, want Fallbacks 1, Size 2, Len 2, Evictions 2", stats)
+    }
+
+    // The recent statements are kept.
+    full.Store(false)
+    queryNumberT(t, cache, 4)
+    queryNumberT(t, cache, 3)
+    if got := cache.Stats().Hits; got != 2 {
+        t.Errorf("Hits = %d, want 2", got)
+    }
+    if got := prepares.Load(); got != 4 {
+        t.Errorf("prepares = %d, want 4", got)
+    }
+}
@@ -0,0 +1,486 @@
+//////////////////////////////////////////////////////////////////////
+// subset.go
+//
+// @usage
+//
+//     1. Import this package.
+//
+//         --------------------------------------------------
+//         import myMySQL "mysql"
+//         --------------------------------------------------
+//
+//     2. Extract the seed rows and every row they depend on.
+//
+//         --------------------------------------------------
+//         err := myMySQL.Subset(ctx, db, w, &myMySQL.SubsetOptions{
+//             Seeds: []myMySQL.SubsetSeed{
+//                 {Table: "users", Where: "id <= ?", Args: []interface{}{100}},
+//             },
+//             ReferenceTables: []string{"countries"},
+//             Format: myMySQL.FORMAT_SQL,
+//         })
+//         --------------------------------------------------
+//
+//     The foreign keys are walked in both directions from the seed rows:
+//     the parent rows of every collected row are added, and the child rows
+//     of the seed rows (and of their children) are added. The children of
+//     rows which were only added as parents are not followed, otherwise a
+//     reference like users.country_code would pull the whole database.
+//     The reference tables are written whole and are never walked.
+//
+//
+// MIT License
+//
+// Copyright (c) 2019 noknow.info
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+// PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+// HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+// OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTW//ARE.
+//////////////////////////////////////////////////////////////////////
+package mysql
+
+import (
+    "bufio"
+    "context"
+    "database/sql"
+    "encoding/json"
+    "fmt"
+    "io"
+    "sort"
+    "strings"
+)
+
+const (
+    // A JSON object which maps table names to arrays of rows.
+    FORMAT_FIXTURE = "fixture"
+
+    DEFAULT_SUBSET_BATCH_SIZE = 500
+    DEFAULT_SUBSET_MAX_ROWS = 100000
+)
+
+type SubsetSeed struct {
+    Table string
+    // Condition of the seed rows. All rows are seeds when empty.
+    Where string
+    Args []interface{}
+}
+
+type SubsetOptions struct {
+    Seeds []SubsetSeed
+    // Tables which are written whole, e.g. "countries".
+    ReferenceTables []string
+    // FORMAT_SQL or FORMAT_FIXTURE.
+    Format string
+    // Write DROP TABLE and CREATE TABLE statements (FORMAT_SQL only).
+    CreateTables bool
+    // Stop with an error when more rows are collected.
+    MaxRows int
+    Masker *Masker
+}
+
+type subsetTable struct {
+    name string
+    primaryKey []string
+    columns []exportColumn
+    index map[string]int
+    // true when the children of the row are followed.
+    followed map[string]bool
+    rows [][]interface{}
+}
+
+type subsetRow struct {
+    table *subsetTable
+    values []interface{}
+    followChildren bool
+}
+
+type subsetter struct {
+    ctx context.Context
+    conn *sql.Conn
+    primaryKeys map[string][]string
+    tables map[string]*subsetTable
+    maxRows int
+    count int
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Extract a referentially consistent subset of the database.
+//////////////////////////////////////////////////////////////////////
+func Subset(ctx context.Context, db *sql.DB, w io.Writer, opts *SubsetOptions) error {
+    o := SubsetOptions{}
+    if opts != nil {
+        o = *opts
+    }
+    if o.Format == "" {
+        o.Format = FORMAT_SQL
+    }
+    if o.Format != FORMAT_SQL && o.Format != FORMAT_FIXTURE {
+        return fmt.Errorf("unknown subset format %q", o.Format)
+    }
+    if o.MaxRows <= 0 {
+        o.MaxRows = DEFAULT_SUBSET_MAX_ROWS
+    }
+    reference := make(map[string]bool)
+    for _, table := range o.ReferenceTables {
+        reference[table] = true
+    }
+
+    conn, err := snapshotConn(ctx, db)
+    if err != nil {
+        return err
+    }
+    defer closeSnapshotConn(conn)
+    if err := o.Masker.LoadColumnTypes(ctx, conn); err != nil {
+        return err
+    }
+
+    primaryKeys, err := PrimaryKeys(ctx, conn)
+    if err != nil {
+        return err
+    }
+    foreignKeys, err := ForeignKeys(ctx, conn)
+    if err != nil {
+        return err
+    }
+    s := &subsetter{
+        ctx: ctx,
+        conn: conn,
+        primaryKeys: primaryKeys,
+        tables: make(map[string]*subsetTable),
+        maxRows: o.MaxRows,
+    }
+
+    var queue []subsetRow
+    for _, seed := range o.Seeds {
+        if reference[seed.Table] {
+            continue
+        }
+        query := "SELECT * FROM " + QuoteIdentifier(seed.Table)
+        if seed.Where != "" {
+            query += " WHERE " + seed.Where
+        }
+        added, err := s.fetch(seed.Table, true, query, seed.Args...)
+        if err != nil {
+            return err
+        }
+        queue = append(queue, added...)
+    }
+
+    for len(queue) > 0 {
+        current := queue
+        queue = nil
+        for _, fk := range foreignKeys {
+            var parents, children [][]interface{}
+            for _, r := range current {
+                if r.table.name == fk.Table && !reference[fk.ReferencedTable] {
+                    if values := r.get(fk.Columns); values != nil {
+                        parents = append(parents, values)
+                    }
+                }
+                if r.followChildren && r.table.name == fk.ReferencedTable && !reference[fk.Table] {
+                    if values := r.get(fk.ReferencedColumns); values != nil {
+                        children = append(children, values)
+                    }
+                }
+            }
+            added, err := s.fetchIn(fk.ReferencedTable, fk.ReferencedColumns, parents, false)
+            if err != nil {
+                return err
+            }
+            queue = append(queue, added...)
+            added, err = s.fetchIn(fk.Table, fk.Columns, children, true)
+            if err != nil {
+                return err
+            }
+            queue = append(queue, added...)
+        }
+    }
+
+    names := make([]string, 0, len(s.tables) + len(reference))
+    for name := range s.tables {
+        names = append(names, name)
+    }
+    for name := range reference {
+        names = append(names, name)
+    }
+    return s.write(w, &o, sortByDependency(names, foreignKeys), reference)
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get the values of the columns. nil if any of them is NULL.
+//////////////////////////////////////////////////////////////////////
+func (r *subsetRow) get(columns []string) []interface{} {
+    values := make([]interface{}, len(columns))
+    for i, column := range columns {
+        index, ok := r.table.index[column]
+        if !ok || r.values[index] == nil {
+            return nil
+        }
+        values[i] = r.values[index]
+    }
+    return values
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Fetch the rows whose columns match one of the value tuples.
+//////////////////////////////////////////////////////////////////////
+func (s *subsetter) fetchIn(table string, columns []string, tuples [][]interface{}, followChildren bool) ([]subsetRow, error) {
+    seen := make(map[string]bool)
+    distinct := tuples[:0]
+    for _, tuple := range tuples {
+        key := subsetKey(tuple)
+        if !seen[key] {
+            seen[key] = true
+            distinct = append(distinct, tuple)
+        }
+    }
+
+    quoted := quoteIdentifiers(columns)
+    placeholder := "(" + strings.TrimSuffix(strings.Repeat("?,", len(columns)), ",") + ")"
+    left := "(" + strings.Join(quoted, ",") + ")"
+    if len(columns) == 1 {
+        placeholder = "?"
+        left = quoted[0]
+    }
+
+    var result []subsetRow
+    for start := 0; start < len(distinct); start += DEFAULT_SUBSET_BATCH_SIZE {
+        end := start + DEFAULT_SUBSET_BATCH_SIZE
+        if end > len(distinct) {
+            end = len(distinct)
+        }
+        batch := distinct[start:end]
+        args := make([]interface{}, 0, len(batch) * len(columns))
+        for _, tuple := range batch {
+            args = append(args, tuple...)
+        }
+        query := "SELECT * FROM " + QuoteIdentifier(table) + " WHERE " + left +
+                " IN (" + strings.TrimSuffix(strings.Repeat(placeholder + ",", len(batch)), ",") + ")"
+        added, err := s.fetch(table, followChildren, query, args...)
+        if err != nil {
+            return nil, err
+        }
+        result = append(result, added...)
+    }
+    return result, nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Run the query and collect the rows which are new to the subset.
+//////////////////////////////////////////////////////////////////////
+func (s *subsetter) fetch(table string, followChildren bool, query string, args ...interface{}) ([]subsetRow, error) {
+    rows, err := s.conn.QueryContext(s.ctx, query, args...)
+    if err != nil {
+        return nil, fmt.Errorf("conn.QueryContext() error: %w", err)
+    }
+    defer rows.Close()
+
+    t := s.tables[table]
+    if t == nil {
+        columns, err := exportColumns(rows)
+        if err != nil {
+            return nil, err
+        }
+        t = &subsetTable{
+            name: table,
+            primaryKey: s.primaryKeys[table],
+            columns: columns,
+            index: make(map[string]int),
+            followed: make(map[string]bool),
+        }
+        for i, c := range columns {
+            t.index[c.name] = i
+        }
+        s.tables[table] = t
+    }
+
+    scanned := make([]interface{}, len(t.columns))
+    dest := make([]interface{}, len(t.columns))
+    for i := range scanned {
+        dest[i] = &scanned[i]
+    }
+    var result []subsetRow
+    for rows.Next() {
+        if err := rows.Scan(dest...); err != nil {
+            return nil, fmt.Errorf("rows.Scan() error: %w", err)
+        }
+        values := make([]interface{}, len(t.columns))
+        for i, c := range t.columns {
+            values[i] = normalizeValue(c.kind, scanned[i])
+        }
+        r := subsetRow{table: t, values: values, followChildren: followChildren}
+
+        var key string
+        if len(t.primaryKey) > 0 {
+            key = subsetKey(r.get(t.primaryKey))
+        } else {
+            key = subsetKey(values)
+        }
+        followed, exists := t.followed[key]
+        if exists && (followed || !followChildren) {
+            continue
+        }
+        t.followed[key] = followChildren
+        if !exists {
+            t.rows = append(t.rows, values)
+            s.count++
+            if s.count > s.maxRows {
+                return nil, fmt.Errorf("subset exceeds %d rows", s.maxRows)
+            }
+        }
+        result = append(result, r)
+    }
+    return result, rows.Err()
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Write the subset in the dependency order.
+//////////////////////////////////////////////////////////////////////
+func (s *subsetter) write(w io.Writer, o *SubsetOptions, tables []string, reference map[string]bool) error {
+    bw := bufio.NewWriter(w)
+    if o.Format == FORMAT_FIXTURE {
+        bw.WriteString("{")
+    } else {
+        writeSessionHeader(bw)
+        bw.WriteString("SET FOREIGN_KEY_CHECKS=0;\n\n")
+    }
+
+    for i, table := range tables {
+        if o.Format == FORMAT_FIXTURE {
+            if i > 0 {
+                bw.WriteString(",")
+            }
+            name, _ := json.Marshal(table)
+            bw.WriteString("\n")
+            bw.Write(name)
+            bw.WriteString(": ")
+        } else if o.CreateTables {
+            if err := dumpCreateTable(s.ctx, s.conn, bw, table, true); err != nil {
+                return err
+            }
+        }
+
+        exportOptions := &ExportOptions{
+            Format: FORMAT_SQL,
+            Table: table,
+            Masker: o.Masker,
+        }
+        if o.Format == FORMAT_FIXTURE {
+            exportOptions.Format = FORMAT_JSON
+            exportOptions.fixture = true
+        }
+        if reference[table] {
+            rows, err := s.conn.QueryContext(s.ctx, "SELECT * FROM " + QuoteIdentifier(table))
+            if err != nil {
+                return fmt.Errorf("conn.QueryContext() error: %w", err)
+            }
+            err = ExportRows(rows, bw, exportOptions)
+            rows.Close()
+            if err != nil {
+                return err
+            }
+        } else {
+            t := s.tables[table]
+            if err := exportValues(bw, exportOptions, t.columns, t.rows); err != nil {
+                return err
+            }
+        }
+        if o.Format == FORMAT_SQL {
+            bw.WriteString("\n")
+        }
+    }
+
+    if o.Format == FORMAT_FIXTURE {
+        bw.WriteString("}\n")
+    } else {
+        bw.WriteString("SET FOREIGN_KEY_CHECKS=1;\n")
+        writeSessionFooter(bw)
+    }
+    return bw.Flush()
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Identity of a value tuple.
+//////////////////////////////////////////////////////////////////////
+func subsetKey(values []interface{}) string {
+    parts := make([]string, len(values))
+    for i, v := range values {
+        parts[i] = fmt.Sprint(v)
+    }
+    return strings.Join(parts, "\x00")
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Sort the tables so that the referenced tables come first.
+// The tables in a cycle are appended in name order.
+//////////////////////////////////////////////////////////////////////
+func sortByDependency(tables []string, foreignKeys []ForeignKey) []string {
+    sort.Strings(tables)
+    pending := make(map[string]map[string]bool)
+    for _, table := range tables {
+        pending[table] = make(map[string]bool)
+    }
+    for _, fk := range foreignKeys {
+        if fk.Table == fk.ReferencedTable {
+            continue
+        }
+        if _, ok := pending[fk.Table]; !ok {
+            continue
+        }
+        if _, ok := pending[fk.ReferencedTable]; ok {
+            pending[fk.Table][fk.ReferencedTable] = true
+        }
+    }
+
+    result := make([]string, 0, len(tables))
+    done := make(map[string]bool)
+    for len(result) < len(tables) {
+        progress := false
+        for _, table := range tables {
+            if done[table] {
+                continue
+            }
+            ready := true
+            for parent := range pending[table] {
+                if !done[parent] {
+                    ready = false
+                    break
+                }
+            }
+            if ready {
+                result = append(result, table)
+                done[table] = true
+                progress = true
+            }
+        }
+        if !progress {
+            for _, table := range tables {
+                if !done[table] {
+                    result = append(result, table)
+                    done[table] = true
+                }
+            }
+        }
+    }
+    return result
+}
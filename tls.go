@@ -0,0 +1,291 @@
+//////////////////////////////////////////////////////////////////////
+// tls.go
+//
+// @usage
+//
+//     1. Import this package.
+//
+//         --------------------------------------------------
+//         import myMySQL "mysql"
+//         --------------------------------------------------
+//
+//     2. Add a TLS config to the DSN and initialize database.
+//
+//         --------------------------------------------------
+//         datasourceName, err := myMySQL.WithTLS("DB_USER:DB_PASS@tcp(db:3306)/DB_NAME?parseTime=true", &myMySQL.TLSOptions{
+//             CAFile: "/etc/mysql/tls/ca.pem",
+//             CertFile: "/etc/mysql/tls/client-cert.pem",
+//             KeyFile: "/etc/mysql/tls/client-key.pem",
+//         })
+//         if err != nil {
+//             // Error handling.
+//         }
+//         myMySQL.Init(datasourceName)
+//         --------------------------------------------------
+//
+//     3. Or register it and set the name to the tls parameter yourself.
+//
+//         --------------------------------------------------
+//         name, err := myMySQL.RegisterTLS(opts)
+//         datasourceName := "DB_USER:DB_PASS@tcp(db:3306)/DB_NAME?tls=" + name
+//         --------------------------------------------------
+//
+//     The files are checked on each new connection and read again when
+//     they changed, so rotated certificates are used without a restart.
+//     When the new files are broken, the last good ones are kept.
+//
+//     The server certificate is verified with the CA bundle (the system
+//     roots without CAFile). Its host name is verified with ServerName,
+//     which WithTLS() sets to the host of a single host DSN, or else with
+//     the server name of the connection, which the failover connector sets
+//     to the host of each connection. The connection fails when there is
+//     neither. InsecureSkipVerify is for development only.
+//
+//
+// MIT License
+//
+// Copyright (c) 2019 noknow.info
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+// INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+// PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+// HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+// OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+// OR THE USE OR OTHER DEALINGS IN THE SOFTW//ARE.
+//////////////////////////////////////////////////////////////////////
+package mysql
+
+import (
+    "crypto/tls"
+    "crypto/x509"
+    "errors"
+    "fmt"
+    "log"
+    "net"
+    "net/url"
+    "os"
+    "strings"
+    "sync"
+    "sync/atomic"
+    gomysql "github.com/go-sql-driver/mysql"
+)
+
+const (
+    TLS_CONFIG_PREFIX = "mysql-tls-"
+    DEFAULT_TLS_MIN_VERSION = tls.VersionTLS12
+)
+
+var (
+    myTLSConfigs atomic.Int64
+)
+
+type TLSOptions struct {
+    // PEM CA bundle. Defaults to the system roots.
+    CAFile string
+    // PEM client certificate and key, e.g. for REQUIRE X509 users.
+    CertFile string
+    KeyFile string
+    // Host name in the server certificate. Defaults to the server name of
+    // the connection.
+    ServerName string
+    // e.g. tls.VersionTLS13. Defaults to DEFAULT_TLS_MIN_VERSION.
+    MinVersion uint16
+    // Do not verify the server certificate. Development only.
+    InsecureSkipVerify bool
+}
+
+type tlsFiles struct {
+    o TLSOptions
+    mu sync.Mutex
+    // Modification times and sizes of the files read last.
+    stamp string
+    roots *x509.CertPool
+    cert *tls.Certificate
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Register a TLS config with go-sql-driver/mysql under a generated
+// name, which is the value of the tls parameter of the DSN.
+//////////////////////////////////////////////////////////////////////
+func RegisterTLS(opts *TLSOptions) (string, error) {
+    config, err := NewTLSConfig(opts)
+    if err != nil {
+        return "", err
+    }
+    name := fmt.Sprintf("%s%d", TLS_CONFIG_PREFIX, myTLSConfigs.Add(1))
+    if err := gomysql.RegisterTLSConfig(name, config); err != nil {
+        return "", fmt.Errorf("mysql.RegisterTLSConfig() error: %w", err)
+    }
+    return name, nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Register a TLS config and set it to the tls parameter of the DSN.
+// ServerName defaults to the host of a single host DSN.
+//////////////////////////////////////////////////////////////////////
+func WithTLS(datasourceName string, opts *TLSOptions) (string, error) {
+    cfg, err := gomysql.ParseDSN(datasourceName)
+    if err != nil {
+        return "", fmt.Errorf("mysql.ParseDSN() error: %w", err)
+    }
+    o := TLSOptions{}
+    if opts != nil {
+        o = *opts
+    }
+    if o.ServerName == "" && cfg.Net == "tcp" && !strings.Contains(cfg.Addr, ",") {
+        if host, _, err := net.SplitHostPort(cfg.Addr); err == nil {
+            o.ServerName = host
+        }
+    }
+    name, err := RegisterTLS(&o)
+    if err != nil {
+        return "", err
+    }
+    if strings.Contains(datasourceName, "?") {
+        return datasourceName + "&tls=" + url.QueryEscape(name), nil
+    }
+    return datasourceName + "?tls=" + url.QueryEscape(name), nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Create a TLS config which reads the files again when they changed.
+//////////////////////////////////////////////////////////////////////
+func NewTLSConfig(opts *TLSOptions) (*tls.Config, error) {
+    f := &tlsFiles{}
+    if opts != nil {
+        f.o = *opts
+    }
+    if f.o.MinVersion == 0 {
+        f.o.MinVersion = DEFAULT_TLS_MIN_VERSION
+    }
+    if (f.o.CertFile == "") != (f.o.KeyFile == "") {
+        return nil, errors.New("both CertFile and KeyFile are needed")
+    }
+    if err := f.load(f.fileStamp()); err != nil {
+        return nil, err
+    }
+
+    config := &tls.Config{
+        ServerName: f.o.ServerName,
+        MinVersion: f.o.MinVersion,
+        // The certificate is verified in VerifyConnection with the current CA bundle.
+        InsecureSkipVerify: true,
+    }
+    if f.o.CertFile != "" {
+        config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+            _, cert := f.current()
+            return cert, nil
+        }
+    }
+    if !f.o.InsecureSkipVerify {
+        config.VerifyConnection = f.verify
+    }
+    return config, nil
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Get the CA bundle and the client certificate, read again when the
+// files changed.
+//////////////////////////////////////////////////////////////////////
+func (f *tlsFiles) current() (*x509.CertPool, *tls.Certificate) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    if stamp := f.fileStamp(); stamp != f.stamp {
+        if err := f.load(stamp); err != nil {
+            // Warned once per change of the files.
+            f.stamp = stamp
+            log.Printf("[WARN] reloading the TLS files error: %s\n", err)
+        }
+    }
+    return f.roots, f.cert
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Read the files. Nothing is changed on error.
+//////////////////////////////////////////////////////////////////////
+func (f *tlsFiles) load(stamp string) error {
+    var roots *x509.CertPool
+    if f.o.CAFile != "" {
+        data, err := os.ReadFile(f.o.CAFile)
+        if err != nil {
+            return fmt.Errorf("os.ReadFile() error: %w", err)
+        }
+        roots = x509.NewCertPool()
+        if !roots.AppendCertsFromPEM(data) {
+            return fmt.Errorf("no certificates in %s", f.o.CAFile)
+        }
+    }
+    var cert *tls.Certificate
+    if f.o.CertFile != "" {
+        keyPair, err := tls.LoadX509KeyPair(f.o.CertFile, f.o.KeyFile)
+        if err != nil {
+            return fmt.Errorf("tls.LoadX509KeyPair() error: %w", err)
+        }
+        cert = &keyPair
+    }
+    f.stamp = stamp
+    f.roots = roots
+    f.cert = cert
+    return nil
+}
+
+func (f *tlsFiles) fileStamp() string {
+    var b strings.Builder
+    for _, path := range []string{f.o.CAFile, f.o.CertFile, f.o.KeyFile} {
+        if path == "" {
+            continue
+        }
+        if info, err := os.Stat(path); err != nil {
+            fmt.Fprintf(&b, "%s:-;", path)
+        } else {
+            fmt.Fprintf(&b, "%s:%d:%d;", path, info.ModTime().UnixNano(), info.Size())
+        }
+    }
+    return b.String()
+}
+
+
+//////////////////////////////////////////////////////////////////////
+// Verify the server certificate with the current CA bundle, and its
+// host name with ServerName or the server name of the connection.
+//////////////////////////////////////////////////////////////////////
+func (f *tlsFiles) verify(cs tls.ConnectionState) error {
+    if len(cs.PeerCertificates) == 0 {
+        return errors.New("no server certificate")
+    }
+    name := f.o.ServerName
+    if name == "" {
+        name = cs.ServerName
+    }
+    if name == "" {
+        return errors.New("no server name to verify the server certificate with, set ServerName")
+    }
+    roots, _ := f.current()
+    opts := x509.VerifyOptions{
+        Roots: roots,
+        DNSName: name,
+        Intermediates: x509.NewCertPool(),
+    }
+    for _, cert := range cs.PeerCertificates[1:] {
+        opts.Intermediates.AddCert(cert)
+    }
+    if _, err := cs.PeerCertificates[0].Verify(opts); err != nil {
+        return fmt.Errorf("server certificate error: %w", err)
+    }
+    return nil
+}
@@ -0,0 +1,126 @@
+package mysql_test
+
+import (
+    "crypto/tls"
+    "net"
+    "os"
+    "strings"
+    "testing"
+    "time"
+    myMySQL "mysql"
+    "mysql/mysqltest"
+)
+
+func TestTLSConfigVerify(t *testing.T) {
+    certs := mysqltest.GenerateCertsT(t)
+    other := mysqltest.GenerateCertsT(t)
+    tests := []struct {
+        name string
+        opts myMySQL.TLSOptions
+        // Server name of the connection.
+        serverName string
+        err string
+    }{
+        {"right CA", myMySQL.TLSOptions{CAFile: certs.CAFile, ServerName: "localhost"}, "", ""},
+        {"IP address", myMySQL.TLSOptions{CAFile: certs.CAFile, ServerName: "127.0.0.1"}, "", ""},
+        {"server name of the connection", myMySQL.TLSOptions{CAFile: certs.CAFile}, "localhost", ""},
+        {"wrong host name", myMySQL.TLSOptions{CAFile: certs.CAFile, ServerName: "db.example.com"}, "", "db.example.com"},
+        {"wrong host name of the connection", myMySQL.TLSOptions{CAFile: certs.CAFile}, "db.example.com", "db.example.com"},
+        {"no server name", myMySQL.TLSOptions{CAFile: certs.CAFile}, "", "no server name"},
+        {"wrong CA", myMySQL.TLSOptions{CAFile: other.CAFile, ServerName: "localhost"}, "", "unknown authority"},
+        {"insecure", myMySQL.TLSOptions{CAFile: other.CAFile, InsecureSkipVerify: true}, "", ""},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            config, err := myMySQL.NewTLSConfig(&tt.opts)
+            if err != nil {
+                t.Fatalf("NewTLSConfig() error: %s", err)
+            }
+            if tt.serverName != "" {
+                config = config.Clone()
+                config.ServerName = tt.serverName
+            }
+            err = handshakeT(t, certs, config)
+            if tt.err == "" && err != nil {
+                t.Errorf("Handshake() error: %s", err)
+            }
+            if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+                t.Errorf("Handshake() error = %v, want %q", err, tt.err)
+            }
+        })
+    }
+}
+
+func TestTLSConfigRotation(t *testing.T) {
+    old := mysqltest.GenerateCertsT(t)
+    rotated := mysqltest.GenerateCertsT(t)
+    config, err := myMySQL.NewTLSConfig(&myMySQL.TLSOptions{CAFile: old.CAFile, ServerName: "localhost"})
+    if err != nil {
+        t.Fatalf("NewTLSConfig() error: %s", err)
+    }
+    if err := handshakeT(t, rotated, config); err == nil {
+        t.Fatal("Handshake() with the server certificate of another CA succeeded")
+    }
+
+    // Rotate the CA bundle in place.
+    data, err := os.ReadFile(rotated.CAFile)
+    if err != nil {
+        t.Fatalf("os.ReadFile() error: %s", err)
+    }
+    if err := os.WriteFile(old.CAFile, data, 0600); err != nil {
+        t.Fatalf("os.WriteFile() error: %s", err)
+    }
+    future := time.Now().Add(time.Minute)
+    if err := os.Chtimes(old.CAFile, future, future); err != nil {
+        t.Fatalf("os.Chtimes() error: %s", err)
+    }
+    if err := handshakeT(t, rotated, config); err != nil {
+        t.Errorf("Handshake() after the rotation error: %s", err)
+    }
+    if err := handshakeT(t, old, config); err == nil {
+        t.Error("Handshake() with the server certificate of the old CA succeeded")
+    }
+
+    // Broken files keep the last good ones.
+    if err := os.WriteFile(old.CAFile, []byte("broken"), 0600); err != nil {
+        t.Fatalf("os.WriteFile() error: %s", err)
+    }
+    if err := handshakeT(t, rotated, config); err != nil {
+        t.Errorf("Handshake() with a broken CA file error: %s", err)
+    }
+}
+
+// Handshake with a server which uses the server certificate of the certs,
+// and get the error of the client.
+func handshakeT(t testing.TB, certs *mysqltest.Certs, config *tls.Config) error {
+    t.Helper()
+    serverConfig, err := certs.ServerTLSConfig()
+    if err != nil {
+        t.Fatalf("ServerTLSConfig() error: %s", err)
+    }
+    listener, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatalf("net.Listen() error: %s", err)
+    }
+    defer listener.Close()
+    done := make(chan struct{})
+    go func() {
+        defer close(done)
+        serverConn, err := listener.Accept()
+        if err != nil {
+            return
+        }
+        server := tls.Server(serverConn, serverConfig)
+        server.Handshake()
+        server.Close()
+    }()
+    clientConn, err := net.Dial("tcp", listener.Addr().String())
+    if err != nil {
+        t.Fatalf("net.Dial() error: %s", err)
+    }
+    client := tls.Client(clientConn, config)
+    err = client.Handshake()
+    client.Close()
+    <-done
+    return err
+}